@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/thermofisher/json2parquet/log"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+// appendedFile is an existing file the records are appended to. The records are written to a temporary
+// file whose row groups are appended to the file in place (see parquet.AppendFile). When they cannot be
+// appended in place, the file is rewritten with its rows followed by the new records and replaces the
+// existing file when the writer is closed.
+type appendedFile struct {
+	path   string
+	reader *parquet.Reader
+	tmp    string // temporary file of the records appended in place, empty when the file is rewritten
+}
+
+func openAppendedFile(path string, kms parquet.KMS) (*appendedFile, error) {
+	reader, err := parquet.OpenReader(path, kms)
+	if err != nil {
+		return nil, err
+	}
+	return &appendedFile{path: path, reader: reader}, nil
+}
+
+// addOverrides adds the overrides of the file to the overrides of the appended records, so the fields
+// are inferred with the same types, the overrides of the appended records take precedence
+func (a *appendedFile) addOverrides(overrides parquet.Overrides) parquet.Overrides {
+	if overrides == nil {
+		overrides = make(parquet.Overrides)
+	}
+	for key, override := range a.reader.Schema().Overrides() {
+		if _, ok := overrides[key]; !ok {
+			overrides[key] = override
+		}
+	}
+	return overrides
+}
+
+// mergeSchema checks that the schema inferred from the appended records is compatible with the schema
+// of the file, the fields missing on either side become optional
+func (a *appendedFile) mergeSchema(sb *parquet.SchemaBuilder) error {
+	if err := sb.Merge(a.reader.Schema()); err != nil {
+		return fmt.Errorf("the data cannot be appended to file(%v): %w", a.path, err)
+	}
+	return nil
+}
+
+// metadataOptions returns the writer options keeping the user entries of the footer metadata of the file,
+// the entries of the tool and the entries set again are not kept
+func (a *appendedFile) metadataOptions(entries metadataFlags) []parquet.WriterOption {
+	set := make(map[string]struct{}, len(entries))
+	for _, e := range entries {
+		set[e.key] = struct{}{}
+	}
+	kv := a.reader.Metadata()
+	var opts []parquet.WriterOption
+	for i, key := range kv.Keys() {
+		if _, ok := set[key]; ok || strings.HasPrefix(key, "json2parquet.") || strings.HasPrefix(key, "ARROW:") {
+			continue
+		}
+		opts = append(opts, parquet.WithKeyValueMetadata(key, kv.Values()[i]))
+	}
+	return opts
+}
+
+// output returns the path the records are written to: the temporary file when they can be appended in
+// place and the file otherwise. The file is rewritten when it is encrypted or has page indexes, when the
+// records change its parquet schema and when the output is encrypted, has page indexes, is written by the
+// ArrowWriter or is sorted as a whole (rewrite is set).
+func (a *appendedFile) output(sc *pqSchema.Schema, rewrite bool) (string, error) {
+	if rewrite {
+		log.Logger().Debugf("the options of the output require rewriting file(%v)", a.path)
+		return a.path, nil
+	}
+	if err := a.reader.CheckAppend(sc); err != nil {
+		log.Logger().Debugf("rewriting file(%v): %v", a.path, err)
+		return a.path, nil
+	}
+	f, err := os.CreateTemp(filepath.Dir(a.path), "."+filepath.Base(a.path)+".*.append")
+	if err != nil {
+		return "", err
+	}
+	a.tmp = f.Name()
+	return a.tmp, f.Close()
+}
+
+// write writes the rows of the rewritten file and returns the sources of the file recorded in its footer
+// metadata, the rows of a file appended in place are not written
+func (a *appendedFile) write(ctx context.Context, wr recordWriter) ([]source, error) {
+	if a.tmp == "" {
+		if err := a.reader.Read(ctx, wr.Write); err != nil {
+			return nil, fmt.Errorf("failed to read file(%v): %w", a.path, err)
+		}
+	}
+	sources, err := writtenSources(a.reader.Metadata())
+	if err != nil {
+		return nil, fmt.Errorf("invalid sources of file(%v): %w", a.path, err)
+	}
+	return sources, nil
+}
+
+// finish appends the row groups of the temporary file to the file in place and prints the summary of the
+// file, the summary of a rewritten file is printed by the output
+func (a *appendedFile) finish(console io.Writer, out outputConfig, wr recordWriter) error {
+	if a.tmp == "" {
+		return out.finish(console, wr)
+	}
+	if err := parquet.AppendFile(a.path, a.tmp); err != nil {
+		return err
+	}
+	f, err := parquet.OpenFile(a.path, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if summary, err := parquet.NewSummary(f.MetaData()); err == nil {
+		printSummary(console, summary)
+	}
+	return nil
+}
+
+func (a *appendedFile) close() {
+	_ = a.reader.Close()
+	if a.tmp != "" {
+		_ = os.Remove(a.tmp)
+	}
+}
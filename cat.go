@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+// errLimitReached stops reading the rows when the limit of cat is reached
+var errLimitReached = errors.New("limit reached")
+
+func runCat(fs *flag.FlagSet, args []string) int {
+	var limit int64
+	var encryption encryptionFlags
+	fs.Int64Var(&limit, "limit", 0, "Maximum number of rows printed (0 means no limit)")
+	encryption.register(fs, false)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		return usageError(fs, "no parquet files")
+	}
+	kms, err := encryption.kms()
+	if err != nil {
+		return fail("%v", err)
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	enc := json.NewEncoder(out)
+	var rows int64
+	for _, path := range fs.Args() {
+		reader, err := parquet.OpenReader(path, kms)
+		if err != nil {
+			return fail("failed to open file(%v): %v", path, err)
+		}
+		err = reader.Read(ctx, func(data map[string]interface{}) error {
+			if limit > 0 && rows >= limit {
+				return errLimitReached
+			}
+			rows++
+			return enc.Encode(jsonRecord(data))
+		})
+		_ = reader.Close()
+		if errors.Is(err, errLimitReached) {
+			return exitOK
+		}
+		if err != nil {
+			return fail("failed to read file(%v): %v", path, err)
+		}
+	}
+	return exitOK
+}
+
+// jsonRecord returns the record with the JSON text of the JSON values kept as JSON instead of strings
+func jsonRecord(data map[string]interface{}) map[string]interface{} {
+	for key, v := range data {
+		data[key] = jsonValue(v)
+	}
+	return data
+}
+
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case tfJson.Raw:
+		return json.RawMessage(v)
+	case map[string]interface{}:
+		return jsonRecord(v)
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonValue(e)
+		}
+	}
+	return v
+}
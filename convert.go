@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+// minimal size of a part of an input file inferred in parallel
+const minInferencePartSize = 4 << 20
+
+func runConvert(fs *flag.FlagSet, args []string) int {
+	var verbose bool
+	var inferOnly bool
+	var batchSize uint
+	var output string
+	var inference inferenceFlags
+	var layoutOpts layoutFlags
+	var writer writerFlags
+	var encryption encryptionFlags
+	var maxRowsPerFile int64
+	var maxBytesPerFile int64
+	var manifest string
+	var appendTo string
+	var partitionBy string
+	var partitionFile string
+	var keepPartitionColumns bool
+	var maxOpenPartitions int
+	var sortBy string
+	var sortScope string
+	var sortBufferRows int
+	var sortTempDir string
+
+	fs.BoolVar(&verbose, "v", false, "Enable verbose mode")
+	fs.BoolVar(&inferOnly, "i", false, "Infer the parquet schema from json data and exit (see the infer command)")
+	fs.UintVar(&batchSize, "b", 1000, "Batch size of the stored JSON data before it is send to parquet writer to process")
+	fs.StringVar(&output, "o", "out.parquet", "Specify the output file, - writes to stdout (default is out.parquet). When the output is split into files it is the file name template with the {index} or {index:width} placeholder, e.g. out-{index:05}.parquet")
+	fs.Int64Var(&maxRowsPerFile, "max-rows-per-file", 0, "Maximum number of rows of an output file, the output is split into files named by the -o template (0 means no limit)")
+	fs.Int64Var(&maxBytesPerFile, "max-bytes-per-file", 0, "Maximum size of an output file in bytes, the output is split into files named by the -o template (0 means no limit)")
+	fs.StringVar(&appendTo, "append", "", "Append the records to an existing parquet file written by json2parquet instead of -o, the inferred schema must be compatible with the schema of the file. The row groups of the records are appended in place, the file is rewritten with its rows followed by the new records when the file or the output is encrypted, has page indexes, is written by -arrow or sorted with -sort-scope file, or when the schema of the file changes")
+	fs.StringVar(&manifest, "manifest", "", "Write the list of the output files to the manifest (default is manifest.json in the directory of the output files when the output is split)")
+	fs.StringVar(&partitionBy, "partition-by", "", "Write Hive style partition directories in the -o directory by the comma separated columns, a timestamp column can be bucketed by day or hour, e.g. region,event_time:day")
+	fs.StringVar(&partitionFile, "partition-file", "part-{index:04}.parquet", "File name template of the files of a partition")
+	fs.BoolVar(&keepPartitionColumns, "keep-partition-columns", false, "Keep the partition columns in the files of the partitions")
+	fs.IntVar(&maxOpenPartitions, "max-open-partitions", 64, "Maximum number of partitions written at the same time, the least recently used partition is closed and continued in a new file")
+	fs.StringVar(&sortBy, "sort-by", "", "Sort the rows by the comma separated columns in the format key[:asc|desc][:nulls_first|nulls_last], e.g. tenant_id,timestamp:desc")
+	fs.StringVar(&sortScope, "sort-scope", "file", "Scope of the sort, file sorts all the rows of the output and row-group sorts the rows of each row group")
+	fs.IntVar(&sortBufferRows, "sort-buffer-rows", 1000000, "Number of rows sorted in memory, larger outputs are sorted in runs spilled to temporary files, with -sort-scope row-group it is the maximum number of rows of a row group")
+	fs.StringVar(&sortTempDir, "sort-temp-dir", "", "Directory of the temporary files of the sort (default is the directory for temporary files of the system)")
+	inference.register(fs)
+	layoutOpts.register(fs)
+	writer.register(fs)
+	encryption.register(fs, true)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		return usageError(fs, "no input files")
+	}
+	if batchSize == 0 {
+		return usageError(fs, "batch size cannot be zero")
+	}
+	if appendTo != "" {
+		var outputSet bool
+		fs.Visit(func(f *flag.Flag) {
+			outputSet = outputSet || f.Name == "o"
+		})
+		if outputSet {
+			return usageError(fs, "the output of -append is the appended file, -o cannot be set")
+		}
+		if maxRowsPerFile > 0 || maxBytesPerFile > 0 || partitionBy != "" || manifest != "" {
+			return usageError(fs, "the appended file cannot be split into files, partitioned or described by a manifest")
+		}
+		output = appendTo
+	}
+
+	layout, err := layoutOpts.layout()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	writerOptions, err := writer.options(layout)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	kms, err := encryption.kms()
+	if err != nil {
+		return fail("%v", err)
+	}
+	encryptionOptions, err := encryption.options(kms)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	writerOptions = append(writerOptions, encryptionOptions...)
+
+	if err = setLogger(verbose); err != nil {
+		return fail("failed create logger: %v", err)
+	}
+
+	filenames := fs.Args()
+
+	// the messages go to stderr when the parquet data is written to stdout
+	console := os.Stdout
+	if output == "-" {
+		console = os.Stderr
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	var appended *appendedFile
+	if appendTo != "" {
+		if appended, err = openAppendedFile(appendTo, kms); err != nil {
+			return fail("failed to open appended file: %v", err)
+		}
+		defer appended.close()
+		inference.overrides.overrides = appended.addOverrides(inference.overrides.overrides)
+	}
+
+	fmt.Fprintf(console, "Infering parquet schema\n\n")
+	sb := inference.schemaBuilder()
+	if err = inference.infer(ctx, sb, filenames); err != nil {
+		return fail("failed to infer parquet schema from JSON data: %v", err)
+	}
+	if appended != nil {
+		if err = appended.mergeSchema(sb); err != nil {
+			return fail("incompatible schema: %v", err)
+		}
+	}
+
+	sc := sb.Schema()
+	sc2, err := sc.SchemaWithLayout(layout)
+	if err != nil {
+		return fail("failed to build parquet schema: %v", err)
+	}
+	pqSchema.PrintSchema(sc2.Root(), console, 2)
+	fmt.Fprintln(console)
+
+	if inferOnly {
+		return exitOK
+	}
+
+	fmt.Fprintf(console, "Reading JSON data and writing data to %v\n\n", output)
+
+	metaOptions, err := metadataOptions(inference.options(), writer.meta)
+	if err != nil {
+		return fail("failed to create footer metadata: %v", err)
+	}
+	writerOptions = append(writerOptions, metaOptions...)
+	if appended != nil {
+		writerOptions = append(writerOptions, appended.metadataOptions(writer.meta)...)
+	}
+
+	out := outputConfig{
+		path:                 output,
+		maxRows:              maxRowsPerFile,
+		maxBytes:             maxBytesPerFile,
+		manifest:             manifest,
+		partitionFile:        partitionFile,
+		keepPartitionColumns: keepPartitionColumns,
+		maxOpenPartitions:    maxOpenPartitions,
+		sortBufferRows:       sortBufferRows,
+		sortTempDir:          sortTempDir,
+		memoryLimit:          writer.memoryLimit,
+		arrow:                writer.arrow,
+	}
+	if partitionBy != "" {
+		if out.partitionBy, err = parquet.ParsePartitionColumns(partitionBy); err != nil {
+			return usageError(fs, "failed to parse partition columns: %v", err)
+		}
+	}
+	if sortBy != "" {
+		if out.sortBy, err = parquet.ParseSortColumns(sortBy); err != nil {
+			return usageError(fs, "failed to parse sort columns: %v", err)
+		}
+		switch sortScope {
+		case "file":
+		case "row-group":
+			out.sortRowGroups = true
+		default:
+			return usageError(fs, "invalid sort scope: %v", sortScope)
+		}
+	}
+	if appended != nil {
+		// the whole sorted output includes the rows of the file and the parquet schema of the ArrowWriter is
+		// converted from the Arrow schema
+		rewrite := len(encryptionOptions) > 0 || writer.pageIndex || writer.arrow || (len(out.sortBy) > 0 && !out.sortRowGroups)
+		if out.path, err = appended.output(sc2, rewrite); err != nil {
+			return fail("failed to create parquet file write: %v", err)
+		}
+	}
+	wr, err := out.open(batchSize, sc, writerOptions)
+	if err != nil {
+		return fail("failed to create parquet file write: %v", err)
+	}
+
+	// the files of a split or partitioned output can be closed before all the records are written, so the
+	// sources are read before the records and recorded in the footer metadata of all the files (the output
+	// of -append is a single file)
+	var scanned []source
+	if out.isSplit() || out.isPartitioned() {
+		if scanned, err = scanSources(ctx, filenames, inference.configureReader); err != nil {
+			wr.Abort()
+			return fail("failed to read JSON data: %v", err)
+		}
+		if err = appendSourcesMetadata(wr, scanned); err != nil {
+			wr.Abort()
+			return fail("failed to write footer metadata: %v", err)
+		}
+	}
+	var sources []source
+	if appended != nil {
+		if sources, err = appended.write(ctx, wr); err != nil {
+			wr.Abort()
+			return fail("failed to write appended file: %v", err)
+		}
+	}
+	written, err := writeSources(ctx, wr, filenames, inference.configureReader)
+	if err != nil {
+		wr.Abort()
+		return fail("failed to write JSON data: %v", err)
+	}
+	if scanned == nil {
+		err = appendSourcesMetadata(wr, append(sources, written...))
+	} else if !slices.Equal(scanned, written) {
+		err = errors.New("the input files changed while they were written")
+	}
+	if err != nil {
+		wr.Abort()
+		return fail("failed to write footer metadata: %v", err)
+	}
+	if err = wr.Close(); err != nil {
+		return fail("failed to write parquet file: %v", err)
+	}
+	if appended != nil {
+		if err = appended.finish(console, out, wr); err != nil {
+			return fail("failed to append to file: %v", err)
+		}
+	} else if err = out.finish(console, wr); err != nil {
+		return fail("failed to write manifest: %v", err)
+	}
+
+	fmt.Fprintln(console, "Success!")
+	return exitOK
+}
+
+// writeSources writes the records of the input files and returns their sources, writing stops at the first error
+func writeSources(ctx context.Context, wr recordWriter, filenames []string, configure func(*tfJson.Reader)) ([]source, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	sources := make([]source, 0, len(filenames))
+	for _, filename := range filenames {
+		src, err := readSource(ctx, filename, configure, func(data tfJson.NDJsonRecord) {
+			if errW := wr.Write(data); errW != nil {
+				cancel(fmt.Errorf("failed to write data: %w", errW))
+			}
+		})
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file(%v): %w", filename, err)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
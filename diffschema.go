@@ -0,0 +1,103 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func runDiffSchema(fs *flag.FlagSet, args []string) int {
+	var encryption encryptionFlags
+	encryption.register(fs, false)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		return usageError(fs, "two parquet files are compared")
+	}
+	kms, err := encryption.kms()
+	if err != nil {
+		return fail("%v", err)
+	}
+	var columns [2]map[string]string
+	for i, path := range fs.Args() {
+		if columns[i], err = describeColumns(path, kms); err != nil {
+			return fail("failed to read schema of file(%v): %v", path, err)
+		}
+	}
+	if !diffColumns(os.Stdout, columns[0], columns[1]) {
+		fmt.Println("The schemas are equal")
+		return exitOK
+	}
+	return exitInvalid
+}
+
+// describeColumns returns the descriptions of the leaf columns of the file by their paths
+func describeColumns(path string, kms parquet.KMS) (map[string]string, error) {
+	f, err := parquet.OpenFile(path, kms)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := f.MetaData().Schema
+	columns := make(map[string]string, sc.NumColumns())
+	for i := range sc.NumColumns() {
+		columns[sc.Column(i).Path()] = describeColumn(sc.Column(i))
+	}
+	return columns, nil
+}
+
+// describeColumn describes the repetitions of the nodes on the path of the column, the physical type
+// and the logical type of the column, e.g. optional.repeated.optional BYTE_ARRAY String
+func describeColumn(col *pqSchema.Column) string {
+	var repetitions []string
+	for node := pqSchema.Node(col.SchemaNode()); node != nil && node.Parent() != nil; node = node.Parent() {
+		repetitions = append([]string{node.RepetitionType().String()}, repetitions...)
+	}
+	description := strings.Join(repetitions, ".") + " " + col.PhysicalType().String()
+	if lt := col.LogicalType(); lt != nil && !lt.Equals(pqSchema.NoLogicalType{}) {
+		description += " " + lt.String()
+	}
+	return description
+}
+
+// diffColumns prints the columns only in the first file (-), only in the second file (+) and the
+// columns with different descriptions (~) in the order of their paths, true when there is a difference
+func diffColumns(out io.Writer, a, b map[string]string) bool {
+	paths := make(map[string]struct{}, len(a)+len(b))
+	for path := range a {
+		paths[path] = struct{}{}
+	}
+	for path := range b {
+		paths[path] = struct{}{}
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		sorted = append(sorted, path)
+	}
+	slices.Sort(sorted)
+	var different bool
+	for _, path := range sorted {
+		da, okA := a[path]
+		db, okB := b[path]
+		switch {
+		case !okB:
+			fmt.Fprintf(out, "- %v: %v\n", path, da)
+		case !okA:
+			fmt.Fprintf(out, "+ %v: %v\n", path, db)
+		case da != db:
+			fmt.Fprintf(out, "~ %v: %v -> %v\n", path, da, db)
+		default:
+			continue
+		}
+		different = true
+	}
+	return different
+}
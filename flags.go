@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	pqParquet "github.com/apache/arrow-go/v18/parquet"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+// columnFlags collects repeated flags in the format column=value
+type columnFlags map[string]string
+
+func (c columnFlags) String() string {
+	return fmt.Sprint(map[string]string(c))
+}
+
+func (c columnFlags) Set(value string) error {
+	column, v, ok := strings.Cut(value, "=")
+	if !ok || column == "" {
+		return fmt.Errorf("invalid value(%v): expected column=value", value)
+	}
+	c[column] = v
+	return nil
+}
+
+// overrideFlags collects repeated flags in the format key=type[:repetition], the flag values
+// are kept to be recorded in the footer metadata
+type overrideFlags struct {
+	overrides parquet.Overrides
+	values    []string
+}
+
+func (o *overrideFlags) String() string {
+	return strings.Join(o.values, ",")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	key, override, err := parquet.ParseOverride(value)
+	if err != nil {
+		return err
+	}
+	if o.overrides == nil {
+		o.overrides = make(parquet.Overrides)
+	}
+	o.overrides[key] = override
+	o.values = append(o.values, value)
+	return nil
+}
+
+type metadataEntry struct {
+	key, value string
+}
+
+// metadataFlags collects repeated flags in the format key=value
+type metadataFlags []metadataEntry
+
+func (m *metadataFlags) String() string {
+	entries := make([]string, 0, len(*m))
+	for _, e := range *m {
+		entries = append(entries, e.key+"="+e.value)
+	}
+	return strings.Join(entries, ",")
+}
+
+func (m *metadataFlags) Set(value string) error {
+	key, v, ok := strings.Cut(value, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid value(%v): expected key=value", value)
+	}
+	*m = append(*m, metadataEntry{key: key, value: v})
+	return nil
+}
+
+func parseColumnBool(option, column, value string) (bool, error) {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %v setting(%v) of column(%v): %w", option, value, column, err)
+	}
+	return enabled, nil
+}
+
+func parseColumnOptions(compression, dictionary, encoding, statistics, bloomFilters columnFlags) ([]parquet.WriterOption, error) {
+	var opts []parquet.WriterOption
+	for column, value := range compression {
+		codec, level, err := parquet.ParseCompression(value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parquet.WithColumnCompression(column, codec, level))
+	}
+	for column, value := range dictionary {
+		enabled, err := parseColumnBool("dictionary", column, value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parquet.WithColumnDictionary(column, enabled))
+	}
+	for column, value := range encoding {
+		enc, err := parquet.ParseEncoding(value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parquet.WithColumnEncoding(column, enc))
+	}
+	for column, value := range statistics {
+		enabled, err := parseColumnBool("statistics", column, value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, parquet.WithColumnStatistics(column, enabled))
+	}
+	for column, value := range bloomFilters {
+		fpp, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bloom filter false positive probability(%v) of column(%v): %w", value, column, err)
+		}
+		opts = append(opts, parquet.WithBloomFilter(column, fpp))
+	}
+	return opts, nil
+}
+
+// inferenceFlags are the flags of the schema inference of the commands reading JSON files
+type inferenceFlags struct {
+	workers          int
+	sanitizeNames    bool
+	snakeCase        bool
+	flatten          bool
+	flattenSeparator string
+	flattenDepth     int
+	overrides        overrideFlags
+}
+
+func (f *inferenceFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&f.workers, "j", runtime.NumCPU(), "Number of parallel workers used to infer the parquet schema")
+	fs.BoolVar(&f.sanitizeNames, "sanitize-names", false, "Replace characters in column names that are not supported by Spark, Hive and Athena")
+	fs.BoolVar(&f.snakeCase, "snake-case", false, "Convert column names to snake_case")
+	fs.BoolVar(&f.flatten, "flatten", false, "Flatten nested objects into top level columns instead of skipping them")
+	fs.StringVar(&f.flattenSeparator, "flatten-separator", ".", "Separator of the keys of flattened nested objects")
+	fs.IntVar(&f.flattenDepth, "flatten-depth", 0, "Maximum depth of flattened nested objects, deeper objects are stored as JSON text (0 means no limit)")
+	fs.Var(&f.overrides, "override", "Force the type of a field instead of inferring it, in the format key=type[:required|optional] where key[] are the elements of the list key (can be repeated)")
+}
+
+func (f *inferenceFlags) configureReader(r *tfJson.Reader) {
+	if f.flatten {
+		r.SetFlattenNestedObjects(f.flattenSeparator, f.flattenDepth)
+		return
+	}
+	r.SetSkipNestedObjects(true) // TODO: remove when nested objects are supported
+}
+
+func (f *inferenceFlags) schemaBuilder() *parquet.SchemaBuilder {
+	sb := parquet.NewSchemaBuilder()
+	naming := parquet.ColumnNaming{
+		Sanitize:  f.sanitizeNames,
+		SnakeCase: f.snakeCase,
+	}
+	if f.flatten {
+		naming.Separator = f.flattenSeparator
+	}
+	sb.SetColumnNaming(naming)
+	sb.SetOverrides(f.overrides.overrides)
+	return sb
+}
+
+// infer infers the schema of the input files in parallel
+func (f *inferenceFlags) infer(ctx context.Context, sb *parquet.SchemaBuilder, filenames []string) error {
+	var parts []parquet.InferencePart
+	for _, filename := range filenames {
+		fileParts, err := parquet.FileParts(filename, f.workers, minInferencePartSize, f.configureReader)
+		if err != nil {
+			return fmt.Errorf("failed to open file(%v): %w", filename, err)
+		}
+		parts = append(parts, fileParts...)
+	}
+	return sb.UpdateSchemaParallel(ctx, parts, f.workers)
+}
+
+// options returns the description of the inference options in the footer metadata
+func (f *inferenceFlags) options() inferenceOptions {
+	return inferenceOptions{
+		SanitizeNames:    f.sanitizeNames,
+		SnakeCase:        f.snakeCase,
+		Flatten:          f.flatten,
+		FlattenSeparator: f.flattenSeparator,
+		FlattenDepth:     f.flattenDepth,
+		Overrides:        f.overrides.values,
+	}
+}
+
+// layoutFlags are the flags of the representation of the timestamps and the lists in the parquet schema
+type layoutFlags struct {
+	legacyLists     bool
+	timestampUnit   string
+	timestampUTC    bool
+	int96Timestamps bool
+}
+
+func (f *layoutFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.legacyLists, "legacy-lists", false, "Write lists in the two-level layout of the earlier versions (repeated elements without the list group) instead of the three-level layout of the parquet specification")
+	fs.StringVar(&f.timestampUnit, "timestamp-unit", "", "Unit of the timestamp columns, one of millis, micros or nanos (default is nanos for RFC3339 strings and millis for -override key=timestamp_millis)")
+	fs.BoolVar(&f.timestampUTC, "timestamp-utc", true, "Write the timestamps adjusted to UTC (isAdjustedToUTC), otherwise RFC3339 strings are written as their local date and time")
+	fs.BoolVar(&f.int96Timestamps, "int96-timestamps", false, "Write the timestamps as legacy INT96 values read by older versions of Hive, Impala and Spark")
+}
+
+func (f *layoutFlags) layout() (parquet.Layout, error) {
+	layout := parquet.Layout{
+		Timestamps: parquet.TimestampType{Local: !f.timestampUTC, INT96: f.int96Timestamps},
+	}
+	unit, err := parquet.ParseTimestampUnit(f.timestampUnit)
+	if err != nil {
+		return layout, fmt.Errorf("invalid timestamp unit: %w", err)
+	}
+	layout.Timestamps.Unit = unit
+	if f.legacyLists {
+		layout.Lists = parquet.ListLayoutLegacy
+	}
+	return layout, nil
+}
+
+const coerceUsage = "Comma separated rules that convert the values that do not match the type of their column: numeric_strings, numbers, bool_strings, stringify, null, all or strict (a value that cannot be converted fails the conversion)"
+
+// writerFlags are the flags of the encoding of the written files
+type writerFlags struct {
+	compression         string
+	columnCompression   columnFlags
+	dictionary          bool
+	columnDictionary    columnFlags
+	dictionaryPageSize  int64
+	columnEncoding      columnFlags
+	autoEncoding        bool
+	pageSize            int64
+	pageV2              bool
+	rowGroupSize        int64
+	columnWorkers       int
+	memoryLimit         int64
+	arrow               bool
+	statistics          bool
+	columnStatistics    columnFlags
+	maxStatisticsSize   int64
+	pageIndex           bool
+	bloomFilters        columnFlags
+	bloomFilterMaxBytes int64
+	coerce              string
+	meta                metadataFlags
+}
+
+func (f *writerFlags) register(fs *flag.FlagSet) {
+	f.columnCompression = make(columnFlags)
+	f.columnDictionary = make(columnFlags)
+	f.columnEncoding = make(columnFlags)
+	f.columnStatistics = make(columnFlags)
+	f.bloomFilters = make(columnFlags)
+	fs.StringVar(&f.compression, "compression", "uncompressed", "Compression of the output file in the format codec[:level], codec is one of uncompressed, snappy, gzip, zstd, brotli or lz4")
+	fs.Var(f.columnCompression, "column-compression", "Compression of a column in the format column=codec[:level] (can be repeated)")
+	fs.BoolVar(&f.dictionary, "dictionary", true, "Enable dictionary encoding of columns")
+	fs.Var(f.columnDictionary, "column-dictionary", "Enable or disable dictionary encoding of a column in the format column=true|false (can be repeated)")
+	fs.Int64Var(&f.dictionaryPageSize, "dictionary-page-size", 0, "Size limit of dictionary pages in bytes, larger dictionaries fall back to the column encoding (0 means library default)")
+	fs.Var(f.columnEncoding, "column-encoding", "Encoding of a column in the format column=encoding, encoding is one of plain, rle, delta_binary_packed, delta_byte_array, delta_length_byte_array or byte_stream_split (can be repeated)")
+	fs.BoolVar(&f.autoEncoding, "auto-encoding", true, "Use delta encoding for timestamp columns and byte stream split encoding for floating point columns")
+	fs.Int64Var(&f.pageSize, "page-size", 0, "Target size of data pages in bytes (0 means library default)")
+	fs.BoolVar(&f.pageV2, "page-v2", false, "Write data pages of version 2")
+	fs.IntVar(&f.columnWorkers, "column-workers", runtime.NumCPU(), "Number of columns of a row group encoded and compressed in parallel, the output does not depend on the number of workers")
+	fs.Int64Var(&f.memoryLimit, "memory-limit", 0, "Approximate limit of the memory of the buffered rows and row groups of an output file in bytes, the row group is written early when it is reached and the sorted rows are spilled (0 means no limit)")
+	fs.BoolVar(&f.arrow, "arrow", false, "Write the files through Arrow records with the Arrow writer of the parquet library, the Arrow schema is embedded in the files")
+	fs.Int64Var(&f.rowGroupSize, "row-group-size", 32<<20, "Target size of row groups in bytes, batches are collected in a row group until its encoded size reaches the target, the row group is buffered in memory until it is written (0 means a row group per batch)")
+	fs.BoolVar(&f.statistics, "statistics", true, "Write min/max statistics of columns")
+	fs.Var(f.columnStatistics, "column-statistics", "Enable or disable min/max statistics of a column in the format column=true|false (can be repeated)")
+	fs.Int64Var(&f.maxStatisticsSize, "max-statistics-size", 0, "Maximum size of min/max values in bytes, longer values are truncated (0 means library default)")
+	fs.BoolVar(&f.pageIndex, "page-index", false, "Write the column and offset indexes of pages")
+	fs.Var(f.bloomFilters, "bloom-filter", "Write bloom filters of a column with the target false positive probability in the format column=fpp, e.g. id=0.01 (can be repeated)")
+	fs.Int64Var(&f.bloomFilterMaxBytes, "bloom-filter-max-bytes", 0, "Maximum size of a bloom filter in bytes (0 means library default)")
+	fs.Var(&f.meta, "meta", "Add an entry to the footer metadata in the format key=value (can be repeated)")
+	fs.StringVar(&f.coerce, "coerce", "strict", coerceUsage)
+}
+
+// options returns the writer options of the flags and the layout, the footer metadata entries are
+// added by the commands
+func (f *writerFlags) options(layout parquet.Layout) ([]parquet.WriterOption, error) {
+	if f.arrow && layout.Lists == parquet.ListLayoutLegacy {
+		return nil, errors.New("the legacy list layout is not supported by the Arrow writer")
+	}
+	codec, level, err := parquet.ParseCompression(f.compression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compression: %w", err)
+	}
+	coercion, err := parquet.ParseCoercion(f.coerce)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coercion: %w", err)
+	}
+	opts := []parquet.WriterOption{
+		parquet.WithCompression(codec, level),
+		parquet.WithDictionary(f.dictionary),
+		parquet.WithDictionaryPageSizeLimit(f.dictionaryPageSize),
+		parquet.WithAutoEncoding(f.autoEncoding),
+		parquet.WithDataPageSize(f.pageSize),
+		parquet.WithRowGroupBytes(f.rowGroupSize),
+		parquet.WithColumnWorkers(f.columnWorkers),
+		parquet.WithMemoryLimit(f.memoryLimit),
+		parquet.WithStatistics(f.statistics),
+		parquet.WithMaxStatisticsSize(f.maxStatisticsSize),
+		parquet.WithPageIndex(f.pageIndex),
+		parquet.WithBloomFilterMaxBytes(f.bloomFilterMaxBytes),
+		parquet.WithArrowWriter(f.arrow),
+		parquet.WithCoercion(coercion),
+		parquet.WithListLayout(layout.Lists),
+		parquet.WithTimestampType(layout.Timestamps),
+	}
+	if f.pageV2 {
+		opts = append(opts, parquet.WithDataPageVersion(pqParquet.DataPageV2))
+	}
+	columnOptions, err := parseColumnOptions(f.columnCompression, f.columnDictionary, f.columnEncoding, f.columnStatistics, f.bloomFilters)
+	if err != nil {
+		return nil, fmt.Errorf("invalid column options: %w", err)
+	}
+	return append(opts, columnOptions...), nil
+}
+
+// encryptionFlags are the flags of the keys of the encrypted files, the keys of the written files are
+// only registered by the commands writing files
+type encryptionFlags struct {
+	keyFile         string
+	footerKey       string
+	columnKeys      columnFlags
+	plaintextFooter bool
+}
+
+func (f *encryptionFlags) register(fs *flag.FlagSet, write bool) {
+	fs.StringVar(&f.keyFile, "key-file", "", "Local file of the encryption keys, a JSON object of the key IDs and the base64 encoded AES keys of 16, 24 or 32 bytes")
+	if !write {
+		return
+	}
+	f.columnKeys = make(columnFlags)
+	fs.StringVar(&f.footerKey, "footer-key", "", "Encrypt the files with parquet modular encryption, the ID of the footer key in the -key-file. All columns are encrypted by the footer key unless -column-key is set")
+	fs.Var(f.columnKeys, "column-key", "Encrypt a column with a key of the -key-file in the format column=keyID, only these columns are encrypted (can be repeated)")
+	fs.BoolVar(&f.plaintextFooter, "plaintext-footer", false, "Write the footer of the encrypted files unencrypted (signed by the footer key), so the schema can be read without the keys")
+}
+
+// kms returns the keys of the key file, nil without a key file
+func (f *encryptionFlags) kms() (parquet.KMS, error) {
+	if f.keyFile == "" {
+		return nil, nil
+	}
+	keys, err := parquet.LoadKeyFile(f.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the encryption keys: %w", err)
+	}
+	return keys, nil
+}
+
+// options returns the writer options encrypting the written files with the keys of the KMS
+func (f *encryptionFlags) options(kms parquet.KMS) ([]parquet.WriterOption, error) {
+	if f.footerKey == "" {
+		if len(f.columnKeys) > 0 || f.plaintextFooter {
+			return nil, errors.New("the encryption options require the -footer-key")
+		}
+		return nil, nil
+	}
+	if kms == nil {
+		return nil, errors.New("the encryption keys are read from the -key-file")
+	}
+	return []parquet.WriterOption{parquet.WithEncryption(parquet.Encryption{
+		KMS:             kms,
+		FooterKeyID:     f.footerKey,
+		PlaintextFooter: f.plaintextFooter,
+		ColumnKeyIDs:    f.columnKeys,
+	})}, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+
+	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+func runInfer(fs *flag.FlagSet, args []string) int {
+	var verbose bool
+	var format string
+	var inference inferenceFlags
+	var layoutOpts layoutFlags
+	fs.BoolVar(&verbose, "v", false, "Enable verbose mode")
+	fs.StringVar(&format, "format", "text", "Format of the schema, text prints the parquet schema and json the description of the fields recorded in the footer metadata of the written files")
+	inference.register(fs)
+	layoutOpts.register(fs)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		return usageError(fs, "no input files")
+	}
+	if format != "text" && format != "json" {
+		return usageError(fs, "invalid format: %v", format)
+	}
+	layout, err := layoutOpts.layout()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	if err = setLogger(verbose); err != nil {
+		return fail("failed create logger: %v", err)
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	sb := inference.schemaBuilder()
+	if err = inference.infer(ctx, sb, fs.Args()); err != nil {
+		return fail("failed to infer parquet schema from JSON data: %v", err)
+	}
+	sc := sb.Schema()
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err = enc.Encode(sc.Describe()); err != nil {
+			return fail("failed to write schema: %v", err)
+		}
+		return exitOK
+	}
+	pqSc, err := sc.SchemaWithLayout(layout)
+	if err != nil {
+		return fail("failed to build parquet schema: %v", err)
+	}
+	pqSchema.PrintSchema(pqSc.Root(), os.Stdout, 2)
+	return exitOK
+}
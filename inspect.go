@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func runInspect(fs *flag.FlagSet, args []string) int {
+	var showMetadata bool
+	var encryption encryptionFlags
+	fs.BoolVar(&showMetadata, "metadata", true, "Print the entries of the footer metadata")
+	encryption.register(fs, false)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		return usageError(fs, "no parquet files")
+	}
+	kms, err := encryption.kms()
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err = inspectFiles(os.Stdout, fs.Args(), kms, showMetadata); err != nil {
+		return fail("%v", err)
+	}
+	return exitOK
+}
+
+// inspectFiles prints the description of the files separated by an empty line, it stops at the first error
+func inspectFiles(out io.Writer, paths []string, kms parquet.KMS, showMetadata bool) error {
+	for i, path := range paths {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		if err := inspectFile(out, path, kms, showMetadata); err != nil {
+			return fmt.Errorf("failed to inspect file(%v): %w", path, err)
+		}
+	}
+	return nil
+}
+
+func inspectFile(out io.Writer, path string, kms parquet.KMS, showMetadata bool) error {
+	f, err := parquet.OpenFile(path, kms)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	md := f.MetaData()
+	fmt.Fprintf(out, "File %v\n", path)
+	fmt.Fprintf(out, "Created by %v, format version %v\n", md.GetCreatedBy(), md.Version())
+	switch {
+	case md.IsSetEncryptionAlgorithm():
+		fmt.Fprintln(out, "Encrypted with a plaintext footer")
+	case md.FileDecryptor != nil:
+		fmt.Fprintln(out, "Encrypted with an encrypted footer")
+	}
+	fmt.Fprintf(out, "%v rows in %v row groups\n\n", md.NumRows, md.NumRowGroups())
+
+	fmt.Fprintf(out, "%-10s %14s %14s %14s\n", "Row group", "Rows", "Compressed", "Uncompressed")
+	for i := range md.NumRowGroups() {
+		rg := md.RowGroup(i)
+		fmt.Fprintf(out, "%-10d %14d %14d %14d\n", i, rg.NumRows(), rg.TotalCompressedSize(), rg.TotalByteSize())
+	}
+	fmt.Fprintln(out)
+	summary, err := parquet.NewSummary(md)
+	switch {
+	case err == nil:
+		printColumns(out, summary)
+	case md.IsSetEncryptionAlgorithm() && kms == nil:
+		// the metadata of the columns encrypted with a plaintext footer is only readable with the keys
+		fmt.Fprintf(out, "The sizes of the encrypted columns are read with the -key-file\n\n")
+	default:
+		return err
+	}
+
+	pqSchema.PrintSchema(md.Schema.Root(), out, 2)
+	if !showMetadata {
+		return nil
+	}
+	kv := md.KeyValueMetadata()
+	if kv.Len() == 0 {
+		return nil
+	}
+	fmt.Fprintln(out, "\nFooter metadata")
+	for i, key := range kv.Keys() {
+		fmt.Fprintf(out, "  %v = %v\n", key, kv.Values()[i])
+	}
+	return nil
+}
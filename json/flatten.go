@@ -0,0 +1,70 @@
+package json
+
+import (
+	"errors"
+	"fmt"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// ErrKeyCollision is returned when two values of a record are flattened to the same key,
+// e.g. {"a.b":1,"a":{"b":2}} with the separator "."
+var ErrKeyCollision = errors.New("flattened key collision")
+
+// Raw is a nested JSON value that is stored as JSON text
+type Raw string
+
+var rawEncoder = jsoniter.Config{
+	EscapeHTML:  true,
+	SortMapKeys: true,
+}.Froze()
+
+type flattenOptions struct {
+	separator string
+	maxDepth  int
+}
+
+func toRaw(value interface{}) (Raw, error) {
+	data, err := rawEncoder.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return Raw(data), nil
+}
+
+// flatten moves the values of nested objects to the top level, the keys are joined by the separator.
+// Objects nested deeper than maxDepth and arrays containing objects or arrays are stored as JSON text.
+// Values flattened to a key that is already in the output return ErrKeyCollision instead of replacing
+// one another.
+func (fo *flattenOptions) flatten(prefix string, depth int, obj map[string]interface{}, out NDJsonRecord) error {
+	for k, v := range obj {
+		key := k
+		if prefix != "" {
+			key = prefix + fo.separator + k
+		}
+		if v == nil {
+			continue
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			if fo.maxDepth <= 0 || depth < fo.maxDepth {
+				if err := fo.flatten(key, depth+1, nested, out); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if _, ok := out[key]; ok {
+			return fmt.Errorf("%w: %v", ErrKeyCollision, key)
+		}
+		if isNested(v) {
+			raw, err := toRaw(v)
+			if err != nil {
+				return err
+			}
+			out[key] = raw
+			continue
+		}
+		out[key] = v
+	}
+	return nil
+}
@@ -13,8 +13,10 @@ import (
 
 type Reader struct {
 	scanner *bufio.Scanner
+	closer  io.Closer
 
 	skipNestedObjects bool
+	flatten           *flattenOptions
 }
 
 type (
@@ -34,13 +36,53 @@ func NewFromFile(file string) (*Reader, error) {
 	if err != nil {
 		return nil, err
 	}
-	return New(f)
+	r, err := New(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewFromFileSection creates a reader of a section of the file, see SplitFile
+func NewFromFileSection(file string, section Section) (*Reader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	r, err := New(io.NewSectionReader(f, section.Offset, section.Length))
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// Close closes the underlying file of readers created by NewFromFile or NewFromFileSection
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
 }
 
 func (r *Reader) SetSkipNestedObjects(skip bool) {
 	r.skipNestedObjects = skip
 }
 
+// SetFlattenNestedObjects enables flattening of nested objects into top level keys joined by the separator,
+// e.g. {"a":{"b":{"c":1}}} becomes {"a.b.c":1}. Objects nested deeper than maxDepth levels are stored as
+// JSON text, a maxDepth of zero means no limit. Flattening takes precedence over skipping of nested objects.
+// Records with two values flattened to the same key, e.g. {"a.b":1,"a":{"b":2}}, are logged and skipped.
+func (r *Reader) SetFlattenNestedObjects(separator string, maxDepth int) {
+	r.flatten = &flattenOptions{
+		separator: separator,
+		maxDepth:  maxDepth,
+	}
+}
+
 func isNested(value interface{}) bool {
 	if value == nil {
 		return false
@@ -49,12 +91,13 @@ func isNested(value interface{}) bool {
 		return true
 	}
 	if reflect.TypeOf(value).Kind() == reflect.Slice {
-		array := value.([]interface{})
-		if len(array) > 0 {
-			if reflect.TypeOf(array[0]).Kind() == reflect.Slice ||
-				reflect.TypeOf(array[0]).Kind() == reflect.Map {
-				return true
+		// the type of the elements is the type of the first element that is not null
+		for _, e := range value.([]interface{}) {
+			if e == nil {
+				continue
 			}
+			kind := reflect.TypeOf(e).Kind()
+			return kind == reflect.Slice || kind == reflect.Map
 		}
 	}
 	return false
@@ -81,7 +124,17 @@ func (r *Reader) Read(ctx context.Context, onRead onRead) error {
 			tfLog.Logger().Errorf("error parsing data: %v", err)
 			continue
 		}
-		if r.skipNestedObjects {
+		if r.flatten != nil {
+			flattened := make(NDJsonRecord, len(jsonRecord))
+			if err = r.flatten.flatten("", 0, jsonRecord, flattened); err != nil {
+				tfLog.Logger().Errorf("error flattening data: %v", err)
+				continue
+			}
+			if len(flattened) == 0 {
+				continue
+			}
+			jsonRecord = flattened
+		} else if r.skipNestedObjects {
 			for k, v := range jsonRecord {
 				if v == nil || isNested(v) {
 					delete(jsonRecord, k)
@@ -94,5 +147,5 @@ func (r *Reader) Read(ctx context.Context, onRead onRead) error {
 		}
 		onRead(jsonRecord)
 	}
-	return nil
+	return r.scanner.Err()
 }
@@ -0,0 +1,63 @@
+package json
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+)
+
+// Section is a byte range of a file that starts at the beginning of a line and ends after a newline
+// or at the end of the file
+type Section struct {
+	Offset int64
+	Length int64
+}
+
+// SplitFile splits the file into at most n sections of similar size aligned to line boundaries.
+// Sections are not smaller than minSize unless the file itself is smaller.
+func SplitFile(file string, n int, minSize int64) ([]Section, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := st.Size()
+	if minSize > 0 && int64(n) > size/minSize {
+		n = int(size / minSize)
+	}
+	n = max(n, 1)
+
+	var sections []Section
+	var start int64
+	br := bufio.NewReader(f)
+	for i := 1; i < n; i++ {
+		boundary := size * int64(i) / int64(n)
+		if boundary <= start {
+			continue
+		}
+		if _, err = f.Seek(boundary, io.SeekStart); err != nil {
+			return nil, err
+		}
+		br.Reset(f)
+		// the line containing the boundary belongs to the current section
+		rest, errR := br.ReadBytes('\n')
+		if errors.Is(errR, io.EOF) {
+			break
+		}
+		if errR != nil {
+			return nil, errR
+		}
+		end := boundary + int64(len(rest))
+		sections = append(sections, Section{Offset: start, Length: end - start})
+		start = end
+	}
+	if start < size {
+		sections = append(sections, Section{Offset: start, Length: size - start})
+	}
+	return sections, nil
+}
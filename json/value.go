@@ -3,6 +3,8 @@ package json
 import (
 	"encoding/base64"
 	"encoding/json"
+	"math"
+	"strconv"
 	"time"
 )
 
@@ -72,3 +74,65 @@ func ToByteString(value interface{}) ([]byte, bool) {
 	}
 	return []byte(s), true
 }
+
+// CoerceToBool converts booleans and exactly the strings "true" and "false" to a bool, other spellings
+// (e.g. "1", "t" or "TRUE") are not converted
+func CoerceToBool(value interface{}) (bool, bool) {
+	if v, ok := ToBool(value); ok {
+		return v, true
+	}
+	switch s, _ := ToString(value); s {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// IntegralToInt64 converts integers and numbers without a fractional part (e.g. 2.0) to an int64
+func IntegralToInt64(value interface{}) (int64, bool) {
+	if v, ok := ToInt64(value); ok {
+		return v, true
+	}
+	f, ok := ToFloat64(value)
+	if !ok || f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(f), true
+}
+
+// CoerceToInt64 converts numbers without a fractional part and numeric strings to an int64
+func CoerceToInt64(value interface{}) (int64, bool) {
+	if s, ok := ToString(value); ok {
+		return IntegralToInt64(json.Number(s))
+	}
+	return IntegralToInt64(value)
+}
+
+// CoerceToFloat64 converts numbers and numeric strings to a float64
+func CoerceToFloat64(value interface{}) (float64, bool) {
+	if v, ok := ToFloat64(value); ok {
+		return v, true
+	}
+	s, ok := ToString(value)
+	if !ok {
+		return 0, false
+	}
+	return ToFloat64(json.Number(s))
+}
+
+// CoerceToString converts strings, numbers, booleans and JSON text to a string
+func CoerceToString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case Raw:
+		return string(v), true
+	}
+	return "", false
+}
@@ -2,48 +2,187 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
-	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
-	tfJson "github.com/thermofisher/json2parquet/json"
 	tfLog "github.com/thermofisher/json2parquet/log"
 	"github.com/thermofisher/json2parquet/parquet"
 	"go.uber.org/zap"
 )
 
+// exit codes of the commands
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command failed, e.g. an input file cannot be read
+	exitUsage   = 2 // invalid arguments
+	exitInvalid = 3 // validate found invalid records or diff-schema found differences
+)
+
+// command is a subcommand of the tool with its own flags
+type command struct {
+	name        string
+	args        string // positional arguments in the usage
+	summary     string // description in the list of the commands
+	description string // description in the usage of the command
+	// run registers the flags of the command, parses the arguments and runs the command
+	run func(fs *flag.FlagSet, args []string) int
+}
+
+var commands = []command{
+	{
+		name:        "convert",
+		args:        "<filename> [<filename>...]",
+		summary:     "Convert ndjson files to parquet files",
+		description: "Infers the parquet schema of the ndjson files and writes their records to parquet files, the schemas inferred from multiple files are merged.",
+		run:         runConvert,
+	},
+	{
+		name:        "infer",
+		args:        "<filename> [<filename>...]",
+		summary:     "Infer the parquet schema of ndjson files",
+		description: "Infers the parquet schema of the ndjson files and prints it without writing any file.",
+		run:         runInfer,
+	},
+	{
+		name:        "inspect",
+		args:        "<file.parquet> [<file.parquet>...]",
+		summary:     "Describe parquet files",
+		description: "Prints the schema, the row groups, the sizes of the columns and the footer metadata of the parquet files.",
+		run:         runInspect,
+	},
+	{
+		name:        "validate",
+		args:        "<filename> [<filename>...]",
+		summary:     "Check that the records of ndjson files can be converted",
+		description: "Converts the records of the ndjson files by the inferred schema (or by the schema of a parquet file written by json2parquet) without writing them and reports the records that cannot be converted. The exit code is 3 when a record is invalid.",
+		run:         runValidate,
+	},
+	{
+		name:        "cat",
+		args:        "<file.parquet> [<file.parquet>...]",
+		summary:     "Print the rows of parquet files as ndjson",
+		description: "Prints the rows of the parquet files written by json2parquet as ndjson records to stdout, the values are converted back to the JSON values they were written from.",
+		run:         runCat,
+	},
+	{
+		name:        "diff-schema",
+		args:        "<file.parquet> <file.parquet>",
+		summary:     "Compare the schemas of two parquet files",
+		description: "Prints the columns that are only in one of the parquet files or that differ in type or repetition. The exit code is 3 when the schemas differ.",
+		run:         runDiffSchema,
+	},
+	{
+		name:        "merge",
+		args:        "<file.parquet> [<file.parquet>...]",
+		summary:     "Merge parquet files into one file",
+		description: "Writes the rows of the parquet files written by json2parquet to a single file in the order of the files, the schemas of the files are merged like the schemas inferred from multiple ndjson files and the columns are named like in the first file.",
+		run:         runMerge,
+	},
+}
+
 func main() {
-	var verbose bool
-	var inferOnly bool
-	var batchSize uint
-	var output string
-
-	flag.BoolVar(&verbose, "v", false, "Enable verbose mode")
-	flag.BoolVar(&inferOnly, "i", false, "Infer the parquet schema from json data and exit")
-	flag.UintVar(&batchSize, "b", 1000, "Batch size of the stored JSON data before it is send to parquet writer to process. Also the parquet row group size.")
-	flag.StringVar(&output, "o", "out.parquet", "Specify the output file (default is out.parquet)")
-
-	flag.Parse()
-
-	if flag.NArg() < 1 {
-		fmt.Println("A simple conversion tool that reads a ndjson file and output a parquet file.")
-		fmt.Println()
-		fmt.Printf("Usage: %v [options] <filename>\n", os.Args[0])
-		fmt.Println("\nOptions:")
-		flag.PrintDefaults()
-		fmt.Println("\nPositional arguments:")
-		fmt.Println("  filename    Path to the input JSON file")
-		os.Exit(1)
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		if len(args) == 1 {
+			printUsage(os.Stdout)
+			return exitOK
+		}
+		cmd := findCommand(args[1])
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "unknown command: %v\n\n", args[1])
+			printUsage(os.Stderr)
+			return exitUsage
+		}
+		fs := cmd.flagSet()
+		fs.SetOutput(os.Stdout)
+		return cmd.run(fs, []string{"-h"})
 	}
+	if cmd := findCommand(args[0]); cmd != nil {
+		return cmd.run(cmd.flagSet(), args[1:])
+	}
+	// the options without a command are the options of convert like in the earlier versions
+	cmd := findCommand("convert")
+	return cmd.run(cmd.flagSet(), args)
+}
 
-	if batchSize == 0 {
-		log.Fatalln("batch size cannot be zero")
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
 	}
+	return nil
+}
+
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "A simple conversion tool that reads ndjson files and output a parquet file.")
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Usage: %v <command> [options] <arguments>\n", programName())
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-12s %v\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nThe options without a command are the options of convert, e.g. %v -o out.parquet data.ndjson.\n", programName())
+	fmt.Fprintf(out, "Run '%v help <command>' for the options of a command.\n", programName())
+	fmt.Fprintln(out, "\nExit codes: 0 success, 1 failure, 2 invalid arguments, 3 invalid records (validate) or different schemas (diff-schema)")
+}
+
+func (c *command) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %v %v [options] %v\n\n%v\n\nOptions:\n", programName(), c.name, c.args, c.description)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the arguments of a command, false with the exit code when the command does not run
+// (the usage was requested or the flags are invalid)
+func parseArgs(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		// the flag set prints the error and the usage
+		return exitUsage, false
+	}
+	return exitOK, true
+}
 
+// usageError prints the error of the arguments of a command and returns the exit code of invalid usage
+func usageError(fs *flag.FlagSet, format string, args ...interface{}) int {
+	fmt.Fprintf(fs.Output(), "%v: %v\n", fs.Name(), fmt.Sprintf(format, args...))
+	fmt.Fprintf(fs.Output(), "Run '%v help %v' for usage.\n", programName(), fs.Name())
+	return exitUsage
+}
+
+// fail logs the error of a command and returns the exit code of failures
+func fail(format string, args ...interface{}) int {
+	log.Printf(format, args...)
+	return exitFailure
+}
+
+func setLogger(verbose bool) error {
 	var logger *zap.Logger
 	var err error
 	if verbose {
@@ -52,78 +191,54 @@ func main() {
 		logger, err = zap.NewProduction()
 	}
 	if err != nil {
-		log.Fatalf("failed create logger: %v", err)
+		return err
 	}
 	tfLog.SetLogger(logger.Sugar())
+	return nil
+}
 
-	filename := flag.Arg(0)
-
+// signalContext returns a context canceled when the process receives SIGTERM or SIGINT
+func signalContext() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Run a goroutine that listens for signals and cancels the context when received
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 	go func() {
-		sig := <-sigChan
-		tfLog.Logger().Debugf("received signal %v\n", sig)
-		cancel()
-	}()
-
-	reader, err := tfJson.NewFromFile(filename)
-	if err != nil {
-		log.Fatalf("failed to open file(%v): %v", filename, err)
-	}
-	reader.SetSkipNestedObjects(true) // TODO: remove when nested objects are supported
-
-	fmt.Printf("Infering parquet schema\n\n")
-	sb := parquet.NewSchemaBuilder()
-	err = reader.Read(ctx, func(data tfJson.NDJsonRecord) {
-		errU := sb.UpdateSchema(data)
-		if errU != nil {
-			tfLog.Logger().Errorf("failed to create schema: %v", errU)
+		select {
+		case sig := <-sigChan:
+			tfLog.Logger().Debugf("received signal %v\n", sig)
 			cancel()
+		case <-ctx.Done():
 		}
-	})
-	if err != nil {
-		log.Fatalf("failed to infer parquet schema from JSON data: %v", err)
-	}
+		signal.Stop(sigChan)
+	}()
+	return ctx, cancel
+}
 
-	sc := sb.Schema()
-	sc2, err := sc.Schema()
-	if err != nil {
-		log.Fatalf("failed to build parquet schema: %v", err)
-	}
-	pqSchema.PrintSchema(sc2.Root(), os.Stdout, 2)
-	fmt.Println()
+func printSummary(out io.Writer, summary *parquet.Summary) {
+	fmt.Fprintf(out, "Written %v rows in %v row groups\n\n", summary.Rows, summary.RowGroups)
+	printColumns(out, summary)
+}
 
-	if inferOnly {
-		os.Exit(0)
+// printColumns prints the sizes of the columns of the summary
+func printColumns(out io.Writer, summary *parquet.Summary) {
+	fmt.Fprintf(out, "%-40s %-12s %14s %14s %8s\n", "Column", "Compression", "Compressed", "Uncompressed", "Ratio")
+	for _, c := range summary.Columns {
+		fmt.Fprintf(out, "%-40s %-12s %14d %14d %8.2f\n", c.Path, c.Compression, c.CompressedBytes, c.UncompressedBytes, c.Ratio())
 	}
-
-	fmt.Printf("Reading JSON data and writing data to %v\n\n", output)
-	reader, err = tfJson.NewFromFile(filename)
-	if err != nil {
-		log.Fatalf("failed to open file(%v): %v", filename, err)
+	fmt.Fprintf(out, "%-40s %-12s %14d %14d %8.2f\n\n", "total", "", summary.CompressedBytes, summary.UncompressedBytes, summary.Ratio())
+	var nulled bool
+	for _, c := range summary.Columns {
+		if c.CoercedNulls > 0 {
+			fmt.Fprintf(out, "Column %v: %v values could not be converted and were written as null\n", c.Path, c.CoercedNulls)
+			nulled = true
+		}
 	}
-	reader.SetSkipNestedObjects(true)
-
-	wr, err := parquet.NewWriter(output, batchSize, sc)
-	if err != nil {
-		log.Fatalf("failed to create parquet file write: %v", err)
+	if nulled {
+		fmt.Fprintln(out)
 	}
-	defer wr.Close()
-
-	err = reader.Read(ctx, func(data tfJson.NDJsonRecord) {
-		errW := wr.Write(data)
-		if errW != nil {
-			tfLog.Logger().Errorf("failed to write data: %v", errW)
-			cancel()
-		}
-	})
-	if err != nil {
-		wr.Close()
-		log.Fatalf("failed to infer parquet schema from JSON data: %v", err) //nolint:gocritic
+	if summary.PeakBufferedBytes > 0 {
+		fmt.Fprintf(out, "Peak buffered data: %v bytes\n\n", summary.PeakBufferedBytes)
 	}
-
-	fmt.Println("Success!")
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func runMerge(fs *flag.FlagSet, args []string) int {
+	var verbose bool
+	var batchSize uint
+	var output string
+	var layoutOpts layoutFlags
+	var writer writerFlags
+	var encryption encryptionFlags
+	fs.BoolVar(&verbose, "v", false, "Enable verbose mode")
+	fs.UintVar(&batchSize, "b", 1000, "Batch size of the rows before they are send to parquet writer to process")
+	fs.StringVar(&output, "o", "out.parquet", "Specify the output file, - writes to stdout. The output can be one of the merged files, it is replaced when the merge succeeds")
+	layoutOpts.register(fs)
+	writer.register(fs)
+	encryption.register(fs, true)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		return usageError(fs, "no parquet files")
+	}
+	if batchSize == 0 {
+		return usageError(fs, "batch size cannot be zero")
+	}
+	layout, err := layoutOpts.layout()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	writerOptions, err := writer.options(layout)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	kms, err := encryption.kms()
+	if err != nil {
+		return fail("%v", err)
+	}
+	encryptionOptions, err := encryption.options(kms)
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	writerOptions = append(writerOptions, encryptionOptions...)
+	if err = setLogger(verbose); err != nil {
+		return fail("failed create logger: %v", err)
+	}
+
+	// the messages go to stderr when the parquet data is written to stdout
+	console := os.Stdout
+	if output == "-" {
+		console = os.Stderr
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	readers := make([]*parquet.Reader, 0, fs.NArg())
+	defer func() {
+		for _, reader := range readers {
+			_ = reader.Close()
+		}
+	}()
+	// the fields are typed like in the merged files and the columns are named like in the first file
+	overrides := make(parquet.Overrides)
+	for _, path := range fs.Args() {
+		reader, err := parquet.OpenReader(path, kms)
+		if err != nil {
+			return fail("failed to open file(%v): %v", path, err)
+		}
+		readers = append(readers, reader)
+		for key, override := range reader.Schema().Overrides() {
+			if _, ok := overrides[key]; !ok {
+				overrides[key] = override
+			}
+		}
+	}
+	naming, err := columnNaming(readers[0].Metadata())
+	if err != nil {
+		return fail("invalid inference options of file(%v): %v", fs.Arg(0), err)
+	}
+	sb := parquet.NewSchemaBuilder()
+	sb.SetColumnNaming(naming)
+	sb.SetOverrides(overrides)
+	for _, reader := range readers {
+		if err = sb.Merge(reader.Schema()); err != nil {
+			return fail("incompatible schema: %v", err)
+		}
+	}
+	sc := sb.Schema()
+	pqSc, err := sc.SchemaWithLayout(layout)
+	if err != nil {
+		return fail("failed to build parquet schema: %v", err)
+	}
+	pqSchema.PrintSchema(pqSc.Root(), console, 2)
+	fmt.Fprintln(console)
+
+	fmt.Fprintf(console, "Merging %v files to %v\n\n", len(readers), output)
+	writerOptions = append(writerOptions, mergeMetadataOptions(readers, writer.meta)...)
+	out := outputConfig{path: output, memoryLimit: writer.memoryLimit, arrow: writer.arrow}
+	wr, err := out.open(batchSize, sc, writerOptions)
+	if err != nil {
+		return fail("failed to create parquet file write: %v", err)
+	}
+	var sources []source
+	for i, reader := range readers {
+		path := fs.Arg(i)
+		if err = reader.Read(ctx, wr.Write); err != nil {
+			wr.Abort()
+			return fail("failed to merge file(%v): %v", path, err)
+		}
+		fileSources, err := writtenSources(reader.Metadata())
+		if err != nil {
+			wr.Abort()
+			return fail("invalid sources of file(%v): %v", path, err)
+		}
+		sources = append(sources, fileSources...)
+	}
+	if err = appendSourcesMetadata(wr, sources); err != nil {
+		wr.Abort()
+		return fail("failed to write footer metadata: %v", err)
+	}
+	if err = wr.Close(); err != nil {
+		return fail("failed to write parquet file: %v", err)
+	}
+	if err = out.finish(console, wr); err != nil {
+		return fail("failed to write manifest: %v", err)
+	}
+
+	fmt.Fprintln(console, "Success!")
+	return exitOK
+}
+
+// mergeMetadataOptions returns the writer options adding the tool version and the user entries to the
+// footer metadata, the inference options are kept when all the merged files were inferred with the same
+// options
+func mergeMetadataOptions(readers []*parquet.Reader, entries metadataFlags) []parquet.WriterOption {
+	opts := []parquet.WriterOption{parquet.WithKeyValueMetadata(versionMetadataKey, toolVersion())}
+	inference := readers[0].Metadata().FindValue(inferenceMetadataKey)
+	for _, reader := range readers[1:] {
+		if value := reader.Metadata().FindValue(inferenceMetadataKey); inference != nil && (value == nil || *value != *inference) {
+			inference = nil
+		}
+	}
+	if inference != nil {
+		opts = append(opts, parquet.WithKeyValueMetadata(inferenceMetadataKey, *inference))
+	}
+	for _, e := range entries {
+		opts = append(opts, parquet.WithKeyValueMetadata(e.key, e.value))
+	}
+	return opts
+}
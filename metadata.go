@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+// keys of the footer metadata entries written by the tool
+const (
+	versionMetadataKey   = "json2parquet.version"
+	sourcesMetadataKey   = "json2parquet.sources"
+	inferenceMetadataKey = "json2parquet.inference"
+)
+
+// version is set at build time by -ldflags "-X main.version=..."
+var version string
+
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// source describes an input file in the footer metadata
+type source struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Rows   int64  `json:"rows"`
+}
+
+// inferenceOptions describes the options the schema was inferred with in the footer metadata
+type inferenceOptions struct {
+	SanitizeNames    bool     `json:"sanitize_names"`
+	SnakeCase        bool     `json:"snake_case"`
+	Flatten          bool     `json:"flatten"`
+	FlattenSeparator string   `json:"flatten_separator,omitempty"`
+	FlattenDepth     int      `json:"flatten_depth,omitempty"`
+	Overrides        []string `json:"overrides,omitempty"`
+}
+
+// metadataOptions returns the writer options adding the tool version, the inference options
+// and the user entries to the footer metadata
+func metadataOptions(inference inferenceOptions, entries metadataFlags) ([]parquet.WriterOption, error) {
+	data, err := json.Marshal(inference)
+	if err != nil {
+		return nil, err
+	}
+	opts := []parquet.WriterOption{
+		parquet.WithKeyValueMetadata(versionMetadataKey, toolVersion()),
+		parquet.WithKeyValueMetadata(inferenceMetadataKey, string(data)),
+	}
+	for _, e := range entries {
+		opts = append(opts, parquet.WithKeyValueMetadata(e.key, e.value))
+	}
+	return opts, nil
+}
+
+// readSource passes the records of the input file to the write function, the checksum of the file
+// is computed while it is read
+func readSource(ctx context.Context, filename string, configure func(*tfJson.Reader), write func(tfJson.NDJsonRecord)) (source, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return source{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	reader, err := tfJson.New(io.TeeReader(f, h))
+	if err != nil {
+		return source{}, err
+	}
+	configure(reader)
+	s := source{File: filename}
+	err = reader.Read(ctx, func(data tfJson.NDJsonRecord) {
+		s.Rows++
+		write(data)
+	})
+	if err != nil {
+		return source{}, err
+	}
+	// the reader stops at the last record, the rest of the file is hashed as well
+	if _, err = io.Copy(h, f); err != nil {
+		return source{}, err
+	}
+	s.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return s, nil
+}
+
+// scanSources reads the input files without writing their records and returns their sources
+func scanSources(ctx context.Context, filenames []string, configure func(*tfJson.Reader)) ([]source, error) {
+	sources := make([]source, 0, len(filenames))
+	for _, filename := range filenames {
+		src, err := readSource(ctx, filename, configure, func(tfJson.NDJsonRecord) {})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file(%v): %w", filename, err)
+		}
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+func appendSourcesMetadata(wr recordWriter, sources []source) error {
+	data, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+	return wr.AppendKeyValueMetadata(sourcesMetadataKey, string(data))
+}
+
+// writtenSources returns the sources recorded in the footer metadata of a file written by the tool
+func writtenSources(kv metadata.KeyValueMetadata) ([]source, error) {
+	var sources []source
+	if value := kv.FindValue(sourcesMetadataKey); value != nil {
+		if err := json.Unmarshal([]byte(*value), &sources); err != nil {
+			return nil, err
+		}
+	}
+	return sources, nil
+}
+
+// columnNaming returns the column naming recorded in the inference options of a file written by the tool
+func columnNaming(kv metadata.KeyValueMetadata) (parquet.ColumnNaming, error) {
+	var inference inferenceOptions
+	if value := kv.FindValue(inferenceMetadataKey); value != nil {
+		if err := json.Unmarshal([]byte(*value), &inference); err != nil {
+			return parquet.ColumnNaming{}, err
+		}
+	}
+	naming := parquet.ColumnNaming{Sanitize: inference.SanitizeNames, SnakeCase: inference.SnakeCase}
+	if inference.Flatten {
+		naming.Separator = inference.FlattenSeparator
+	}
+	return naming, nil
+}
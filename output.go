@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+// recordWriter is the output of the converted records
+type recordWriter = parquet.RecordWriter
+
+// fileWriter is the writer of a single file, the Writer or the ArrowWriter
+type fileWriter interface {
+	Summary() (*parquet.Summary, error)
+}
+
+// outputConfig configures the files the records are written to
+type outputConfig struct {
+	path     string // file name template when the output is split
+	maxRows  int64
+	maxBytes int64
+	manifest string
+
+	// partitioned output, path is the base directory of the partitions
+	partitionBy          []parquet.PartitionColumn
+	partitionFile        string // file name template of the files of a partition
+	keepPartitionColumns bool
+	maxOpenPartitions    int
+
+	// sorted output
+	sortBy         []parquet.SortColumn
+	sortRowGroups  bool // sort each row group instead of the whole output
+	sortBufferRows int
+	sortTempDir    string
+
+	memoryLimit int64 // limit of the rows buffered by the sort, see parquet.WithMemoryLimit
+
+	arrow bool // the files are written by the ArrowWriter
+}
+
+func (o outputConfig) isStdout() bool {
+	return o.path == "-"
+}
+
+func (o outputConfig) isSplit() bool {
+	return o.maxRows > 0 || o.maxBytes > 0
+}
+
+func (o outputConfig) isPartitioned() bool {
+	return len(o.partitionBy) > 0
+}
+
+// manifestPath returns the path of the manifest, empty if no manifest is written
+func (o outputConfig) manifestPath() string {
+	if o.manifest == "" && o.isPartitioned() {
+		return filepath.Join(o.path, "manifest.json")
+	}
+	if o.manifest == "" && o.isSplit() {
+		return filepath.Join(filepath.Dir(o.path), "manifest.json")
+	}
+	return o.manifest
+}
+
+func (o outputConfig) open(batchSize uint, sc *parquet.Schema, opts []parquet.WriterOption) (recordWriter, error) {
+	if len(o.sortBy) == 0 {
+		return o.openFiles(batchSize, sc, opts)
+	}
+	opts = append(opts, parquet.WithSortingColumns(o.sortBy...))
+	if o.sortRowGroups {
+		return o.openFiles(batchSize, sc, append(opts, parquet.WithSortedRowGroups(o.sortBufferRows)))
+	}
+	wr, err := o.openFiles(batchSize, sc, opts)
+	if err != nil {
+		return nil, err
+	}
+	sw, err := parquet.NewSortingWriter(wr, sc, o.sortBy, o.sortBufferRows, o.sortTempDir)
+	if err != nil {
+		wr.Abort()
+		return nil, err
+	}
+	sw.SetMemoryLimit(o.memoryLimit)
+	return sw, nil
+}
+
+func (o outputConfig) openFiles(batchSize uint, sc *parquet.Schema, opts []parquet.WriterOption) (recordWriter, error) {
+	if o.isPartitioned() && !o.isStdout() {
+		return parquet.NewPartitionedWriter(o.path, o.partitionFile, o.partitionBy, o.keepPartitionColumns,
+			o.maxOpenPartitions, o.maxRows, o.maxBytes, batchSize, sc, opts...)
+	}
+	if !o.isStdout() {
+		return parquet.NewRollingWriter(o.path, o.maxRows, o.maxBytes, batchSize, sc, opts...)
+	}
+	if o.isSplit() || o.isPartitioned() || o.manifest != "" {
+		return nil, errors.New("the output written to stdout cannot be split into files, partitioned or described by a manifest")
+	}
+	if o.arrow {
+		return parquet.NewArrowWriterTo(os.Stdout, batchSize, sc, opts...)
+	}
+	return parquet.NewWriterTo(os.Stdout, batchSize, sc, opts...)
+}
+
+// finish prints the summaries of the written files and writes the manifest
+func (o outputConfig) finish(console io.Writer, wr recordWriter) error {
+	if sw, ok := wr.(*parquet.SortingWriter); ok {
+		fmt.Fprintf(console, "Peak sort buffer: %v bytes\n\n", sw.PeakBufferedBytes())
+		wr = sw.Unwrap()
+	}
+	if w, ok := wr.(fileWriter); ok {
+		if summary, err := w.Summary(); err == nil {
+			printSummary(console, summary)
+		}
+		return nil
+	}
+	manifest := wr.(interface{ Manifest() *parquet.Manifest }).Manifest()
+	for _, f := range manifest.Files {
+		if len(manifest.Files) > 1 || o.isPartitioned() {
+			fmt.Fprintf(console, "File %v\n", f.Path)
+		}
+		if f.Summary != nil {
+			printSummary(console, f.Summary)
+		}
+	}
+	path := o.manifestPath()
+	if path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, data, 0o644); err != nil { //nolint:gosec
+		return err
+	}
+	fmt.Fprintf(console, "Written %v files described by manifest %v\n\n", len(manifest.Files), path)
+	return nil
+}
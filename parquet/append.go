@@ -0,0 +1,174 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+// size of the footer length and the magic bytes at the end of a parquet file
+const footerTrailerSize = 8
+
+// magic bytes at the start and at the end of a parquet file with a plaintext footer
+var magicBytes = []byte("PAR1")
+
+// CheckAppend returns an error wrapping ErrOpNotSupported if the rows of the parquet schema cannot be appended
+// to the file in place by AppendFile: the file is encrypted, it has page indexes or its parquet schema differs
+func (r *Reader) CheckAppend(sc *schema.Schema) error {
+	md := r.file.MetaData()
+	if err := checkAppendable(md); err != nil {
+		return err
+	}
+	if !md.Schema.Equals(sc) {
+		return fmt.Errorf("%w: appended rows of another parquet schema", ErrOpNotSupported)
+	}
+	return nil
+}
+
+// checkAppendable returns an error if the row groups of the file cannot be moved to other offsets: the
+// encrypted data is bound to its row group and the page locations of a page index are file offsets
+func checkAppendable(md *metadata.FileMetaData) error {
+	if md.FileDecryptor != nil || md.IsSetEncryptionAlgorithm() {
+		return fmt.Errorf("%w: encrypted file appended in place", ErrOpNotSupported)
+	}
+	for _, rg := range md.RowGroups {
+		for _, cc := range rg.Columns {
+			if cc.IsSetCryptoMetadata() {
+				return fmt.Errorf("%w: encrypted file appended in place", ErrOpNotSupported)
+			}
+			if cc.IsSetColumnIndexOffset() || cc.IsSetOffsetIndexOffset() {
+				return fmt.Errorf("%w: file with page index appended in place", ErrOpNotSupported)
+			}
+		}
+	}
+	return nil
+}
+
+// AppendFile appends the row groups of the parquet file src to the parquet file at path in place: the data of
+// src is copied after the row groups of the file and the footer of the file is replaced by the footer of src
+// listing the row groups of both files, so the rows of the file are not rewritten. The footer metadata of src
+// replaces the footer metadata of the file. The files must have the same parquet schema and can be neither
+// encrypted nor have page indexes (see Reader.CheckAppend). When the data cannot be appended the file is
+// restored to its original content.
+func AppendFile(path, src string) (err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if errC := f.Close(); errC != nil && err == nil {
+			err = fmt.Errorf("failed to close parquet file: %w", errC)
+		}
+	}()
+	reader, err := file.NewParquetReader(f)
+	if err != nil {
+		return fmt.Errorf("failed to read file(%v): %w", path, err)
+	}
+	md := reader.MetaData()
+	srcReader, err := file.OpenParquetFile(src, false)
+	if err != nil {
+		return fmt.Errorf("failed to read file(%v): %w", src, err)
+	}
+	defer srcReader.Close()
+	srcMd := srcReader.MetaData()
+	if err = errors.Join(checkAppendable(md), checkAppendable(srcMd)); err != nil {
+		return err
+	}
+	if !md.Schema.Equals(srcMd.Schema) {
+		return fmt.Errorf("%w: appended row groups of another parquet schema", ErrOpNotSupported)
+	}
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	footerOffset := size - footerTrailerSize - int64(md.Size())
+	footer := make([]byte, size-footerOffset)
+	if _, err = f.ReadAt(footer, footerOffset); err != nil {
+		return err
+	}
+	if err = appendRowGroups(f, footerOffset, md, src, srcMd); err != nil {
+		// the row groups of the file are not changed, the original footer makes the file complete again
+		if _, errW := f.WriteAt(footer, footerOffset); errW == nil {
+			_ = f.Truncate(size)
+		}
+		return err
+	}
+	return nil
+}
+
+// appendRowGroups writes the data of the row groups of src at the footer offset of the file followed by the
+// footer of src with the row groups of both files
+func appendRowGroups(f *os.File, footerOffset int64, md *metadata.FileMetaData, src string, srcMd *metadata.FileMetaData) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	srcSize, err := in.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	// the data of src starts after the magic bytes
+	dataOffset := int64(len(magicBytes))
+	dataSize := srcSize - footerTrailerSize - int64(srcMd.Size()) - dataOffset
+	if _, err = f.Seek(footerOffset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, io.NewSectionReader(in, dataOffset, dataSize)); err != nil {
+		return fmt.Errorf("failed to copy row groups: %w", err)
+	}
+
+	// the row groups of src are moved by the data of the file before them
+	shift := footerOffset - dataOffset
+	shiftOffset := func(offset *int64) {
+		if offset != nil {
+			*offset += shift
+		}
+	}
+	for i, rg := range srcMd.RowGroups {
+		shiftOffset(rg.FileOffset)
+		for _, cc := range rg.Columns {
+			cc.FileOffset += shift
+			if cc.MetaData == nil {
+				continue
+			}
+			cc.MetaData.DataPageOffset += shift
+			shiftOffset(cc.MetaData.IndexPageOffset)
+			shiftOffset(cc.MetaData.DictionaryPageOffset)
+			shiftOffset(cc.MetaData.BloomFilterOffset)
+		}
+		ordinal := int16(len(md.RowGroups) + i)
+		rg.Ordinal = &ordinal
+	}
+	srcMd.RowGroups = append(md.RowGroups, srcMd.RowGroups...)
+	srcMd.NumRows += md.NumRows
+
+	var buf bytes.Buffer
+	if _, err = srcMd.WriteTo(&buf, nil); err != nil {
+		return fmt.Errorf("failed to serialize footer: %w", err)
+	}
+	footerLen := uint32(buf.Len())
+	if err = binary.Write(&buf, binary.LittleEndian, footerLen); err != nil {
+		return err
+	}
+	buf.Write(magicBytes)
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write footer: %w", err)
+	}
+	end, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(end); err != nil {
+		return err
+	}
+	return f.Sync()
+}
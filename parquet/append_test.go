@@ -0,0 +1,102 @@
+package parquet_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func writeReaderRecords(t *testing.T, path string, sc *parquet.Schema, opts ...parquet.WriterOption) {
+	wr, err := parquet.NewWriter(path, 10, sc, opts...)
+	require.NoError(t, err)
+	for _, record := range testReaderRecords {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.Close())
+}
+
+func TestAppendFile(t *testing.T) {
+	sc := testReaderSchema(t)
+	pqSc, err := sc.Schema()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path, src := filepath.Join(dir, "out.parquet"), filepath.Join(dir, "appended.parquet")
+	writeReaderRecords(t, path, sc, parquet.WithBloomFilter("user_name", 0.01), parquet.WithKeyValueMetadata("key", "file"))
+	writeReaderRecords(t, src, sc, parquet.WithBloomFilter("user_name", 0.01), parquet.WithKeyValueMetadata("key", "appended"))
+
+	reader, err := parquet.OpenReader(path, nil)
+	require.NoError(t, err)
+	require.NoError(t, reader.CheckAppend(pqSc))
+	require.NoError(t, reader.Close())
+	require.NoError(t, parquet.AppendFile(path, src))
+
+	reader, err = parquet.OpenReader(path, nil)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.EqualValues(t, 2*len(testReaderRecords), reader.NumRows())
+	var records []map[string]interface{}
+	require.NoError(t, reader.Read(context.Background(), func(data map[string]interface{}) error {
+		records = append(records, data)
+		return nil
+	}))
+	require.Equal(t, append(testReaderExpected, testReaderExpected...), records)
+	// the footer metadata is the footer metadata of the appended file
+	require.Equal(t, "appended", *reader.Metadata().FindValue("key"))
+
+	// the bloom filters of the appended row groups are moved with them
+	pqReader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer pqReader.Close()
+	require.Equal(t, 2, pqReader.NumRowGroups())
+	user := pqReader.MetaData().Schema.ColumnIndexByName("user_name")
+	for i := range pqReader.NumRowGroups() {
+		rgFilters, err := pqReader.GetBloomFilterReader().RowGroup(i)
+		require.NoError(t, err)
+		filter, err := rgFilters.GetColumnBloomFilter(user)
+		require.NoError(t, err)
+		userFilter := metadata.TypedBloomFilter[pq.ByteArray]{BloomFilter: filter}
+		require.True(t, userFilter.Check(pq.ByteArray("Alice!")))
+	}
+}
+
+func TestAppendFileNotSupported(t *testing.T) {
+	sc := testReaderSchema(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.parquet")
+	writeReaderRecords(t, path, sc)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	// the page locations of the page index are file offsets
+	src := filepath.Join(dir, "page-index.parquet")
+	writeReaderRecords(t, src, sc, parquet.WithPageIndex(true))
+	require.ErrorIs(t, parquet.AppendFile(path, src), parquet.ErrOpNotSupported)
+
+	src = filepath.Join(dir, "encrypted.parquet")
+	writeReaderRecords(t, src, sc, parquet.WithEncryption(parquet.Encryption{
+		KMS: testKMS, FooterKeyID: "footer", PlaintextFooter: true,
+	}))
+	require.ErrorIs(t, parquet.AppendFile(path, src), parquet.ErrOpNotSupported)
+
+	src = filepath.Join(dir, "timestamps.parquet")
+	writeReaderRecords(t, src, sc, parquet.WithTimestampType(parquet.TimestampType{Unit: parquet.TimestampUnitMicros}))
+	require.ErrorIs(t, parquet.AppendFile(path, src), parquet.ErrOpNotSupported)
+	reader, err := parquet.OpenReader(path, nil)
+	require.NoError(t, err)
+	defer reader.Close()
+	layoutSc, err := sc.SchemaWithLayout(parquet.Layout{Timestamps: parquet.TimestampType{Unit: parquet.TimestampUnitMicros}})
+	require.NoError(t, err)
+	require.ErrorIs(t, reader.CheckAppend(layoutSc), parquet.ErrOpNotSupported)
+
+	// the file is not changed
+	appended, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, data, appended)
+}
@@ -0,0 +1,616 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/extensions"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/thermofisher/json2parquet/log"
+)
+
+// sortedFields returns the top level fields in the order of the parquet schema
+func (s *Schema) sortedFields() []Node {
+	fields := make([]Node, 0, len(s.fields))
+	for _, node := range s.fields {
+		fields = append(fields, node)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].GetName() < fields[j].GetName()
+	})
+	return fields
+}
+
+// ArrowSchema returns the schema of the Arrow records of the rows, the fields are in the order of the
+// parquet schema. Timestamps are in UTC, JSON values use the JSON extension type and lists have
+// nullable elements like the three-level lists of the parquet schema.
+func (s *Schema) ArrowSchema() (*arrow.Schema, error) {
+	return s.ArrowSchemaWithLayout(Layout{})
+}
+
+// ArrowSchemaWithLayout returns the schema of the Arrow records with the timestamps in the layout, the
+// legacy list layout is not supported. INT96 timestamps are nanoseconds in the records.
+func (s *Schema) ArrowSchemaWithLayout(layout Layout) (*arrow.Schema, error) {
+	if layout.Lists == ListLayoutLegacy {
+		return nil, fmt.Errorf("%w: legacy list layout of Arrow records", ErrOpNotSupported)
+	}
+	nodes := s.sortedFields()
+	fields := make([]arrow.Field, len(nodes))
+	for i, node := range nodes {
+		field, err := arrowField(node, layout.Timestamps)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = field
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+func arrowField(node Node, timestamps TimestampType) (arrow.Field, error) {
+	nullable := node.GetRepetition() == parquet.Repetitions.Optional
+	if ln, ok := node.(*ListNode); ok {
+		element, err := arrowField(ln.Element(), timestamps)
+		if err != nil {
+			return arrow.Field{}, err
+		}
+		element.Nullable = true
+		return arrow.Field{Name: node.GetName(), Type: arrow.ListOfField(element), Nullable: nullable}, nil
+	}
+	typ, err := arrowType(node, timestamps)
+	if err != nil {
+		return arrow.Field{}, err
+	}
+	return arrow.Field{Name: node.GetName(), Type: typ, Nullable: nullable}, nil
+}
+
+func arrowType(node Node, timestamps TimestampType) (arrow.DataType, error) {
+	if isTimestamp(node) {
+		return timestamps.arrowType(node), nil
+	}
+	switch node.GetType() {
+	case NodeTypeBoolean:
+		return arrow.FixedWidthTypes.Boolean, nil
+	case NodeTypeInt64:
+		return arrow.PrimitiveTypes.Int64, nil
+	case NodeTypeFloat64:
+		return arrow.PrimitiveTypes.Float64, nil
+	case NodeTypeByteArray:
+		switch node.GetLogicalType() {
+		case LogicalTypeUTF8:
+			return arrow.BinaryTypes.String, nil
+		case LogicalTypeJSON:
+			return extensions.NewJSONType(arrow.BinaryTypes.String)
+		}
+		return arrow.BinaryTypes.Binary, nil
+	}
+	return nil, fmt.Errorf("%w: field(%v) of type(%v)", ErrTypeNotSupported, node.GetName(), node.GetType())
+}
+
+// arrowColumn converts the values of a top level field of the rows to the builder of the field
+type arrowColumn interface {
+	// convert converts the value of the field in the row, the converted values are kept until the next row
+	convert(row map[string]interface{}) error
+	// append appends the converted values to the builder
+	append()
+	// coercedNulls returns the number of the values written as nulls because they could not be converted
+	coercedNulls() int64
+}
+
+type typedArrowColumn[T any] struct {
+	key       string // JSON key of the field
+	name      string
+	required  bool
+	builder   array.Builder
+	list      *array.ListBuilder // builder of the field when the field is a list
+	converter ValueConverter[T]
+	// appendValue appends a value to the builder of the field or of the elements of the list
+	appendValue func(T)
+	// describe returns the error of a value that cannot be converted, nil for the default error
+	describe func(v interface{}) error
+	// nullInvalid writes the values that cannot be converted as nulls (see CoerceNull)
+	nullInvalid bool
+
+	present bool
+	values  []T
+	valid   []bool // the elements of the list that are not null
+	invalid int64  // values of the row written as nulls
+	nulls   int64  // values of the appended rows written as nulls
+}
+
+func (c *typedArrowColumn[T]) convert(row map[string]interface{}) error {
+	c.values = c.values[:0]
+	c.valid = c.valid[:0]
+	c.invalid = 0
+	value, ok := row[c.key]
+	c.present = ok
+	if !ok {
+		if c.required {
+			return fmt.Errorf("missing required column(%v)", c.name)
+		}
+		return nil
+	}
+	if c.list == nil {
+		v, ok := c.converter(value)
+		if !ok {
+			return c.nullValue(conversionError[T](c.describe, value))
+		}
+		c.values = append(c.values, v)
+		return nil
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return c.nullValue(fmt.Errorf("unexpected type(%T)", value))
+	}
+	for _, v := range values {
+		var converted T
+		valid := v != nil
+		if valid {
+			if converted, valid = c.converter(v); !valid {
+				if !c.nullInvalid {
+					return conversionError[T](c.describe, v)
+				}
+				// the elements are nullable
+				c.invalid++
+			}
+		}
+		c.values = append(c.values, converted)
+		c.valid = append(c.valid, valid)
+	}
+	return nil
+}
+
+// nullValue writes the value of the field that cannot be converted as null if it is allowed,
+// otherwise it returns the error
+func (c *typedArrowColumn[T]) nullValue(err error) error {
+	if !c.nullInvalid || c.required {
+		return err
+	}
+	c.present = false
+	c.invalid++
+	return nil
+}
+
+func (c *typedArrowColumn[T]) append() {
+	c.nulls += c.invalid
+	switch {
+	case !c.present:
+		c.builder.AppendNull()
+	case c.list != nil:
+		// an empty list is a valid list without elements
+		c.list.Append(true)
+		for i, v := range c.values {
+			if c.valid[i] {
+				c.appendValue(v)
+			} else {
+				c.list.ValueBuilder().AppendNull()
+			}
+		}
+	default:
+		c.appendValue(c.values[0])
+	}
+}
+
+func (c *typedArrowColumn[T]) coercedNulls() int64 {
+	return c.nulls
+}
+
+func newTypedArrowColumn[T any](key string, node Node, builder array.Builder, rules Coercion,
+	converter ValueConverter[T], appendValue func(T),
+) *typedArrowColumn[T] {
+	list, _ := builder.(*array.ListBuilder)
+	return &typedArrowColumn[T]{
+		key:         key,
+		name:        node.GetName(),
+		required:    node.GetRepetition() == parquet.Repetitions.Required,
+		builder:     builder,
+		list:        list,
+		converter:   converter,
+		appendValue: appendValue,
+		nullInvalid: rules&CoerceNull != 0,
+	}
+}
+
+// newArrowColumns returns the converters of the top level fields to the builders of the record builder,
+// the fields of the record are in the order of Schema.ArrowSchema
+func newArrowColumns(sc *Schema, rb *array.RecordBuilder, rules Coercion, timestamps TimestampType) ([]arrowColumn, error) {
+	nodes := sc.sortedFields()
+	columns := make([]arrowColumn, len(nodes))
+	for i, node := range nodes {
+		column, err := newArrowColumn(node, columnCoercion(sc, rules, node.GetKey()), timestamps, rb.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func newArrowColumn(node Node, rules Coercion, timestamps TimestampType, builder array.Builder) (arrowColumn, error) {
+	key := node.GetKey()
+	element := node
+	vb := builder
+	if ln, ok := node.(*ListNode); ok {
+		element = ln.Element()
+		vb = builder.(*array.ListBuilder).ValueBuilder()
+	}
+	if eb, ok := vb.(*array.ExtensionBuilder); ok {
+		vb = eb.Builder
+	}
+	switch vb := vb.(type) {
+	case *array.BooleanBuilder:
+		return newTypedArrowColumn(key, node, builder, rules, boolConverter(rules), vb.Append), nil
+	case *array.Int64Builder:
+		return newTypedArrowColumn(key, node, builder, rules, int64Converter(rules), vb.Append), nil
+	case *array.TimestampBuilder:
+		c := newTypedArrowColumn(key, node, builder, rules, timestamps.int64Converter(element, rules), func(v int64) {
+			vb.Append(arrow.Timestamp(v))
+		})
+		c.describe = timestamps.overflowError(element, rules)
+		return c, nil
+	case *array.Float64Builder:
+		return newTypedArrowColumn(key, node, builder, rules, float64Converter(rules), vb.Append), nil
+	case *array.StringBuilder:
+		return newTypedArrowColumn(key, node, builder, rules, byteArrayConverter(rules), func(v parquet.ByteArray) {
+			vb.BinaryBuilder.Append(v)
+		}), nil
+	case *array.BinaryBuilder:
+		return newTypedArrowColumn(key, node, builder, rules, byteArrayConverter(rules), func(v parquet.ByteArray) {
+			vb.Append(v)
+		}), nil
+	}
+	return nil, fmt.Errorf("%w: field(%v) of type(%v)", ErrTypeNotSupported, node.GetName(), vb.Type())
+}
+
+// ArrowWriter writes the rows to a parquet file through the Arrow writer of the parquet library (pqarrow),
+// the rows are converted to Arrow records of the batch size by the schema. Records created elsewhere
+// can be written by WriteRecord. The Arrow schema is embedded in the file, so the Arrow readers restore
+// the original types (e.g. the time zone of timestamps or the JSON extension type), and lists are
+// written in the three-level layout of the parquet specification.
+type ArrowWriter struct {
+	writer *pqarrow.FileWriter
+	output *output
+	schema *arrow.Schema
+
+	builder   *array.RecordBuilder // nil for NewArrowRecordWriter
+	alloc     *countingAllocator   // allocator of the builder
+	columns   []arrowColumn
+	rows      uint // number of rows in the record builder
+	batchSize uint
+
+	rowGroupBytes int64
+	newRowGroup   bool // the next record starts a new row group
+
+	// approximate memory size of the buffered data, see WithMemoryLimit
+	memoryLimit int64
+	peakBytes   int64
+
+	summary  *Summary
+	failed   error // error that left the written data incomplete, see fail
+	closed   bool
+	closeErr error
+}
+
+// NewArrowWriter creates a writer of the rows to the parquet file, the file is written atomically like
+// by NewWriter. The columns are not encoded in parallel (see WithColumnWorkers), the rows cannot be
+// sorted by the writer (see WithSortedRowGroups) and the statistics cannot be truncated (see
+// WithMaxStatisticsSize).
+func NewArrowWriter(path string, batchSize uint, sc *Schema, opts ...WriterOption) (*ArrowWriter, error) {
+	out, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newArrowWriter(out, batchSize, sc, nil, opts)
+	if err != nil {
+		out.remove()
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewArrowWriterTo creates a writer of the rows to the output like NewWriterTo
+func NewArrowWriterTo(out io.Writer, batchSize uint, sc *Schema, opts ...WriterOption) (*ArrowWriter, error) {
+	return newArrowWriter(newOutput(out), batchSize, sc, nil, opts)
+}
+
+// NewArrowRecordWriter creates a writer of the Arrow records with the schema to the parquet file, the
+// records are written by WriteRecord (Write is not supported). The sorting columns are named by the
+// fields of the schema.
+func NewArrowRecordWriter(path string, sc *arrow.Schema, opts ...WriterOption) (*ArrowWriter, error) {
+	out, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newArrowWriter(out, 0, nil, sc, opts)
+	if err != nil {
+		out.remove()
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewArrowRecordWriterTo creates a writer of the Arrow records with the schema to the output
+func NewArrowRecordWriterTo(out io.Writer, sc *arrow.Schema, opts ...WriterOption) (*ArrowWriter, error) {
+	return newArrowWriter(newOutput(out), 0, nil, sc, opts)
+}
+
+func newArrowWriter(out *output, batchSize uint, sc *Schema, arrowSc *arrow.Schema, opts []WriterOption) (*ArrowWriter, error) {
+	config := newWriterConfig(opts)
+	if config.sortRowGroups > 0 {
+		return nil, fmt.Errorf("%w: sorted row groups written by the Arrow writer", ErrOpNotSupported)
+	}
+	if config.listLayout == ListLayoutLegacy {
+		return nil, fmt.Errorf("%w: legacy list layout written by the Arrow writer", ErrOpNotSupported)
+	}
+	if config.maxStatisticsSize > 0 {
+		// the statistics of the last row group are written with the footer when pqarrow is closed
+		return nil, fmt.Errorf("%w: truncated statistics written by the Arrow writer", ErrOpNotSupported)
+	}
+	var err error
+	if sc != nil {
+		if arrowSc, err = sc.ArrowSchemaWithLayout(config.layout()); err != nil {
+			return nil, err
+		}
+	}
+	arrowProps := pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema(),
+		pqarrow.WithDeprecatedInt96Timestamps(config.timestamps.INT96))
+	pqSc, err := pqarrow.ToParquet(arrowSc, nil, arrowProps)
+	if err != nil {
+		return nil, err
+	}
+	props, err := config.properties(sc, pqSc)
+	if err != nil {
+		return nil, err
+	}
+	kv, err := schemaMetadata(sc, config.metadata)
+	if err != nil {
+		return nil, err
+	}
+	// the footer metadata of pqarrow is the metadata of the schema
+	md := arrowSc.Metadata()
+	md = arrow.NewMetadata(append(md.Keys(), kv.Keys()...), append(md.Values(), kv.Values()...))
+	fw, err := pqarrow.NewFileWriter(arrow.NewSchema(arrowSc.Fields(), &md), out.sink, props, arrowProps)
+	if err != nil {
+		return nil, err
+	}
+	if config.encryption != nil {
+		out.kms = config.encryption.KMS
+	}
+	w := &ArrowWriter{
+		writer:        fw,
+		output:        out,
+		schema:        arrowSc,
+		batchSize:     batchSize,
+		rowGroupBytes: config.rowGroupBytes,
+		memoryLimit:   config.memoryLimit,
+	}
+	if sc != nil {
+		w.alloc = newCountingAllocator()
+		w.builder = array.NewRecordBuilder(w.alloc, arrowSc)
+		if w.columns, err = newArrowColumns(sc, w.builder, config.coercion, config.timestamps); err != nil {
+			w.builder.Release()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Schema returns the schema of the records written by WriteRecord
+func (w *ArrowWriter) Schema() *arrow.Schema {
+	return w.schema
+}
+
+// Write converts the values of the row to the record builder, the buffered rows are written as a record
+// when the batch is full. A row that cannot be converted is not written.
+func (w *ArrowWriter) Write(data map[string]interface{}) error {
+	if w.builder == nil {
+		return fmt.Errorf("%w: rows written to the writer of Arrow records", ErrOpNotSupported)
+	}
+	if w.failed != nil {
+		return w.failedError()
+	}
+	if w.rows >= w.batchSize {
+		if err := w.WriteBatch(); err != nil {
+			return err
+		}
+	}
+	// all the values are converted before any is appended, so the row is either written or not
+	for _, c := range w.columns {
+		if err := c.convert(data); err != nil {
+			return err
+		}
+	}
+	for _, c := range w.columns {
+		c.append()
+	}
+	w.rows++
+	if w.memoryLimit <= 0 {
+		return nil
+	}
+	size := w.bufferedBytes()
+	w.peakBytes = max(w.peakBytes, size)
+	if size < w.memoryLimit {
+		return nil
+	}
+	// the parquet library closes a row group when the next one is started, so the row group is
+	// written when the next record is written
+	log.Logger().Debugf("memory limit of %v bytes reached, writing the row group early", w.memoryLimit)
+	if err := w.WriteBatch(); err != nil {
+		return err
+	}
+	w.newRowGroup = true
+	return nil
+}
+
+// bufferedBytes returns the approximate memory size of the buffered data: the rows in the record
+// builder and the row group not yet written to the output
+func (w *ArrowWriter) bufferedBytes() int64 {
+	size := w.writer.RowGroupTotalBytesWritten()
+	if w.alloc != nil {
+		size += w.alloc.allocated()
+	}
+	return size
+}
+
+// WriteBatch writes the buffered rows as a record, an error of the output leaves the row group
+// incomplete and the writer failed, see Close
+func (w *ArrowWriter) WriteBatch() error {
+	if w.failed != nil {
+		return w.failedError()
+	}
+	if w.rows == 0 {
+		return nil
+	}
+	// the buffered data grows until the batch is written
+	w.peakBytes = max(w.peakBytes, w.bufferedBytes())
+	log.Logger().Debugf("writing %v rows of json data", w.rows)
+	w.rows = 0
+	rec := w.builder.NewRecordBatch()
+	defer rec.Release()
+	return w.writeRecord(rec)
+}
+
+// WriteRecord writes the Arrow record, the schema of the record must match the schema of the writer
+// (see Schema). The rows buffered by Write are written first, so the rows are in the order of the calls.
+func (w *ArrowWriter) WriteRecord(rec arrow.RecordBatch) error {
+	if w.closed {
+		return errors.New("writer is closed")
+	}
+	if w.failed != nil {
+		return w.failedError()
+	}
+	if err := w.WriteBatch(); err != nil {
+		return err
+	}
+	return w.writeRecord(rec)
+}
+
+// writeRecord writes the record to the current row group, the row group is flushed when the next record
+// is written after its size reached the target size (see WithRowGroupBytes). A record of another schema
+// is rejected, any other error fails the writer.
+func (w *ArrowWriter) writeRecord(rec arrow.RecordBatch) error {
+	if !rec.Schema().Equal(w.schema) {
+		return fmt.Errorf("record schema does not match the schema of the writer: %v", rec.Schema())
+	}
+	if w.newRowGroup {
+		// the error of writing the previous row group is not returned by NewBufferedRowGroup
+		w.writer.NewBufferedRowGroup()
+		w.newRowGroup = false
+		if err := w.output.sink.err; err != nil {
+			return w.fail(err)
+		}
+	}
+	if err := w.writer.WriteBuffered(rec); err != nil {
+		return w.fail(err)
+	}
+	size := w.writer.RowGroupTotalBytesWritten()
+	log.Logger().Debugf("buffered row group size %v bytes", size)
+	w.peakBytes = max(w.peakBytes, w.bufferedBytes())
+	w.newRowGroup = w.rowGroupBytes <= 0 || size >= w.rowGroupBytes
+	return nil
+}
+
+// EstimatedSize returns the size of the data written so far including the current row group, the rows
+// buffered for the next record are not included
+func (w *ArrowWriter) EstimatedSize() int64 {
+	return w.output.sink.written + w.writer.RowGroupTotalBytesWritten()
+}
+
+// AppendKeyValueMetadata adds an entry to the footer metadata of the file, it must be called
+// before the writer is closed
+func (w *ArrowWriter) AppendKeyValueMetadata(key, value string) error {
+	if w.closed {
+		return errors.New("writer is closed")
+	}
+	if w.failed != nil {
+		return w.failedError()
+	}
+	return w.writer.AppendKeyValueMetadata(key, value)
+}
+
+// Close writes the buffered rows and the footer, see Writer.Close
+func (w *ArrowWriter) Close() error {
+	if w.closed {
+		return w.closeErr
+	}
+	w.closed = true
+	if w.failed != nil {
+		w.closeErr = w.failedError()
+		if w.builder != nil {
+			w.builder.Release()
+		}
+		w.output.remove()
+		return w.closeErr
+	}
+	w.closeErr = w.output.commit(w.finish())
+	if w.closeErr != nil {
+		return w.closeErr
+	}
+	if w.summary = w.output.summary(w.writer.FileMetadata()); w.summary == nil {
+		return nil
+	}
+	// a top level field has a single leaf column
+	for i, c := range w.columns {
+		w.summary.Columns[i].CoercedNulls = c.coercedNulls()
+	}
+	w.summary.PeakBufferedBytes = w.peakBytes
+	return nil
+}
+
+// Abort discards the written data, see Writer.Abort
+func (w *ArrowWriter) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.closeErr = errors.New("writer is aborted")
+	if w.builder != nil {
+		w.builder.Release()
+	}
+	w.output.remove()
+}
+
+// finish writes the remaining rows and the footer and closes the output
+func (w *ArrowWriter) finish() error {
+	var errs []error
+	if w.builder != nil {
+		if err := w.WriteBatch(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write last batch data: %w", err))
+		}
+		w.builder.Release()
+	}
+	if w.failed != nil {
+		// the footer of an incomplete row group is not written
+		w.output.remove()
+		return errors.Join(errs...)
+	}
+	if err := w.writer.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close parquet writer: %w", err))
+	}
+	if err := w.output.close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// fail marks the writer failed by the error, see Writer.fail
+func (w *ArrowWriter) fail(err error) error {
+	w.failed = err
+	return err
+}
+
+func (w *ArrowWriter) failedError() error {
+	return fmt.Errorf("writer failed: %w", w.failed)
+}
+
+// Summary returns the description of the written file, it is available after the writer is closed
+func (w *ArrowWriter) Summary() (*Summary, error) {
+	if w.summary == nil {
+		return nil, ErrNoSummary
+	}
+	return w.summary, nil
+}
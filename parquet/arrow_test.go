@@ -0,0 +1,294 @@
+package parquet_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/require"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func testArrowRecords() []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"id":    json.Number("1"),
+			"name":  "first",
+			"score": json.Number("1.5"),
+			"ok":    true,
+			"ts":    "2024-10-01T10:00:00Z",
+			"tags":  []interface{}{"a", nil, "b"},
+			"attrs": tfJson.Raw(`{"a":1}`),
+		},
+		{
+			"id":   json.Number("2"),
+			"ts":   "2024-10-01T11:00:00Z",
+			"tags": []interface{}{},
+		},
+		{
+			"id": json.Number("3"),
+			"ts": "2024-10-01T12:00:00Z",
+		},
+	}
+}
+
+func testArrowSchema(t *testing.T, records []map[string]interface{}) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	for _, record := range records {
+		require.NoError(t, sb.UpdateSchema(record))
+	}
+	return sb.Schema()
+}
+
+// readArrowTable reads the parquet data as an Arrow table with the embedded Arrow schema
+func readArrowTable(t *testing.T, data []byte) (arrow.Table, *file.Reader) {
+	reader, err := file.NewParquetReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = reader.Close() })
+	fr, err := pqarrow.NewFileReader(reader, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	table, err := fr.ReadTable(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(table.Release)
+	return table, reader
+}
+
+// requireArrowFields checks the names and types of the fields read back, the reader adds the field ids
+// to the metadata of the fields
+func requireArrowFields(t *testing.T, expected, actual *arrow.Schema) {
+	require.Equal(t, expected.NumFields(), actual.NumFields())
+	for i, field := range expected.Fields() {
+		require.Equal(t, field.Name, actual.Field(i).Name)
+		require.Equal(t, field.Nullable, actual.Field(i).Nullable)
+		require.True(t, arrow.TypeEqual(field.Type, actual.Field(i).Type), "field(%v): %v", field.Name, actual.Field(i).Type)
+	}
+}
+
+func TestArrowSchema(t *testing.T) {
+	sc := testArrowSchema(t, testArrowRecords())
+	arrowSc, err := sc.ArrowSchema()
+	require.NoError(t, err)
+	require.Equal(t, "schema:\n"+
+		"  fields: 7\n"+
+		"    - attrs: type=extension<arrow.json[storage_type=utf8]>, nullable\n"+
+		"    - id: type=int64\n"+
+		"    - name: type=utf8, nullable\n"+
+		"    - ok: type=bool, nullable\n"+
+		"    - score: type=float64, nullable\n"+
+		"    - tags: type=list<element: utf8, nullable>, nullable\n"+
+		"    - ts: type=timestamp[ns, tz=UTC]", arrowSc.String())
+}
+
+func TestArrowWriter(t *testing.T) {
+	records := testArrowRecords()
+	sc := testArrowSchema(t, records)
+	var out bytes.Buffer
+	wr, err := parquet.NewArrowWriterTo(&out, 2, sc, parquet.WithKeyValueMetadata("key", "value"))
+	require.NoError(t, err)
+	for _, record := range records {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.AppendKeyValueMetadata("appended", "value"))
+	require.NoError(t, wr.Close())
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, summary.Rows)
+	// a row group per batch
+	require.Equal(t, 2, summary.RowGroups)
+
+	table, reader := readArrowTable(t, out.Bytes())
+	kv := reader.MetaData().KeyValueMetadata()
+	require.NotNil(t, kv.FindValue("ARROW:schema"))
+	require.NotNil(t, kv.FindValue(parquet.SchemaMetadataKey))
+	require.Equal(t, "value", *kv.FindValue("key"))
+	require.Equal(t, "value", *kv.FindValue("appended"))
+	// lists are written in the three-level layout
+	require.Equal(t, "tags.list.element", reader.MetaData().Schema.Column(5).ColumnPath().String())
+
+	// the types are restored from the embedded Arrow schema, except the JSON extension type that
+	// the parquet library reads as binary
+	arrowSc, err := sc.ArrowSchema()
+	require.NoError(t, err)
+	fields := arrowSc.Fields()
+	fields[0].Type = arrow.BinaryTypes.Binary
+	requireArrowFields(t, arrow.NewSchema(fields, nil), table.Schema())
+	require.EqualValues(t, 3, table.NumRows())
+	tr := array.NewTableReader(table, -1)
+	defer tr.Release()
+	require.True(t, tr.Next())
+	rec := tr.RecordBatch()
+	tags := rec.Column(5).(*array.List)
+	// a missing list is null, an empty list is valid and a null element is null
+	require.Equal(t, `[["a" (null) "b"] [] (null)]`, tags.String())
+	require.Equal(t, `[1 2 3]`, rec.Column(1).String())
+	require.Equal(t, `["first" (null) (null)]`, rec.Column(2).String())
+	require.Equal(t, `{"a":1}`, string(rec.Column(0).(*array.Binary).Value(0)))
+	ts := rec.Column(6).(*array.Timestamp)
+	require.Equal(t, int64(1727776800000000000), int64(ts.Value(0)))
+}
+
+func TestArrowWriterRecords(t *testing.T) {
+	records := testArrowRecords()
+	sc := testArrowSchema(t, records)
+	var out bytes.Buffer
+	wr, err := parquet.NewArrowWriterTo(&out, 100, sc)
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(records[0]))
+
+	rb := array.NewRecordBuilder(memory.DefaultAllocator, wr.Schema())
+	defer rb.Release()
+	for i := range rb.Schema().NumFields() {
+		switch b := rb.Field(i).(type) {
+		case *array.Int64Builder:
+			b.Append(10)
+		case *array.TimestampBuilder:
+			b.Append(0)
+		default:
+			b.AppendNull()
+		}
+	}
+	rec := rb.NewRecordBatch()
+	defer rec.Release()
+	require.NoError(t, wr.WriteRecord(rec))
+	require.NoError(t, wr.Write(records[1]))
+	require.NoError(t, wr.Close())
+
+	table, _ := readArrowTable(t, out.Bytes())
+	require.EqualValues(t, 3, table.NumRows())
+	ids := array.NewChunkedSlice(table.Column(1).Data(), 0, 3)
+	defer ids.Release()
+	// the buffered row is written before the record
+	var values []int64
+	for _, chunk := range ids.Chunks() {
+		values = append(values, chunk.(*array.Int64).Int64Values()...)
+	}
+	require.Equal(t, []int64{1, 10, 2}, values)
+
+	// the record must match the schema of the writer
+	other := arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64}}, nil)
+	wr, err = parquet.NewArrowWriterTo(io.Discard, 100, sc)
+	require.NoError(t, err)
+	rb = array.NewRecordBuilder(memory.DefaultAllocator, other)
+	defer rb.Release()
+	rb.Field(0).(*array.Int64Builder).Append(1)
+	rec = rb.NewRecordBatch()
+	defer rec.Release()
+	require.Error(t, wr.WriteRecord(rec))
+	wr.Abort()
+}
+
+func TestArrowRecordWriter(t *testing.T) {
+	arrowSc := arrow.NewSchema([]arrow.Field{
+		{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "values", Type: arrow.ListOf(arrow.ListOf(arrow.PrimitiveTypes.Int64)), Nullable: true},
+	}, nil)
+	path := filepath.Join(t.TempDir(), "records.parquet")
+	wr, err := parquet.NewArrowRecordWriter(path, arrowSc,
+		parquet.WithSortingColumns(parquet.SortColumn{Key: "id"}))
+	require.NoError(t, err)
+	require.ErrorIs(t, wr.Write(map[string]interface{}{"id": json.Number("1")}), parquet.ErrOpNotSupported)
+
+	rb := array.NewRecordBuilder(memory.DefaultAllocator, arrowSc)
+	defer rb.Release()
+	rb.Field(0).(*array.Int64Builder).AppendValues([]int64{1, 2}, nil)
+	// nested lists are handled by pqarrow
+	lb := rb.Field(1).(*array.ListBuilder)
+	inner := lb.ValueBuilder().(*array.ListBuilder)
+	lb.Append(true)
+	inner.Append(true)
+	inner.ValueBuilder().(*array.Int64Builder).AppendValues([]int64{1, 2}, nil)
+	inner.AppendNull()
+	lb.AppendNull()
+	rec := rb.NewRecordBatch()
+	defer rec.Release()
+	require.NoError(t, wr.WriteRecord(rec))
+	require.NoError(t, wr.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	table, reader := readArrowTable(t, data)
+	requireArrowFields(t, arrowSc, table.Schema())
+	require.Equal(t, `[[[1 2] (null)] (null)]`, table.Column(1).Data().Chunk(0).String())
+	require.Len(t, reader.MetaData().RowGroup(0).SortingColumns(), 1)
+}
+
+func TestArrowWriterInvalidRow(t *testing.T) {
+	records := testArrowRecords()
+	sc := testArrowSchema(t, records)
+	var out bytes.Buffer
+	wr, err := parquet.NewArrowWriterTo(&out, 100, sc)
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(records[0]))
+	// the values converted before the invalid value are not appended
+	invalid := map[string]interface{}{"id": json.Number("4"), "ts": "2024-10-01T12:00:00Z", "tags": []interface{}{true}}
+	require.ErrorContains(t, wr.Write(invalid), "cannot convert")
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"name": "x"}), "missing required column")
+	require.NoError(t, wr.Write(records[2]))
+	require.NoError(t, wr.Close())
+
+	table, _ := readArrowTable(t, out.Bytes())
+	require.EqualValues(t, 2, table.NumRows())
+	require.Equal(t, `[1 3]`, table.Column(1).Data().Chunk(0).String())
+}
+
+func TestArrowWriterFailed(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("1"), "text": "a"}))
+	sc := sb.Schema()
+
+	// a row group that fails to be written fails the writer
+	wr, err := parquet.NewArrowWriterTo(&failingWriter{limit: 1 << 20}, 100, sc,
+		parquet.WithRowGroupBytes(1<<16), parquet.WithDictionary(false))
+	require.NoError(t, err)
+	for i := 0; ; i++ {
+		err = wr.Write(map[string]interface{}{
+			"id": json.Number(strconv.Itoa(i)), "text": fmt.Sprintf("%v %d", strings.Repeat("text ", 20), i),
+		})
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorIs(t, err, errWriteFailed)
+	require.ErrorIs(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "text": "a"}), errWriteFailed)
+	require.ErrorIs(t, wr.WriteBatch(), errWriteFailed)
+	require.ErrorIs(t, wr.AppendKeyValueMetadata("key", "value"), errWriteFailed)
+	require.ErrorIs(t, wr.Close(), errWriteFailed)
+	_, err = wr.Summary()
+	require.ErrorIs(t, err, parquet.ErrNoSummary)
+}
+
+func TestArrowRollingWriter(t *testing.T) {
+	records := testArrowRecords()
+	sc := testArrowSchema(t, records)
+	dir := t.TempDir()
+	rw, err := parquet.NewRollingWriter(filepath.Join(dir, "out-{index}.parquet"), 2, 0, 10, sc,
+		parquet.WithArrowWriter(true))
+	require.NoError(t, err)
+	for _, record := range records {
+		require.NoError(t, rw.Write(record))
+	}
+	require.NoError(t, rw.Close())
+	manifest := rw.Manifest()
+	require.Len(t, manifest.Files, 2)
+	require.EqualValues(t, 3, manifest.Rows)
+	for _, f := range manifest.Files {
+		reader, err := file.OpenParquetFile(f.Path, false)
+		require.NoError(t, err)
+		require.NotNil(t, reader.MetaData().KeyValueMetadata().FindValue("ARROW:schema"))
+		require.Equal(t, f.Rows, reader.NumRows())
+		require.NoError(t, reader.Close())
+	}
+}
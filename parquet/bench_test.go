@@ -0,0 +1,69 @@
+package parquet_test
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func benchmarkRecords(rows int, lists bool) []map[string]interface{} {
+	records := make([]map[string]interface{}, rows)
+	for i := range records {
+		record := map[string]interface{}{
+			"id":      json.Number(strconv.Itoa(i)),
+			"value":   json.Number(strconv.Itoa(i) + ".5"),
+			"name":    "name " + strconv.Itoa(i%100),
+			"time":    "2024-10-01T10:00:00Z",
+			"enabled": i%2 == 0,
+		}
+		if lists {
+			record["ids"] = []interface{}{json.Number("1"), json.Number("2"), json.Number(strconv.Itoa(i))}
+			record["tags"] = []interface{}{"a", "b", "tag " + strconv.Itoa(i%10)}
+		}
+		records[i] = record
+	}
+	return records
+}
+
+func benchmarkWriter(b *testing.B, lists bool, opts ...parquet.WriterOption) {
+	records := benchmarkRecords(10000, lists)
+	sb := parquet.NewSchemaBuilder()
+	for _, record := range records[:10] {
+		require.NoError(b, sb.UpdateSchema(record))
+	}
+	sc := sb.Schema()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		wr, err := parquet.NewWriterTo(io.Discard, 1000, sc, opts...)
+		require.NoError(b, err)
+		for _, record := range records {
+			require.NoError(b, wr.Write(record))
+		}
+		require.NoError(b, wr.Close())
+	}
+	b.ReportMetric(float64(b.N*len(records))/b.Elapsed().Seconds(), "rows/s")
+}
+
+func BenchmarkWriter(b *testing.B) {
+	benchmarkWriter(b, false)
+}
+
+func BenchmarkWriterLists(b *testing.B) {
+	benchmarkWriter(b, true)
+}
+
+func BenchmarkWriterZstd(b *testing.B) {
+	benchmarkWriter(b, true, parquet.WithCompression(compress.Codecs.Zstd, compress.DefaultCompressionLevel))
+}
+
+func BenchmarkWriterZstdColumnWorkers(b *testing.B) {
+	benchmarkWriter(b, true, parquet.WithCompression(compress.Codecs.Zstd, compress.DefaultCompressionLevel),
+		parquet.WithColumnWorkers(runtime.NumCPU()))
+}
@@ -0,0 +1,77 @@
+package parquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Coercion is a set of rules that convert the values that do not match the type of their column when
+// they are written. Without any rules (CoerceStrict) a value that cannot be converted fails the row.
+type Coercion uint
+
+const (
+	// CoerceNumericStrings parses numeric strings written to int64 and float64 columns
+	CoerceNumericStrings Coercion = 1 << iota
+	// CoerceNumbers writes numbers without a fractional part (e.g. 2.0) to int64 columns, integers are
+	// written to float64 columns in any mode
+	CoerceNumbers
+	// CoerceBoolStrings parses the strings "true" and "false" written to bool columns
+	CoerceBoolStrings
+	// CoerceStringify writes numbers, booleans and JSON text to string and byte array columns as text
+	CoerceStringify
+	// CoerceNull writes the values that cannot be converted as nulls of optional columns and optional
+	// list elements, the nulls are counted per column (see ColumnSummary.CoercedNulls)
+	CoerceNull
+
+	// CoerceStrict fails the row on any value that does not match the type of its column
+	CoerceStrict Coercion = 0
+	// CoerceAll enables all the rules
+	CoerceAll = CoerceNumericStrings | CoerceNumbers | CoerceBoolStrings | CoerceStringify | CoerceNull
+
+	// the values of a field whose type was forced (see Schema.IsOverridden) are always converted
+	overriddenCoercion = CoerceNumericStrings | CoerceNumbers | CoerceBoolStrings | CoerceStringify
+)
+
+var coercionRules = map[string]Coercion{
+	"strict":          CoerceStrict,
+	"numeric_strings": CoerceNumericStrings,
+	"numbers":         CoerceNumbers,
+	"bool_strings":    CoerceBoolStrings,
+	"stringify":       CoerceStringify,
+	"null":            CoerceNull,
+	"all":             CoerceAll,
+}
+
+// ParseCoercion parses a comma separated list of coercion rules, a rule is one of numeric_strings,
+// numbers, bool_strings, stringify, null, all (all the rules) or strict (no rules)
+func ParseCoercion(s string) (Coercion, error) {
+	var rules Coercion
+	for _, name := range strings.Split(s, ",") {
+		rule, ok := coercionRules[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return CoerceStrict, fmt.Errorf("%w: coercion rule(%v)", ErrOpNotSupported, name)
+		}
+		rules |= rule
+	}
+	return rules, nil
+}
+
+// columnCoercion returns the rules of the values of the field with the JSON key, the values of the
+// overridden list elements of the field are always converted as well
+func columnCoercion(sc *Schema, rules Coercion, key string) Coercion {
+	if sc.overrides.overridesColumn(key) {
+		rules |= overriddenCoercion
+	}
+	return rules
+}
+
+// numericStrings applies the converter of numbers to the numeric strings
+func numericStrings[T any](convert ValueConverter[T]) ValueConverter[T] {
+	return func(v interface{}) (T, bool) {
+		if s, ok := v.(string); ok {
+			v = json.Number(s)
+		}
+		return convert(v)
+	}
+}
@@ -0,0 +1,198 @@
+package parquet_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func TestParseCoercion(t *testing.T) {
+	rules, err := parquet.ParseCoercion("numeric_strings, BOOL_STRINGS")
+	require.NoError(t, err)
+	require.Equal(t, parquet.CoerceNumericStrings|parquet.CoerceBoolStrings, rules)
+	rules, err = parquet.ParseCoercion("strict")
+	require.NoError(t, err)
+	require.Equal(t, parquet.CoerceStrict, rules)
+	rules, err = parquet.ParseCoercion("all")
+	require.NoError(t, err)
+	require.Equal(t, parquet.CoerceAll, rules)
+	_, err = parquet.ParseCoercion("numbers,lenient")
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+}
+
+func testCoercionSchema(t *testing.T) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"id":    json.Number("1"),
+		"count": json.Number("1"),
+		"score": json.Number("1.5"),
+		"ok":    true,
+		"name":  "a",
+		"list":  []interface{}{json.Number("1")},
+	}))
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("2")}))
+	return sb.Schema()
+}
+
+func TestWriteCoercion(t *testing.T) {
+	sc := testCoercionSchema(t)
+	coerced := map[string]interface{}{
+		"id":    json.Number("1"),
+		"count": "42",
+		"score": "2.5",
+		"ok":    "true",
+		"name":  json.Number("7"),
+		"list":  []interface{}{json.Number("2.0"), "3"},
+	}
+
+	// the strict mode fails the row
+	wr, err := parquet.NewWriterTo(io.Discard, 10, sc)
+	require.NoError(t, err)
+	for key, value := range coerced {
+		if key != "id" {
+			require.ErrorContains(t, wr.Write(map[string]interface{}{"id": json.Number("1"), key: value}), "cannot convert")
+		}
+	}
+	wr.Abort()
+
+	var buf bytes.Buffer
+	wr, err = parquet.NewWriterTo(&buf, 10, sc, parquet.WithCoercion(parquet.CoerceAll))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(coerced))
+	// the values that cannot be converted are written as nulls
+	require.NoError(t, wr.Write(map[string]interface{}{
+		"id":    json.Number("2"),
+		"count": "x",
+		"score": true,
+		"ok":    json.Number("1"),
+		"name":  []interface{}{"a"},
+		"list":  []interface{}{json.Number("1.5"), json.Number("4")},
+	}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("3"), "list": "x"}))
+	// the values of required columns are not nulled
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"id": "x"}), "cannot convert")
+	require.NoError(t, wr.Close())
+
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	nulls := make(map[string]int64)
+	for _, c := range summary.Columns {
+		nulls[c.Path] = c.CoercedNulls
+	}
+	require.Equal(t, map[string]int64{
+		"count": 1, "id": 0, "list.list.element": 2, "name": 1, "ok": 1, "score": 1,
+	}, nulls)
+
+	reader, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer reader.Close()
+	require.EqualValues(t, 3, reader.NumRows())
+	rgr := reader.RowGroup(0)
+	idx := reader.MetaData().Schema.ColumnIndexByName
+	readInt64 := func(name string) ([]int64, []int16) {
+		col, err := rgr.Column(idx(name))
+		require.NoError(t, err)
+		values, defLevels := make([]int64, 10), make([]int16, 10)
+		total, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(10, values, defLevels, make([]int16, 10))
+		require.NoError(t, err)
+		return values[:n], defLevels[:total]
+	}
+	values, defLevels := readInt64("count")
+	require.Equal(t, []int64{42}, values)
+	require.Equal(t, []int16{1, 0, 0}, defLevels)
+	values, defLevels = readInt64("list.list.element")
+	require.Equal(t, []int64{2, 3, 4}, values)
+	require.Equal(t, []int16{3, 3, 2, 3, 0}, defLevels)
+
+	col, err := rgr.Column(idx("name"))
+	require.NoError(t, err)
+	names := make([]pq.ByteArray, 3)
+	_, n, err := col.(*file.ByteArrayColumnChunkReader).ReadBatch(3, names, make([]int16, 3), nil)
+	require.NoError(t, err)
+	require.Equal(t, []pq.ByteArray{pq.ByteArray("7")}, names[:n])
+}
+
+func TestWriteCoercionRules(t *testing.T) {
+	sc := testCoercionSchema(t)
+	wr, err := parquet.NewWriterTo(io.Discard, 10, sc, parquet.WithCoercion(parquet.CoerceNumbers))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "count": json.Number("2.0")}))
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "count": json.Number("2.5")}), "cannot convert")
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "count": "2"}), "cannot convert")
+	// integers are written to float columns in any mode
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "score": json.Number("2")}))
+	require.NoError(t, wr.Close())
+
+	wr, err = parquet.NewWriterTo(io.Discard, 10, sc, parquet.WithCoercion(parquet.CoerceStringify))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "name": tfJson.Raw(`{"a":1}`)}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "name": false}))
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "ok": "true"}), "cannot convert")
+	require.NoError(t, wr.Close())
+}
+
+func TestWriteCoercionBoolStrings(t *testing.T) {
+	sc := testCoercionSchema(t)
+	var buf bytes.Buffer
+	wr, err := parquet.NewWriterTo(&buf, 10, sc, parquet.WithCoercion(parquet.CoerceBoolStrings|parquet.CoerceNull))
+	require.NoError(t, err)
+	for _, value := range []string{"true", "false", "1", "0", "t", "F", "TRUE", "False"} {
+		require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "ok": value}))
+	}
+	require.NoError(t, wr.Close())
+
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	for _, c := range summary.Columns {
+		if c.Path == "ok" {
+			// only "true" and "false" are converted
+			require.EqualValues(t, 6, c.CoercedNulls)
+		}
+	}
+
+	reader, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer reader.Close()
+	col, err := reader.RowGroup(0).Column(reader.MetaData().Schema.ColumnIndexByName("ok"))
+	require.NoError(t, err)
+	values := make([]bool, 8)
+	_, n, err := col.(*file.BooleanColumnChunkReader).ReadBatch(8, values, make([]int16, 8), nil)
+	require.NoError(t, err)
+	require.Equal(t, []bool{true, false}, values[:n])
+}
+
+func TestArrowWriterCoercion(t *testing.T) {
+	sc := testCoercionSchema(t)
+	var buf bytes.Buffer
+	wr, err := parquet.NewArrowWriterTo(&buf, 10, sc, parquet.WithCoercion(parquet.CoerceAll))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{
+		"id":    json.Number("1"),
+		"count": "42",
+		"list":  []interface{}{"x", json.Number("2.0")},
+	}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "count": "x", "list": "x"}))
+	// a row that is not written does not change the counts
+	require.Error(t, wr.Write(map[string]interface{}{"id": "x", "count": "x"}))
+	require.NoError(t, wr.Close())
+
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	nulls := make(map[string]int64)
+	for _, c := range summary.Columns {
+		nulls[c.Path] = c.CoercedNulls
+	}
+	require.EqualValues(t, 1, nulls["count"])
+	require.EqualValues(t, 2, nulls["list.list.element"])
+
+	table, _ := readArrowTable(t, buf.Bytes())
+	require.Equal(t, `[42 (null)]`, table.Column(0).Data().Chunk(0).String())
+	require.Equal(t, `[[(null) 2] (null)]`, table.Column(2).Data().Chunk(0).String())
+}
@@ -0,0 +1,397 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	tfJson "github.com/thermofisher/json2parquet/json"
+)
+
+// Define a type for conversion functions
+type ValueConverter[T any] func(interface{}) (T, bool)
+
+// columnBuffer collects the converted values and the levels of a leaf column for the rows of a batch
+type columnBuffer interface {
+	// append converts the value of the column in the row, the buffer is not changed on error
+	append(row map[string]interface{}) error
+	// undo removes the values of the last appended row
+	undo()
+	// write passes the buffered values to the column writer with a single WriteBatch and resets the buffer
+	write(cw file.ColumnChunkWriter) error
+	reset()
+	// coercedNulls returns the number of the values written as nulls because they could not be converted
+	coercedNulls() int64
+	// bufferedBytes returns the approximate memory size of the buffered values and levels
+	bufferedBytes() int64
+}
+
+type typedColumnBuffer[T any] struct {
+	key        string // JSON key of the top level field that contains the column
+	name       string // name of the top level field
+	required   bool   // the top level field is required
+	defLevel   int16
+	repLevel   int16
+	array      bool
+	convert    ValueConverter[T]
+	writeBatch func(cw file.ColumnChunkWriter, values []T, defLevels, repLevels []int16) error
+	// describe returns the error of a value that cannot be converted, nil for the default error
+	describe func(v interface{}) error
+	// nullInvalid writes the values that cannot be converted as nulls (see CoerceNull)
+	nullInvalid bool
+	// dataSize returns the size of the data a value refers to (e.g. the bytes of a byte array), nil
+	// for fixed size values
+	dataSize func(v T) int64
+
+	// definition levels of a list column: the list without elements and a null element (-1 when
+	// the elements are required)
+	emptyDefLevel int16
+	nullDefLevel  int16
+
+	values    []T
+	defLevels []int16
+	repLevels []int16
+
+	// values written as nulls by all the batches
+	nulls int64
+	// size of the data of the buffered values, see dataSize
+	data int64
+
+	// lengths of the buffers, the nulls and the data before the last appended row
+	lastValues int
+	lastLevels int
+	lastNulls  int64
+	lastData   int64
+}
+
+// repeatedNode returns the repeated node on the path of the node, the element of a two-level list
+// or the middle group of a three-level list, nil if the node is not in a list
+func repeatedNode(node schema.Node) schema.Node {
+	for ; node != nil; node = node.Parent() {
+		if node.RepetitionType() == parquet.Repetitions.Repeated {
+			return node
+		}
+	}
+	return nil
+}
+
+// definitionLevel returns the number of the nodes on the path of the node that are not required
+func definitionLevel(node schema.Node) int16 {
+	var level int16
+	for ; node != nil; node = node.Parent() {
+		if node.RepetitionType() != parquet.Repetitions.Required {
+			level++
+		}
+	}
+	return level
+}
+
+func newTypedColumnBuffer[T any](key string, col *schema.Column, rules Coercion, convert ValueConverter[T],
+	writeBatch func(cw file.ColumnChunkWriter, values []T, defLevels, repLevels []int16) error,
+) *typedColumnBuffer[T] {
+	node := col.SchemaNode()
+	b := &typedColumnBuffer[T]{
+		key:          key,
+		name:         col.ColumnPath()[0],
+		required:     node.RepetitionType() == parquet.Repetitions.Required,
+		defLevel:     col.MaxDefinitionLevel(),
+		repLevel:     col.MaxRepetitionLevel(),
+		convert:      convert,
+		writeBatch:   writeBatch,
+		nullInvalid:  rules&CoerceNull != 0,
+		nullDefLevel: -1,
+	}
+	if repeated := repeatedNode(node); repeated != nil {
+		// the parent of the repeated node is the list
+		b.array = true
+		b.required = repeated.Parent().RepetitionType() == parquet.Repetitions.Required
+		b.emptyDefLevel = definitionLevel(repeated) - 1
+		if node.RepetitionType() == parquet.Repetitions.Optional {
+			b.nullDefLevel = b.defLevel - 1
+		}
+	}
+	return b
+}
+
+func (b *typedColumnBuffer[T]) append(row map[string]interface{}) error {
+	b.lastValues, b.lastLevels, b.lastNulls, b.lastData = len(b.values), len(b.defLevels), b.nulls, b.data
+	var err error
+	if b.array {
+		err = b.appendArray(row)
+	} else {
+		err = b.appendSingle(row)
+	}
+	if err != nil {
+		b.undo()
+		return err
+	}
+	if b.dataSize != nil {
+		for _, v := range b.values[b.lastValues:] {
+			b.data += b.dataSize(v)
+		}
+	}
+	return nil
+}
+
+func (b *typedColumnBuffer[T]) appendSingle(row map[string]interface{}) error {
+	value, ok := row[b.key]
+	if !ok {
+		if b.required {
+			return fmt.Errorf("missing required column(%v)", b.name)
+		}
+		b.defLevels = append(b.defLevels, 0)
+		return nil
+	}
+	v, ok := b.convert(value)
+	if !ok {
+		if !b.nullInvalid || b.required {
+			return conversionError[T](b.describe, value)
+		}
+		b.nulls++
+		b.defLevels = append(b.defLevels, 0)
+		return nil
+	}
+	b.values = append(b.values, v)
+	b.defLevels = append(b.defLevels, b.defLevel)
+	return nil
+}
+
+func (b *typedColumnBuffer[T]) appendArray(row map[string]interface{}) error {
+	if b.repLevel > 1 {
+		return errors.New("nested elements are not supported")
+	}
+	value, ok := row[b.key]
+	if !ok {
+		if b.required {
+			return fmt.Errorf("missing required column(%v)", b.name)
+		}
+		// missing at the list level
+		b.defLevels = append(b.defLevels, b.emptyDefLevel-1)
+		b.repLevels = append(b.repLevels, 0)
+		return nil
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		if !b.nullInvalid || b.required {
+			return fmt.Errorf("unexpected type(%T)", value)
+		}
+		b.nulls++
+		b.defLevels = append(b.defLevels, b.emptyDefLevel-1)
+		b.repLevels = append(b.repLevels, 0)
+		return nil
+	}
+	if len(values) == 0 {
+		// the list is defined without elements
+		b.defLevels = append(b.defLevels, b.emptyDefLevel)
+		b.repLevels = append(b.repLevels, 0)
+		return nil
+	}
+	for i, v := range values {
+		if i == 0 {
+			// new entry start
+			b.repLevels = append(b.repLevels, 0)
+		} else {
+			// same array, so take the max repLevel
+			b.repLevels = append(b.repLevels, b.repLevel)
+		}
+		if v == nil {
+			if b.nullDefLevel < 0 {
+				return fmt.Errorf("null element of list(%v) with required elements", b.name)
+			}
+			b.defLevels = append(b.defLevels, b.nullDefLevel)
+			continue
+		}
+		converted, ok := b.convert(v)
+		if !ok {
+			if !b.nullInvalid || b.nullDefLevel < 0 {
+				return conversionError[T](b.describe, v)
+			}
+			b.nulls++
+			b.defLevels = append(b.defLevels, b.nullDefLevel)
+			continue
+		}
+		b.values = append(b.values, converted)
+		// non-NULL so take the max defLevel
+		b.defLevels = append(b.defLevels, b.defLevel)
+	}
+	return nil
+}
+
+// conversionError returns the error of the value that cannot be converted to T, described by the
+// function if it is set
+func conversionError[T any](describe func(v interface{}) error, v interface{}) error {
+	if describe != nil {
+		if err := describe(v); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("cannot convert(%T) to %T", v, *new(T))
+}
+
+func (b *typedColumnBuffer[T]) undo() {
+	b.nulls = b.lastNulls
+	b.data = b.lastData
+	b.values = b.values[:b.lastValues]
+	b.defLevels = b.defLevels[:b.lastLevels]
+	if b.array {
+		b.repLevels = b.repLevels[:b.lastLevels]
+	}
+}
+
+func (b *typedColumnBuffer[T]) write(cw file.ColumnChunkWriter) error {
+	defer b.reset()
+	return b.writeBatch(cw, b.values, b.defLevels, b.repLevels)
+}
+
+// reset empties the buffer, the memory is reused by the next batch
+func (b *typedColumnBuffer[T]) reset() {
+	b.values = b.values[:0]
+	b.defLevels = b.defLevels[:0]
+	if b.array {
+		b.repLevels = b.repLevels[:0]
+	}
+	b.lastValues, b.lastLevels = 0, 0
+	b.lastNulls = b.nulls
+	b.data, b.lastData = 0, 0
+}
+
+func (b *typedColumnBuffer[T]) coercedNulls() int64 {
+	return b.nulls
+}
+
+func (b *typedColumnBuffer[T]) bufferedBytes() int64 {
+	levels := int64(len(b.defLevels)+len(b.repLevels)) * int64(unsafe.Sizeof(int16(0)))
+	return int64(len(b.values))*int64(unsafe.Sizeof(*new(T))) + levels + b.data
+}
+
+func writeBoolBatch(cw file.ColumnChunkWriter, values []bool, defLevels, repLevels []int16) error {
+	_, err := cw.(*file.BooleanColumnChunkWriter).WriteBatch(values, defLevels, repLevels)
+	return err
+}
+
+func writeInt64Batch(cw file.ColumnChunkWriter, values []int64, defLevels, repLevels []int16) error {
+	_, err := cw.(*file.Int64ColumnChunkWriter).WriteBatch(values, defLevels, repLevels)
+	return err
+}
+
+func writeInt96Batch(cw file.ColumnChunkWriter, values []parquet.Int96, defLevels, repLevels []int16) error {
+	_, err := cw.(*file.Int96ColumnChunkWriter).WriteBatch(values, defLevels, repLevels)
+	return err
+}
+
+func writeFloat64Batch(cw file.ColumnChunkWriter, values []float64, defLevels, repLevels []int16) error {
+	_, err := cw.(*file.Float64ColumnChunkWriter).WriteBatch(values, defLevels, repLevels)
+	return err
+}
+
+func writeByteArrayBatch(cw file.ColumnChunkWriter, values []parquet.ByteArray, defLevels, repLevels []int16) error {
+	_, err := cw.(*file.ByteArrayColumnChunkWriter).WriteBatch(values, defLevels, repLevels)
+	return err
+}
+
+// newColumnBuffers returns the buffers of the leaf columns of the parquet schema in the order of the columns
+func (w *Writer) newColumnBuffers(pqSc *schema.Schema) ([]columnBuffer, error) {
+	buffers := make([]columnBuffer, pqSc.NumColumns())
+	for i := range buffers {
+		col := pqSc.Column(i)
+		key := w.columnKey(col)
+		rules := columnCoercion(w.schema, w.coercion, key)
+		switch col.PhysicalType() {
+		case parquet.Types.Boolean:
+			buffers[i] = newTypedColumnBuffer(key, col, rules, boolConverter(rules), writeBoolBatch)
+		case parquet.Types.Int64:
+			field := w.schema.FieldByPath(col.ColumnPath())
+			if !isTimestamp(field) {
+				buffers[i] = newTypedColumnBuffer(key, col, rules, int64Converter(rules), writeInt64Batch)
+				break
+			}
+			b := newTypedColumnBuffer(key, col, rules, w.timestamps.int64Converter(field, rules), writeInt64Batch)
+			b.describe = w.timestamps.overflowError(field, rules)
+			buffers[i] = b
+		case parquet.Types.Int96:
+			field := w.schema.FieldByPath(col.ColumnPath())
+			b := newTypedColumnBuffer(key, col, rules, w.timestamps.int96Converter(field, rules), writeInt96Batch)
+			b.describe = w.timestamps.overflowError(field, rules)
+			buffers[i] = b
+		case parquet.Types.Double:
+			buffers[i] = newTypedColumnBuffer(key, col, rules, float64Converter(rules), writeFloat64Batch)
+		case parquet.Types.ByteArray:
+			b := newTypedColumnBuffer(key, col, rules, byteArrayConverter(rules), writeByteArrayBatch)
+			b.dataSize = byteArraySize
+			buffers[i] = b
+		default:
+			return nil, fmt.Errorf("%w: column(%v) of type(%v)", ErrTypeNotSupported, col.Path(), col.PhysicalType())
+		}
+	}
+	return buffers, nil
+}
+
+// columnKey returns the JSON key of the top level field that contains the column
+func (w *Writer) columnKey(col *schema.Column) string {
+	path := col.ColumnPath()
+	field := w.schema.FieldByPath(path[:1])
+	if field == nil {
+		return path[0]
+	}
+	return field.GetKey()
+}
+
+// The converters of the values of a field by the coercion rules of the field (see columnCoercion)
+
+func boolConverter(rules Coercion) ValueConverter[bool] {
+	if rules&CoerceBoolStrings != 0 {
+		return tfJson.CoerceToBool
+	}
+	return tfJson.ToBool
+}
+
+func int64Converter(rules Coercion) ValueConverter[int64] {
+	var convert ValueConverter[int64] = tfJson.ToInt64
+	if rules&CoerceNumbers != 0 {
+		convert = tfJson.IntegralToInt64
+	}
+	if rules&CoerceNumericStrings != 0 {
+		convert = numericStrings(convert)
+	}
+	return convert
+}
+
+func float64Converter(rules Coercion) ValueConverter[float64] {
+	if rules&CoerceNumericStrings != 0 {
+		return numericStrings[float64](tfJson.ToFloat64)
+	}
+	return tfJson.ToFloat64
+}
+
+func byteArrayConverter(rules Coercion) ValueConverter[parquet.ByteArray] {
+	if rules&CoerceStringify != 0 {
+		return coerceToByteArray
+	}
+	return toByteArray
+}
+
+func byteArraySize(v parquet.ByteArray) int64 {
+	return int64(len(v))
+}
+
+func toByteArray(v interface{}) (parquet.ByteArray, bool) {
+	if raw, ok := v.(tfJson.Raw); ok {
+		return parquet.ByteArray(raw), true
+	}
+	s, ok := tfJson.ToString(v)
+	if !ok {
+		return nil, ok
+	}
+	return parquet.ByteArray(s), true
+}
+
+func coerceToByteArray(v interface{}) (parquet.ByteArray, bool) {
+	s, ok := tfJson.CoerceToString(v)
+	if !ok {
+		return nil, ok
+	}
+	return parquet.ByteArray(s), true
+}
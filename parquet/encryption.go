@@ -0,0 +1,165 @@
+package parquet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// KMS is a key management service that provides the encryption keys by their IDs. The key IDs are
+// stored in the files, so the readers retrieve the keys from the same service.
+type KMS interface {
+	// Key returns the key with the ID, an AES key of 16, 24 or 32 bytes
+	Key(id string) ([]byte, error)
+}
+
+// MemoryKMS is a KMS of the keys held in memory indexed by their IDs, e.g. the keys of a key file or of tests
+type MemoryKMS map[string][]byte
+
+func (m MemoryKMS) Key(id string) ([]byte, error) {
+	key, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: key(%v)", ErrKeyNotFound, id)
+	}
+	return key, nil
+}
+
+// LoadKeyFile reads the keys of a local key file, a JSON object of the key IDs and the base64 encoded keys,
+// e.g. {"footer": "AAECAwQFBgcICQoLDA0ODw==", "pii": "EBESExQVFhcYGRobHB0eHw=="}
+func LoadKeyFile(path string) (MemoryKMS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var encoded map[string]string
+	if err = json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("invalid key file(%v): %w", path, err)
+	}
+	keys := make(MemoryKMS, len(encoded))
+	for id, s := range encoded {
+		key, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key(%v) in key file(%v): %w", id, path, err)
+		}
+		if err = checkKey(id, key); err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+func checkKey(id string, key []byte) error {
+	if len(key) != 16 && len(key) != 24 && len(key) != 32 {
+		return fmt.Errorf("invalid key(%v): the key has %v bytes instead of 16, 24 or 32", id, len(key))
+	}
+	return nil
+}
+
+// Encryption configures the parquet modular encryption of the written files
+type Encryption struct {
+	KMS KMS
+	// FooterKeyID is the ID of the key of the footer, the columns without a column key are encrypted
+	// by the footer key unless there are column keys
+	FooterKeyID string
+	// PlaintextFooter writes the footer unencrypted (signed by the footer key), so the schema and the
+	// metadata of the file can be read by readers without the keys or without encryption support
+	PlaintextFooter bool
+	// ColumnKeyIDs are the IDs of the keys of the top level columns (the column names in the parquet
+	// schema), only these columns are encrypted when set, otherwise all the columns are encrypted by the
+	// footer key
+	ColumnKeyIDs map[string]string
+}
+
+// properties returns the encryption properties of a file, the properties cannot be shared by files
+func (e *Encryption) properties(pqSc *schema.Schema) (*parquet.FileEncryptionProperties, error) {
+	if e.KMS == nil || e.FooterKeyID == "" {
+		return nil, errors.New("encryption without the KMS or the footer key")
+	}
+	footerKey, err := e.key(e.FooterKeyID)
+	if err != nil {
+		return nil, err
+	}
+	opts := []parquet.EncryptOption{parquet.WithFooterKeyID(e.FooterKeyID)}
+	if e.PlaintextFooter {
+		opts = append(opts, parquet.WithPlaintextFooter())
+	}
+	if len(e.ColumnKeyIDs) > 0 {
+		// a misspelled column would be written unencrypted
+		for column := range e.ColumnKeyIDs {
+			if pqSc.Root().FieldIndexByName(column) < 0 {
+				return nil, fmt.Errorf("encrypted column(%v) not in the schema", column)
+			}
+		}
+		columns := make(parquet.ColumnPathToEncryptionPropsMap)
+		for i := range pqSc.NumColumns() {
+			path := pqSc.Column(i).ColumnPath()
+			id, ok := e.ColumnKeyIDs[path[0]]
+			if !ok {
+				continue
+			}
+			key, err := e.key(id)
+			if err != nil {
+				return nil, err
+			}
+			columns[path.String()] = parquet.NewColumnEncryptionProperties(path.String(),
+				parquet.WithKey(string(key)), parquet.WithKeyID(id))
+		}
+		opts = append(opts, parquet.WithEncryptedColumns(columns))
+	}
+	return parquet.NewFileEncryptionProperties(string(footerKey), opts...), nil
+}
+
+func (e *Encryption) key(id string) ([]byte, error) {
+	key, err := e.KMS.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	return key, checkKey(id, key)
+}
+
+// keyRetriever retrieves the keys of the key IDs stored in the files from the KMS
+type keyRetriever struct {
+	kms KMS
+}
+
+func (r keyRetriever) GetKey(keyMetadata []byte) string {
+	key, err := r.kms.Key(string(keyMetadata))
+	if err != nil {
+		// the reader fails on the missing key
+		return ""
+	}
+	return string(key)
+}
+
+// DecryptionProperties returns the properties of the readers of the files encrypted with the keys of the KMS,
+// the keys are retrieved by the key IDs stored in the files. Plaintext files are read as well.
+func DecryptionProperties(kms KMS) *parquet.FileDecryptionProperties {
+	return parquet.NewFileDecryptionProperties(parquet.WithKeyRetriever(keyRetriever{kms: kms}), parquet.WithPlaintextAllowed())
+}
+
+// OpenFile opens a parquet file, the encrypted files are decrypted with the keys of the KMS (nil for plaintext files).
+// The columns of the file encrypted with keys missing from the KMS cannot be read.
+func OpenFile(path string, kms KMS) (reader *file.Reader, err error) {
+	var opts []file.ReadOption
+	if kms != nil {
+		props := parquet.NewReaderProperties(nil)
+		props.FileDecryptProps = DecryptionProperties(kms)
+		opts = append(opts, file.WithReadProps(props))
+	}
+	// the parquet library panics on a missing key
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to decrypt file(%v): %v", path, r)
+		}
+	}()
+	return file.OpenParquetFile(path, false, opts...)
+}
@@ -0,0 +1,173 @@
+package parquet_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+var testKMS = parquet.MemoryKMS{
+	"footer": []byte("0123456789abcdef"),
+	"pii":    []byte("fedcba9876543210fedcba98"),
+}
+
+func testEncryptionSchema(t *testing.T) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"id":   json.Number("1"),
+		"ssn":  "123-45-6789",
+		"tags": []interface{}{"a"},
+	}))
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("2")}))
+	return sb.Schema()
+}
+
+func writeEncrypted(t *testing.T, path string, enc parquet.Encryption, opts ...parquet.WriterOption) *parquet.Summary {
+	wr, err := parquet.NewWriter(path, 10, testEncryptionSchema(t), append(opts, parquet.WithEncryption(enc))...)
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "ssn": "123-45-6789", "tags": []interface{}{"a", "b"}}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "ssn": "987-65-4321"}))
+	require.NoError(t, wr.Close())
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	return summary
+}
+
+func readSSN(t *testing.T, reader *file.Reader) []pq.ByteArray {
+	col, err := reader.RowGroup(0).Column(reader.MetaData().Schema.ColumnIndexByName("ssn"))
+	require.NoError(t, err)
+	values := make([]pq.ByteArray, 2)
+	_, n, err := col.(*file.ByteArrayColumnChunkReader).ReadBatch(2, values, make([]int16, 2), nil)
+	require.NoError(t, err)
+	return values[:n]
+}
+
+func TestWriteEncryptedFooter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.parquet")
+	summary := writeEncrypted(t, path, parquet.Encryption{KMS: testKMS, FooterKeyID: "footer"})
+	require.EqualValues(t, 2, summary.Rows)
+	require.Len(t, summary.Columns, 3)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "PARE", string(data[len(data)-4:]))
+	require.NotContains(t, string(data), "123-45-6789")
+
+	// the footer cannot be read without the key
+	_, err = parquet.OpenFile(path, nil)
+	require.Error(t, err)
+	_, err = parquet.OpenFile(path, parquet.MemoryKMS{"pii": testKMS["pii"]})
+	require.Error(t, err)
+
+	reader, err := parquet.OpenFile(path, testKMS)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.EqualValues(t, 2, reader.NumRows())
+	require.Equal(t, []pq.ByteArray{pq.ByteArray("123-45-6789"), pq.ByteArray("987-65-4321")}, readSSN(t, reader))
+}
+
+func TestWritePlaintextFooterColumnKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.parquet")
+	summary := writeEncrypted(t, path, parquet.Encryption{
+		KMS:             testKMS,
+		FooterKeyID:     "footer",
+		PlaintextFooter: true,
+		ColumnKeyIDs:    map[string]string{"ssn": "pii"},
+	})
+	// the sizes of the columns encrypted with column keys are read from the decrypted footer
+	require.Len(t, summary.Columns, 3)
+	for _, c := range summary.Columns {
+		require.Positive(t, c.CompressedBytes, c.Path)
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "PAR1", string(data[len(data)-4:]))
+	require.NotContains(t, string(data), "123-45-6789")
+
+	// the schema and the plaintext columns are read without the keys
+	reader, err := parquet.OpenFile(path, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, reader.NumRows())
+	require.Equal(t, 3, reader.MetaData().Schema.NumColumns())
+	col, err := reader.RowGroup(0).Column(reader.MetaData().Schema.ColumnIndexByName("id"))
+	require.NoError(t, err)
+	ids := make([]int64, 2)
+	_, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(2, ids, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, ids[:n])
+	require.NoError(t, reader.Close())
+
+	reader, err = parquet.OpenFile(path, testKMS)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, []pq.ByteArray{pq.ByteArray("123-45-6789"), pq.ByteArray("987-65-4321")}, readSSN(t, reader))
+}
+
+func TestWriteEncryptionErrors(t *testing.T) {
+	sc := testEncryptionSchema(t)
+	dir := t.TempDir()
+	for _, enc := range []parquet.Encryption{
+		{FooterKeyID: "footer"},
+		{KMS: testKMS},
+		{KMS: testKMS, FooterKeyID: "unknown"},
+		{KMS: parquet.MemoryKMS{"short": []byte("key")}, FooterKeyID: "short"},
+		{KMS: testKMS, FooterKeyID: "footer", ColumnKeyIDs: map[string]string{"ssn": "unknown"}},
+		// a misspelled column is not written unencrypted
+		{KMS: testKMS, FooterKeyID: "footer", ColumnKeyIDs: map[string]string{"SSN": "pii"}},
+	} {
+		_, err := parquet.NewWriter(filepath.Join(dir, "out.parquet"), 10, sc, parquet.WithEncryption(enc))
+		require.Error(t, err, "%+v", enc)
+	}
+	_, err := parquet.NewWriter(filepath.Join(dir, "out.parquet"), 10, sc, parquet.WithEncryption(parquet.Encryption{
+		KMS: testKMS, FooterKeyID: "unknown",
+	}))
+	require.ErrorIs(t, err, parquet.ErrKeyNotFound)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"footer": "MDEyMzQ1Njc4OWFiY2RlZg==", "pii": "ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4"}`), 0o600))
+	kms, err := parquet.LoadKeyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, testKMS, kms)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"footer": "c2hvcnQ="}`), 0o600))
+	_, err = parquet.LoadKeyFile(path)
+	require.ErrorContains(t, err, "instead of 16, 24 or 32")
+	require.NoError(t, os.WriteFile(path, []byte(`{"footer": "not base64"}`), 0o600))
+	_, err = parquet.LoadKeyFile(path)
+	require.Error(t, err)
+}
+
+func TestRollingWriterEncryption(t *testing.T) {
+	dir := t.TempDir()
+	enc := parquet.Encryption{KMS: testKMS, FooterKeyID: "footer", ColumnKeyIDs: map[string]string{"ssn": "pii"}}
+	for _, arrow := range []bool{false, true} {
+		wr, err := parquet.NewRollingWriter(filepath.Join(dir, "out-{index}.parquet"), 1, 0, 10, testEncryptionSchema(t),
+			parquet.WithEncryption(enc), parquet.WithArrowWriter(arrow))
+		require.NoError(t, err)
+		require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "ssn": "123-45-6789"}))
+		require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "ssn": "987-65-4321"}))
+		require.NoError(t, wr.Close())
+
+		// each file is encrypted with its own properties
+		files := wr.Manifest().Files
+		require.Len(t, files, 2)
+		for i, ssn := range []string{"123-45-6789", "987-65-4321"} {
+			require.NotNil(t, files[i].Summary)
+			require.EqualValues(t, 1, files[i].Summary.Rows)
+			reader, err := parquet.OpenFile(files[i].Path, testKMS)
+			require.NoError(t, err)
+			require.Equal(t, []pq.ByteArray{pq.ByteArray(ssn)}, readSSN(t, reader))
+			require.NoError(t, reader.Close())
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package parquet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	tfJson "github.com/thermofisher/json2parquet/json"
+)
+
+// InferencePart is a part of the input data whose schema is inferred independently
+type InferencePart struct {
+	// Origin describes the part in merge conflicts
+	Origin string
+	// Open creates the reader of the part, the reader is closed after the part is inferred
+	Open func() (*tfJson.Reader, error)
+}
+
+// FileParts splits the file into at most n parts aligned to line boundaries, see tfJson.SplitFile.
+// The configure function (if not nil) is called for each created reader.
+func FileParts(file string, n int, minSize int64, configure func(*tfJson.Reader)) ([]InferencePart, error) {
+	sections, err := tfJson.SplitFile(file, n, minSize)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]InferencePart, 0, len(sections))
+	for _, section := range sections {
+		parts = append(parts, InferencePart{
+			Origin: fmt.Sprintf("%v[%v:%v]", file, section.Offset, section.Offset+section.Length),
+			Open: func() (*tfJson.Reader, error) {
+				r, errO := tfJson.NewFromFileSection(file, section)
+				if errO != nil {
+					return nil, errO
+				}
+				if configure != nil {
+					configure(r)
+				}
+				return r, nil
+			},
+		})
+	}
+	return parts, nil
+}
+
+func (sb *SchemaBuilder) inferPart(ctx context.Context, part InferencePart) (*Schema, error) {
+	reader, err := part.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	partSb := NewSchemaBuilder()
+	partSb.SetOverrides(sb.overrides)
+	partSb.SetOrigin(part.Origin)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	err = reader.Read(ctx, func(data tfJson.NDJsonRecord) {
+		if errU := partSb.UpdateSchema(data); errU != nil {
+			cancel(errU)
+		}
+	})
+	if err != nil {
+		if cause := context.Cause(ctx); cause != nil {
+			return nil, cause
+		}
+		return nil, err
+	}
+	return partSb.Schema(), nil
+}
+
+// UpdateSchemaParallel infers the schema of each part by a separate builder, at most workers parts are
+// inferred at the same time. The inferred schemas are merged in the order of the parts, so the result is
+// the same as if the parts were inferred sequentially by a single builder.
+func (sb *SchemaBuilder) UpdateSchemaParallel(ctx context.Context, parts []InferencePart, workers int) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	schemas := make([]*Schema, len(parts))
+	sem := make(chan struct{}, max(workers, 1))
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+			sc, err := sb.inferPart(ctx, part)
+			if err != nil {
+				cancel(fmt.Errorf("failed to infer schema of %v: %w", part.Origin, err))
+				return
+			}
+			schemas[i] = sc
+		}()
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	for _, sc := range schemas {
+		if err := sb.Merge(sc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
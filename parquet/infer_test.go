@@ -0,0 +1,87 @@
+package parquet_test
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func testInferSchemaFile(t *testing.T, file string) string {
+	reader, err := tfJson.NewFromFile(file)
+	require.NoError(t, err)
+	defer reader.Close()
+	sb := parquet.NewSchemaBuilder()
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+	sc, err := sb.Schema().Schema()
+	require.NoError(t, err)
+	return sc.String()
+}
+
+func TestUpdateSchemaParallel(t *testing.T) {
+	for _, file := range []string{"../data/mixed.ndjson", "../data/integers.ndjson", "../data/booleans.ndjson"} {
+		expected := testInferSchemaFile(t, file)
+		for _, n := range []int{1, 2, 3, 7, 16} {
+			parts, err := parquet.FileParts(file, n, 0, nil)
+			require.NoError(t, err)
+			require.Len(t, parts, n)
+
+			sb := parquet.NewSchemaBuilder()
+			require.NoError(t, sb.UpdateSchemaParallel(context.Background(), parts, 4))
+			sc, err := sb.Schema().Schema()
+			require.NoError(t, err)
+			require.Equal(t, expected, sc.String(), "%v split into %v parts", file, n)
+		}
+	}
+}
+
+func TestUpdateSchemaParallelError(t *testing.T) {
+	lines := make([]string, 0, 100)
+	for range 99 {
+		lines = append(lines, `{"value": 1}`)
+	}
+	lines = append(lines, `{"value": true}`)
+	file := filepath.Join(t.TempDir(), "mismatch.ndjson")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o600))
+
+	parts, err := parquet.FileParts(file, 4, 0, nil)
+	require.NoError(t, err)
+	sb := parquet.NewSchemaBuilder()
+	err = sb.UpdateSchemaParallel(context.Background(), parts, 2)
+	require.ErrorIs(t, err, parquet.ErrTypeMismatch)
+}
+
+func TestUpdateSchemaParallelReadError(t *testing.T) {
+	// the line is longer than the buffer of the reader
+	lines := []string{`{"value": 1}`, `{"value": "` + strings.Repeat("x", 70<<10) + `"}`, `{"value": 2}`}
+	file := filepath.Join(t.TempDir(), "long.ndjson")
+	require.NoError(t, os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0o600))
+
+	parts, err := parquet.FileParts(file, 2, 0, nil)
+	require.NoError(t, err)
+	sb := parquet.NewSchemaBuilder()
+	err = sb.UpdateSchemaParallel(context.Background(), parts, 2)
+	require.ErrorIs(t, err, bufio.ErrTooLong)
+}
+
+func TestSplitFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lines.ndjson")
+	require.NoError(t, os.WriteFile(file, []byte("{\"a\":1}\n{\"a\":22}\n{\"a\":333}\n{\"a\":4444}"), 0o600))
+
+	sections, err := tfJson.SplitFile(file, 3, 0)
+	require.NoError(t, err)
+	require.Equal(t, []tfJson.Section{{Offset: 0, Length: 17}, {Offset: 17, Length: 10}, {Offset: 27, Length: 10}}, sections)
+
+	sections, err = tfJson.SplitFile(file, 10, 15)
+	require.NoError(t, err)
+	require.Equal(t, []tfJson.Section{{Offset: 0, Length: 27}, {Offset: 27, Length: 10}}, sections)
+}
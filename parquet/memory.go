@@ -0,0 +1,77 @@
+package parquet
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	tfJson "github.com/thermofisher/json2parquet/json"
+)
+
+// approximate sizes of the Go values the decoded JSON records are made of
+const (
+	interfaceSize    = 16
+	stringSize       = 16
+	sliceSize        = 24
+	mapSize          = 48
+	mapEntryOverhead = 8 // share of the buckets of the map per entry besides the key and the value
+)
+
+// recordSize returns the approximate memory size of a decoded JSON record, it is used to account
+// the rows kept in memory by the writers (see WithMemoryLimit)
+func recordSize(data map[string]interface{}) int64 {
+	size := int64(mapSize)
+	for key, v := range data {
+		size += stringSize + int64(len(key)) + mapEntryOverhead + valueSize(v)
+	}
+	return size
+}
+
+func valueSize(v interface{}) int64 {
+	size := int64(interfaceSize)
+	switch v := v.(type) {
+	case string:
+		size += stringSize + int64(len(v))
+	case json.Number:
+		size += stringSize + int64(len(v))
+	case tfJson.Raw:
+		size += stringSize + int64(len(v))
+	case []interface{}:
+		size += sliceSize
+		for _, e := range v {
+			size += valueSize(e)
+		}
+	case map[string]interface{}:
+		size += recordSize(v)
+	}
+	return size
+}
+
+// countingAllocator counts the bytes currently allocated by the Arrow builders of a writer
+type countingAllocator struct {
+	memory.Allocator
+	size atomic.Int64
+}
+
+func newCountingAllocator() *countingAllocator {
+	return &countingAllocator{Allocator: memory.DefaultAllocator}
+}
+
+func (a *countingAllocator) Allocate(size int) []byte {
+	a.size.Add(int64(size))
+	return a.Allocator.Allocate(size)
+}
+
+func (a *countingAllocator) Reallocate(size int, b []byte) []byte {
+	a.size.Add(int64(size - len(b)))
+	return a.Allocator.Reallocate(size, b)
+}
+
+func (a *countingAllocator) Free(b []byte) {
+	a.size.Add(-int64(len(b)))
+	a.Allocator.Free(b)
+}
+
+func (a *countingAllocator) allocated() int64 {
+	return a.size.Load()
+}
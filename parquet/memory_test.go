@@ -0,0 +1,118 @@
+package parquet_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+const testMemoryLimit = 64 << 10
+
+func testMemoryRecords(rows int) []map[string]interface{} {
+	records := make([]map[string]interface{}, rows)
+	for i := range records {
+		records[i] = map[string]interface{}{
+			"id":   json.Number(strconv.Itoa(i)),
+			"text": strings.Repeat("text!", 20) + strconv.Itoa(i),
+		}
+	}
+	return records
+}
+
+func TestMemoryLimit(t *testing.T) {
+	records := testMemoryRecords(5000)
+	sb := parquet.NewSchemaBuilder()
+	for _, record := range records[:10] {
+		require.NoError(t, sb.UpdateSchema(record))
+	}
+	sc := sb.Schema()
+	dir := t.TempDir()
+	for _, arrow := range []bool{false, true} {
+		write := func(opts ...parquet.WriterOption) *parquet.Summary {
+			opts = append(opts, parquet.WithArrowWriter(arrow), parquet.WithRowGroupBytes(1<<30))
+			wr, err := parquet.NewRollingWriter(filepath.Join(dir, "out.parquet"), 0, 0, 100000, sc, opts...)
+			require.NoError(t, err)
+			for _, record := range records {
+				require.NoError(t, wr.Write(record))
+			}
+			require.NoError(t, wr.Close())
+			summary := wr.Manifest().Files[0].Summary
+			require.NotNil(t, summary)
+			require.EqualValues(t, len(records), summary.Rows)
+			return summary
+		}
+
+		summary := write()
+		require.Equal(t, 1, summary.RowGroups, "arrow %v", arrow)
+		require.Greater(t, summary.PeakBufferedBytes, int64(testMemoryLimit), "arrow %v", arrow)
+
+		// the row groups are written early, the builders of the Arrow writer grow by doubling
+		summary = write(parquet.WithMemoryLimit(testMemoryLimit))
+		require.Greater(t, summary.RowGroups, 1, "arrow %v", arrow)
+		require.Less(t, summary.PeakBufferedBytes, int64(2*testMemoryLimit), "arrow %v", arrow)
+		if !arrow {
+			require.Less(t, summary.PeakBufferedBytes, int64(testMemoryLimit+1024))
+		}
+	}
+}
+
+func TestMemoryLimitSortedRowGroups(t *testing.T) {
+	sc := testSortSchema(t)
+	columns, err := parquet.ParseSortColumns("tenant,ts:desc")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sorted.parquet")
+	wr, err := parquet.NewWriter(path, 100000, sc, parquet.WithSortingColumns(columns...),
+		parquet.WithSortedRowGroups(100000), parquet.WithMemoryLimit(testMemoryLimit))
+	require.NoError(t, err)
+	records := testSortRecords(5000)
+	for _, record := range records {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.Close())
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	require.Less(t, summary.PeakBufferedBytes, int64(testMemoryLimit+1024))
+
+	reader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Greater(t, reader.NumRowGroups(), 1)
+	require.EqualValues(t, len(records), reader.NumRows())
+	requireSortingColumns(t, reader)
+}
+
+func TestMemoryLimitSortingWriter(t *testing.T) {
+	sc := testSortSchema(t)
+	columns, err := parquet.ParseSortColumns("tenant,ts:desc")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sorted.parquet")
+	tempDir := t.TempDir()
+	wr, err := parquet.NewWriter(path, 100, sc, parquet.WithSortingColumns(columns...))
+	require.NoError(t, err)
+	sw, err := parquet.NewSortingWriter(wr, sc, columns, 1000000, tempDir)
+	require.NoError(t, err)
+	sw.SetMemoryLimit(testMemoryLimit)
+	records := testSortRecords(5000)
+	for _, record := range records {
+		require.NoError(t, sw.Write(record))
+	}
+	// the rows are spilled before the buffer size is reached
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+	require.NoError(t, sw.Close())
+	require.Less(t, sw.PeakBufferedBytes(), int64(testMemoryLimit+1024))
+
+	reader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, testExpectedOrder(records), slices.Concat(readSeq(t, reader)...))
+}
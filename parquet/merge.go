@@ -0,0 +1,114 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/thermofisher/json2parquet/log"
+)
+
+func originOrUnknown(origin string) string {
+	if origin == "" {
+		return "unknown origin"
+	}
+	return origin
+}
+
+// fieldForMerge returns a copy of the field of the merged schema with the name of the JSON key
+func fieldForMerge(key string, field Node) Node {
+	field = field.Clone()
+	if field.GetName() != key {
+		field.SetName(key)
+	}
+	return field
+}
+
+func (sb *SchemaBuilder) mergeField(key string, field, otherField Node, otherOrigin string) (Node, error) {
+	if sb.overrides.overridesColumn(key) {
+		return field, nil
+	}
+	if field.IsEqual(otherField) {
+		return field, nil
+	}
+	action, updatedField := checkOrUpdateInferedType(field, otherField)
+	if action == inferedTypeActionUpgrade {
+		log.Logger().Debugf("changed inferred field %v to %v from %v", field.Print(), updatedField.Print(), otherOrigin)
+		updatedField.SetRepetition(field.GetRepetition())
+		return updatedField, nil
+	}
+	if action == inferedTypeActionNone {
+		return field, nil
+	}
+	return nil, fmt.Errorf("%w: field(%v) from %v does not match field(%v) from %v", ErrTypeMismatch,
+		otherField.Print(), originOrUnknown(otherOrigin), field.Print(), originOrUnknown(sb.origin))
+}
+
+func (sb *SchemaBuilder) setOptional(key string, field Node) {
+	if override, ok := sb.overrides[key]; ok && override.ForceRepetition {
+		return
+	}
+	field.SetRepetition(parquet.Repetitions.Optional)
+	delete(sb.requiredFields, key)
+}
+
+// Merge combines a schema inferred independently (e.g. from another file or another part of the
+// same file) into the builder. The same widening rules as for the inference of a single record
+// are applied and fields missing on one side become optional, so the result is the same as if
+// all the records were inferred by the builder. Overrides of the builder take precedence over
+// the merged fields.
+func (sb *SchemaBuilder) Merge(other *Schema) error {
+	if other == nil {
+		return errors.New("no schema to merge")
+	}
+	if other.records == 0 {
+		return nil
+	}
+	fields := make(map[string]Node, len(sb.fields))
+	for key, field := range sb.fields {
+		fields[key] = field.Clone()
+	}
+	for key, otherField := range other.fields {
+		otherField = fieldForMerge(key, otherField)
+		field, ok := fields[key]
+		if !ok {
+			if sb.records > 0 {
+				sb.setOptional(key, otherField)
+			}
+			fields[key] = otherField
+			continue
+		}
+		otherRepetition := otherField.GetRepetition()
+		merged, err := sb.mergeField(key, field, otherField, other.origin)
+		if err != nil {
+			return err
+		}
+		if otherRepetition == parquet.Repetitions.Optional {
+			sb.setOptional(key, merged)
+		}
+		fields[key] = merged
+	}
+	if sb.records > 0 {
+		for key, field := range fields {
+			if _, ok := other.fields[key]; !ok {
+				sb.setOptional(key, field)
+			}
+		}
+	}
+
+	sb.fields = fields
+	sb.requiredFields = make(map[string]struct{}, len(fields))
+	for key, field := range fields {
+		if field.GetRepetition() == parquet.Repetitions.Required {
+			sb.requiredFields[key] = struct{}{}
+		}
+	}
+	sb.firstRun = false
+	sb.records += other.records
+	if sb.origin == "" {
+		sb.origin = other.origin
+	} else if other.origin != "" {
+		sb.origin += ", " + other.origin
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package parquet_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func testInferSchema(t *testing.T, json string, origin string) *parquet.Schema {
+	var input bytes.Buffer
+	input.WriteString(json)
+	reader, err := tfJson.New(&input)
+	require.NoError(t, err)
+
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOrigin(origin)
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+	return sb.Schema()
+}
+
+func TestMergeSchemas(t *testing.T) {
+	lines := []string{
+		`{"id": 1, "value": 1, "text": "SGVsbG8=", "tags": [], "time": "2006-01-02T15:04:05Z"}`,
+		`{"id": 2, "value": 2, "text": "AQID", "tags": []}`,
+		`{"id": 3, "value": 3.5, "text": "Hello there", "tags": [1], "time": "2006-01-02T15:04:05Z"}`,
+		`{"id": 4, "value": 4, "tags": [2.5], "new": true, "time": "not a time"}`,
+		``,
+		`{"id": 5, "value": 5, "text": "General Kenobi", "tags": [], "new": false}`,
+	}
+	expected := testInferSchema(t, strings.Join(lines, "\n"), "all")
+	expSchema, err := expected.Schema()
+	require.NoError(t, err)
+
+	for split := range len(lines) + 1 {
+		sb := parquet.NewSchemaBuilder()
+		require.NoError(t, sb.Merge(testInferSchema(t, strings.Join(lines[:split], "\n"), "first")))
+		require.NoError(t, sb.Merge(testInferSchema(t, strings.Join(lines[split:], "\n"), "second")))
+		merged := sb.Schema()
+		require.Equal(t, expected.NumRecords(), merged.NumRecords())
+		sc, errS := merged.Schema()
+		require.NoError(t, errS)
+		require.Equal(t, expSchema.String(), sc.String(), "split at %v", split)
+	}
+}
+
+func TestMergeSchemasConflict(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOrigin("hour-01.ndjson")
+	require.NoError(t, sb.UpdateSchema(tfJson.NDJsonRecord{"value": true}))
+	err := sb.Merge(testInferSchema(t, `{"value": 1}`, "hour-02.ndjson"))
+	require.ErrorIs(t, err, parquet.ErrTypeMismatch)
+	require.Contains(t, err.Error(), "from hour-02.ndjson")
+	require.Contains(t, err.Error(), "from hour-01.ndjson")
+}
+
+func TestMergeNilSchema(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	require.Error(t, sb.Merge(nil))
+}
@@ -0,0 +1,190 @@
+package parquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+)
+
+// SchemaMetadataKey is the key of the footer metadata entry with the serialized inferred schema
+const SchemaMetadataKey = "json2parquet.schema"
+
+// FieldDescription is the serialized form of a field of the inferred schema, unlike the parquet
+// schema it keeps the JSON key and the extended type of the field
+type FieldDescription struct {
+	Name         string `json:"name"`
+	Key          string `json:"key,omitempty"`
+	Type         string `json:"type,omitempty"`
+	LogicalType  string `json:"logical_type,omitempty"`
+	ExtendedType string `json:"extended_type,omitempty"`
+	Repetition   string `json:"repetition"`
+	Overridden   bool   `json:"overridden,omitempty"`
+	// ForcedRepetition is set when the repetition of the field was forced by its override
+	ForcedRepetition bool               `json:"forced_repetition,omitempty"`
+	Fields           []FieldDescription `json:"fields,omitempty"`
+}
+
+// describeField returns the description of the field with the path (see Overrides)
+func (s *Schema) describeField(path string, n Node) FieldDescription {
+	d := FieldDescription{
+		Name:       n.GetName(),
+		Repetition: n.GetRepetition().String(),
+	}
+	if override, ok := s.overrides[path]; ok {
+		d.Overridden = true
+		d.ForcedRepetition = override.ForceRepetition
+	}
+	if n.GetKey() != n.GetName() {
+		d.Key = n.GetKey()
+	}
+	if n.GetType() != NodeTypeNone {
+		d.Type = n.GetType().String()
+	}
+	if n.GetLogicalType() != LogicalTypeNone {
+		d.LogicalType = n.GetLogicalType().String()
+	}
+	if n.GetExtendedType() != ExtendedTypeNone {
+		d.ExtendedType = n.GetExtendedType().String()
+	}
+	if ln, ok := n.(*ListNode); ok {
+		d.Fields = []FieldDescription{s.describeField(elementPath(path), ln.Element())}
+		return d
+	}
+	fields, _ := n.Fields()
+	for _, f := range fields {
+		d.Fields = append(d.Fields, s.describeField(path+"."+f.GetKey(), f))
+	}
+	return d
+}
+
+// Describe returns the description of the top level fields sorted by the column name
+func (s *Schema) Describe() []FieldDescription {
+	fields := make([]FieldDescription, 0, len(s.fields))
+	for key, field := range s.fields {
+		fields = append(fields, s.describeField(key, field))
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// SchemaDescription returns the inferred schema stored in the footer metadata of a parquet file,
+// nil if the file does not contain it
+func SchemaDescription(kv metadata.KeyValueMetadata) ([]FieldDescription, error) {
+	value := kv.FindValue(SchemaMetadataKey)
+	if value == nil {
+		return nil, nil
+	}
+	var fields []FieldDescription
+	if err := json.Unmarshal([]byte(*value), &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// NewSchemaFromDescription restores the inferred schema from its description (see SchemaDescription),
+// the schema describes the records of a written file
+func NewSchemaFromDescription(fields []FieldDescription, records int64) (*Schema, error) {
+	s := &Schema{
+		fields:  make(map[string]Node, len(fields)),
+		records: records,
+	}
+	for _, d := range fields {
+		field, err := s.describedNode(d.key(), d)
+		if err != nil {
+			return nil, err
+		}
+		s.fields[field.GetKey()] = field
+	}
+	return s, nil
+}
+
+// key returns the JSON key of the described field
+func (d FieldDescription) key() string {
+	if d.Key != "" {
+		return d.Key
+	}
+	return d.Name
+}
+
+// describedNode returns the field of the description with the path (see Overrides), the overrides of the
+// described field and of its nested fields are restored
+func (s *Schema) describedNode(path string, d FieldDescription) (Node, error) {
+	repetition, ok := parseEnum(d.Repetition, parquet.Repetitions.Required, parquet.Repetitions.Optional, parquet.Repetitions.Repeated)
+	if !ok {
+		return nil, fmt.Errorf("invalid repetition(%v) of field(%v)", d.Repetition, d.Name)
+	}
+	typ, ok := parseEnum(d.Type, NodeTypeBoolean, NodeTypeInt64, NodeTypeFloat64, NodeTypeByteArray)
+	if !ok && d.Type != "" {
+		return nil, fmt.Errorf("%w: type(%v) of field(%v)", ErrTypeNotSupported, d.Type, d.Name)
+	}
+	logicalType, ok := parseEnum(d.LogicalType, LogicalTypeUTF8, LogicalTypeList, LogicalTypeJSON)
+	if !ok && d.LogicalType != "" {
+		return nil, fmt.Errorf("%w: logical type(%v) of field(%v)", ErrTypeNotSupported, d.LogicalType, d.Name)
+	}
+	extendedType, ok := parseEnum(d.ExtendedType, ExtendedTypeRFC3339, ExtendedTypeEpochMillis)
+	if !ok && d.ExtendedType != "" {
+		return nil, fmt.Errorf("%w: extended type(%v) of field(%v)", ErrTypeNotSupported, d.ExtendedType, d.Name)
+	}
+	key := d.key()
+	var field Node
+	switch {
+	case logicalType == LogicalTypeList:
+		if len(d.Fields) != 1 {
+			return nil, fmt.Errorf("invalid list field(%v) with %v elements", d.Name, len(d.Fields))
+		}
+		element, err := s.describedNode(elementPath(path), d.Fields[0])
+		if err != nil {
+			return nil, err
+		}
+		field = NewListNode(key, repetition, element)
+	case typ == NodeTypeNone:
+		fields := make([]Node, 0, len(d.Fields))
+		for _, f := range d.Fields {
+			child, err := s.describedNode(path+"."+f.key(), f)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, child)
+		}
+		field = NewGroupNode(key, repetition, fields, logicalType)
+	default:
+		var err error
+		if field, err = getNodeByType(key, typ, logicalType, extendedType, repetition, nil); err != nil {
+			return nil, err
+		}
+	}
+	if key != d.Name {
+		field.SetName(d.Name)
+	}
+	if d.Overridden {
+		if s.overrides == nil {
+			s.overrides = make(Overrides)
+		}
+		override := Override{
+			Type:         field.GetType(),
+			LogicalType:  field.GetLogicalType(),
+			ExtendedType: field.GetExtendedType(),
+		}
+		if d.ForcedRepetition {
+			override.Repetition, override.ForceRepetition = repetition, true
+		}
+		s.overrides[path] = override
+	}
+	return field, nil
+}
+
+// parseEnum returns the value whose string is s
+func parseEnum[T fmt.Stringer](s string, values ...T) (T, bool) {
+	for _, v := range values {
+		if v.String() == s {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,171 @@
+package parquet
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+)
+
+// ColumnNamesMetadataKey is the key of the footer metadata entry that maps renamed columns
+// to their original JSON keys. The value is a JSON object of strings indexed by the column
+// name, e.g. {"user_name":"User Name","user_info.first_name":"userInfo.firstName"}, with an
+// entry for each top level column whose name differs from its JSON key (the keys of flattened
+// objects are joined by the separator). The format is stable, readers of older files rely on it.
+const ColumnNamesMetadataKey = "json2parquet.column_names"
+
+// ColumnNaming configures how JSON keys are converted to parquet column names.
+// The zero value keeps the JSON keys verbatim.
+type ColumnNaming struct {
+	// Sanitize replaces characters that are not accepted by Spark, Hive and Athena
+	// (everything except letters, digits and underscores) with an underscore and
+	// makes sure the name does not start with a digit or is empty
+	Sanitize bool
+	// SnakeCase converts camelCase and PascalCase names to lower snake_case
+	SnakeCase bool
+	// Separator is the separator of the keys of flattened nested objects (see
+	// tfJson.Reader.SetFlattenNestedObjects). Each component of a flattened key is converted
+	// separately and the components are joined by the sanitized separator, so a nested key is
+	// named like the same key at the top level.
+	Separator string
+}
+
+func (cn ColumnNaming) enabled() bool {
+	return cn.Sanitize || cn.SnakeCase
+}
+
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				sb.WriteRune('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+	return sb.String()
+}
+
+func isValidNameRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// replaceInvalidRunes replaces the characters that are not valid in a name with an underscore
+func replaceInvalidRunes(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		if !isValidNameRune(r) {
+			r = '_'
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func sanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	sanitized := replaceInvalidRunes(name)
+	if sanitized[0] >= '0' && sanitized[0] <= '9' {
+		return "_" + sanitized
+	}
+	return sanitized
+}
+
+// ColumnName returns the column name for a JSON key without collision handling, the components
+// of a flattened key are converted separately (see Separator)
+func (cn ColumnNaming) ColumnName(key string) string {
+	if cn.Separator == "" || !strings.Contains(key, cn.Separator) {
+		return cn.componentName(key)
+	}
+	components := strings.Split(key, cn.Separator)
+	for i, component := range components {
+		components[i] = cn.componentName(component)
+	}
+	separator := cn.Separator
+	if cn.Sanitize {
+		separator = replaceInvalidRunes(separator)
+	}
+	return strings.Join(components, separator)
+}
+
+func (cn ColumnNaming) componentName(key string) string {
+	name := key
+	if cn.SnakeCase {
+		name = toSnakeCase(name)
+	}
+	if cn.Sanitize {
+		name = sanitizeName(name)
+	}
+	return name
+}
+
+// ResolveColumnNames maps each key to a unique column name. Names are compared case
+// insensitively, because Hive and Athena ignore the case of column names. Keys that
+// are left unchanged by the policy keep their names, unless they differ only by case
+// from another unchanged key. The other keys are processed in lexicographic order and
+// colliding names get a numeric suffix, so the result does not depend on the order in
+// which the keys were encountered.
+func (cn ColumnNaming) ResolveColumnNames(keys []string) map[string]string {
+	candidates := make(map[string]string, len(keys))
+	var unchanged, changed []string
+	for _, key := range keys {
+		name := cn.ColumnName(key)
+		candidates[key] = name
+		if name == key {
+			unchanged = append(unchanged, key)
+			continue
+		}
+		changed = append(changed, key)
+	}
+	sort.Strings(unchanged)
+	sort.Strings(changed)
+
+	names := make(map[string]string, len(keys))
+	used := make(map[string]struct{}, len(keys))
+	// the unchanged names are reserved before any suffixed name is generated, so a suffix
+	// never takes the name of an unchanged key
+	var renamed []string
+	for _, key := range unchanged {
+		if _, ok := used[strings.ToLower(key)]; ok {
+			renamed = append(renamed, key)
+			continue
+		}
+		used[strings.ToLower(key)] = struct{}{}
+		names[key] = key
+	}
+	for _, key := range append(renamed, changed...) {
+		name := candidates[key]
+		for i := 2; ; i++ {
+			if _, ok := used[strings.ToLower(name)]; !ok {
+				break
+			}
+			name = candidates[key] + "_" + strconv.Itoa(i)
+		}
+		used[strings.ToLower(name)] = struct{}{}
+		names[key] = name
+	}
+	return names
+}
+
+// OriginalColumnNames returns the mapping of renamed columns to their original JSON keys
+// stored in the footer metadata of a parquet file
+func OriginalColumnNames(kv metadata.KeyValueMetadata) (map[string]string, error) {
+	names := make(map[string]string)
+	value := kv.FindValue(ColumnNamesMetadataKey)
+	if value == nil {
+		return names, nil
+	}
+	if err := json.Unmarshal([]byte(*value), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
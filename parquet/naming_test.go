@@ -0,0 +1,119 @@
+package parquet_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		key    string
+		naming parquet.ColumnNaming
+		want   string
+	}{
+		{key: "a.b c", naming: parquet.ColumnNaming{}, want: "a.b c"},
+		{key: "a.b c", naming: parquet.ColumnNaming{Sanitize: true}, want: "a_b_c"},
+		{key: "1st", naming: parquet.ColumnNaming{Sanitize: true}, want: "_1st"},
+		{key: "", naming: parquet.ColumnNaming{Sanitize: true}, want: "_"},
+		{key: "größe", naming: parquet.ColumnNaming{Sanitize: true}, want: "gr__e"},
+		{key: "userID", naming: parquet.ColumnNaming{SnakeCase: true}, want: "user_id"},
+		{key: "HTTPServerName", naming: parquet.ColumnNaming{SnakeCase: true}, want: "http_server_name"},
+		{key: "already_snake", naming: parquet.ColumnNaming{SnakeCase: true}, want: "already_snake"},
+		{key: "Event Time", naming: parquet.ColumnNaming{Sanitize: true, SnakeCase: true}, want: "event_time"},
+		// the components of flattened keys are converted separately
+		{key: "userInfo.1st", naming: parquet.ColumnNaming{Sanitize: true, Separator: "."}, want: "userInfo__1st"},
+		{key: "userInfo.firstName", naming: parquet.ColumnNaming{SnakeCase: true, Separator: "."}, want: "user_info.first_name"},
+		{key: "a/b..c", naming: parquet.ColumnNaming{Sanitize: true, Separator: "."}, want: "a_b___c"},
+		{key: "UserInfo__ID", naming: parquet.ColumnNaming{SnakeCase: true, Separator: "__"}, want: "user_info__id"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, tt.naming.ColumnName(tt.key), tt.key)
+	}
+}
+
+func TestResolveColumnNames(t *testing.T) {
+	naming := parquet.ColumnNaming{Sanitize: true}
+	keys := []string{"a.b", "a_b", "a b", "Name", "name"}
+	want := map[string]string{
+		"a_b":  "a_b",
+		"Name": "Name",
+		"name": "name_2",
+		"a b":  "a_b_2",
+		"a.b":  "a_b_3",
+	}
+	require.Equal(t, want, naming.ResolveColumnNames(keys))
+	// the result does not depend on the order of the keys
+	reversed := []string{"name", "Name", "a b", "a_b", "a.b"}
+	require.Equal(t, want, naming.ResolveColumnNames(reversed))
+}
+
+func TestResolveColumnNamesUnchangedPriority(t *testing.T) {
+	naming := parquet.ColumnNaming{Sanitize: true}
+	// a_b_2 needs no change and keeps its name although the suffix of a_B and a.b would take it
+	keys := []string{"a.b", "a_b_2", "a_B", "A_b"}
+	want := map[string]string{
+		"A_b":   "A_b",
+		"a_b_2": "a_b_2",
+		"a_B":   "a_B_3",
+		"a.b":   "a_b_4",
+	}
+	require.Equal(t, want, naming.ResolveColumnNames(keys))
+}
+
+func TestFlattenedColumnNamesSchema(t *testing.T) {
+	reader, err := tfJson.New(strings.NewReader(`{"userInfo": {"firstName": "Dan", "1st": true}, "user_info": 1}`))
+	require.NoError(t, err)
+	reader.SetFlattenNestedObjects(".", 0)
+	sb := parquet.NewSchemaBuilder()
+	sb.SetColumnNaming(parquet.ColumnNaming{Sanitize: true, SnakeCase: true, Separator: "."})
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+	sc := sb.Schema()
+	require.Equal(t, map[string]string{
+		"user_info_first_name": "userInfo.firstName",
+		"user_info__1st":       "userInfo.1st",
+	}, sc.OriginalNames())
+}
+
+func TestSanitizedColumnNamesSchema(t *testing.T) {
+	jsonStr := `{"user.id": 1, "User Name": "Dan", "1st": true}` + "\n" +
+		`{"user.id": 2, "User Name": "Eva", "user_id": 3}`
+
+	sb := parquet.NewSchemaBuilder()
+	sb.SetColumnNaming(parquet.ColumnNaming{Sanitize: true, SnakeCase: true})
+	parquetReader, _ := testWriteJSONWith(t, jsonStr, "naming.parquet", sb, nil, 1000)
+	schema := `required group field_id=-1 schema {
+  optional boolean field_id=-1 _1st;
+  optional int64 field_id=-1 user_id;
+  required int64 field_id=-1 user_id_2;
+  required byte_array field_id=-1 user_name (String);
+}
+`
+	require.Equal(t, schema, parquetReader.MetaData().Schema.String())
+	require.Equal(t, int64(2), parquetReader.MetaData().NumRows)
+
+	names, err := parquet.OriginalColumnNames(parquetReader.MetaData().KeyValueMetadata())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"_1st":      "1st",
+		"user_name": "User Name",
+		"user_id_2": "user.id",
+	}, names)
+
+	rgr := parquetReader.RowGroup(0)
+	col, err := rgr.Column(2)
+	require.NoError(t, err)
+	values := make([]int64, 2)
+	_, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(2, values, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []int64{1, 2}, values)
+}
@@ -44,6 +44,7 @@ const (
 	LogicalTypeNone LogicalType = iota
 	LogicalTypeUTF8
 	LogicalTypeList
+	LogicalTypeJSON
 )
 
 func (lt LogicalType) ToLogicalType() schema.LogicalType {
@@ -54,6 +55,8 @@ func (lt LogicalType) ToLogicalType() schema.LogicalType {
 		return &schema.StringLogicalType{}
 	case LogicalTypeList:
 		return &schema.ListLogicalType{}
+	case LogicalTypeJSON:
+		return &schema.JSONLogicalType{}
 	}
 	return &schema.UnknownLogicalType{}
 }
@@ -68,6 +71,7 @@ type ExtendedType int
 const (
 	ExtendedTypeNone ExtendedType = iota
 	ExtendedTypeRFC3339
+	ExtendedTypeEpochMillis
 )
 
 func (et ExtendedType) String() string {
@@ -76,6 +80,8 @@ func (et ExtendedType) String() string {
 		return "NONE"
 	case ExtendedTypeRFC3339:
 		return "RFC3339"
+	case ExtendedTypeEpochMillis:
+		return "EPOCH_MILLIS"
 	}
 	return "UNKNOWN"
 }
@@ -84,6 +90,8 @@ var ErrOpNotSupported = errors.New("not supported")
 
 type Node interface {
 	GetName() string
+	SetName(name string)
+	GetKey() string
 	GetType() NodeType
 	GetRepetition() parquet.Repetition
 	SetRepetition(repetition parquet.Repetition)
@@ -94,12 +102,14 @@ type Node interface {
 	Fields() ([]Node, error)
 	FieldByPath(name []string) Node
 	IsEqual(Node) bool
+	Clone() Node
 
 	Node() (schema.Node, error)
 }
 
 type node struct {
 	name         string
+	key          string // JSON key of the field, empty if equal to name
 	typ          NodeType
 	repetition   parquet.Repetition
 	logicalType  LogicalType
@@ -110,6 +120,22 @@ func (bn *node) GetName() string {
 	return bn.name
 }
 
+// SetName changes the column name, the JSON key of the field is preserved
+func (bn *node) SetName(name string) {
+	if bn.key == "" {
+		bn.key = bn.name
+	}
+	bn.name = name
+}
+
+// GetKey returns the JSON key the values of the field are read from
+func (bn *node) GetKey() string {
+	if bn.key == "" {
+		return bn.name
+	}
+	return bn.key
+}
+
 func (bn *node) GetType() NodeType {
 	return bn.typ
 }
@@ -159,6 +185,11 @@ func (bn *node) Node() (schema.Node, error) {
 	return nil, ErrOpNotSupported
 }
 
+func (bn *node) Clone() Node {
+	c := *bn
+	return &c
+}
+
 type TemporaryNode struct {
 	node
 }
@@ -178,6 +209,11 @@ func (tn *TemporaryNode) Node() (schema.Node, error) {
 	return nil, ErrOpNotSupported
 }
 
+func (tn *TemporaryNode) Clone() Node {
+	c := *tn
+	return &c
+}
+
 type BooleanNode struct {
 	node
 }
@@ -196,6 +232,11 @@ func (bn *BooleanNode) Node() (schema.Node, error) {
 	return schema.NewBooleanNode(bn.name, bn.repetition, -1), nil
 }
 
+func (bn *BooleanNode) Clone() Node {
+	c := *bn
+	return &c
+}
+
 type Int64Node struct {
 	node
 }
@@ -210,10 +251,25 @@ func NewInt64Node(name string, repetition parquet.Repetition) *Int64Node {
 	}
 }
 
+// NewEpochMillisNode creates a node for integers storing milliseconds since the Unix epoch
+func NewEpochMillisNode(name string, repetition parquet.Repetition) *Int64Node {
+	n := NewInt64Node(name, repetition)
+	n.extendedType = ExtendedTypeEpochMillis
+	return n
+}
+
 func (bn *Int64Node) Node() (schema.Node, error) {
+	if bn.extendedType == ExtendedTypeEpochMillis {
+		return TimestampType{}.node(bn)
+	}
 	return schema.NewInt64Node(bn.name, bn.repetition, -1), nil
 }
 
+func (bn *Int64Node) Clone() Node {
+	c := *bn
+	return &c
+}
+
 type Float64Node struct {
 	node
 }
@@ -232,6 +288,11 @@ func (bn *Float64Node) Node() (schema.Node, error) {
 	return schema.NewFloat64Node(bn.name, bn.repetition, -1), nil
 }
 
+func (bn *Float64Node) Clone() Node {
+	c := *bn
+	return &c
+}
+
 type ByteArrayNode struct {
 	node
 }
@@ -250,18 +311,26 @@ func NewByteArrayNode(name string, repetition parquet.Repetition, logicalType Lo
 
 func (bn *ByteArrayNode) Node() (schema.Node, error) {
 	if bn.extendedType == ExtendedTypeRFC3339 {
-		return schema.NewPrimitiveNodeLogical(bn.name, bn.repetition,
-			schema.NewTimestampLogicalType(true, schema.TimeUnitNanos), parquet.Types.Int64, 0, -1)
+		return TimestampType{}.node(bn)
 	}
 	if bn.logicalType == LogicalTypeUTF8 {
 		return schema.NewPrimitiveNodeLogical(bn.name, bn.repetition,
 			schema.StringLogicalType{}, parquet.Types.ByteArray, 0, -1)
 	}
+	if bn.logicalType == LogicalTypeJSON {
+		return schema.NewPrimitiveNodeLogical(bn.name, bn.repetition,
+			schema.JSONLogicalType{}, parquet.Types.ByteArray, 0, -1)
+	}
 	return schema.NewByteArrayNode(bn.name, bn.repetition, -1), nil
 }
 
+func (bn *ByteArrayNode) Clone() Node {
+	c := *bn
+	return &c
+}
+
 func (bn *ByteArrayNode) SetLogicalType(lt LogicalType) error {
-	if lt == LogicalTypeNone || lt == LogicalTypeUTF8 {
+	if lt == LogicalTypeNone || lt == LogicalTypeUTF8 || lt == LogicalTypeJSON {
 		bn.logicalType = lt
 		return nil
 	}
@@ -297,8 +366,22 @@ func NewGroupNode(name string, repetition parquet.Repetition, fields []Node, log
 	}
 }
 
+func cloneFields(fields []Node) []Node {
+	cloned := make([]Node, 0, len(fields))
+	for _, f := range fields {
+		cloned = append(cloned, f.Clone())
+	}
+	return cloned
+}
+
+func (gn *GroupNode) Clone() Node {
+	c := *gn
+	c.fields = cloneFields(gn.fields)
+	return &c
+}
+
 func (gn *GroupNode) FieldByPath(path []string) Node {
-	if path == nil {
+	if len(path) == 0 {
 		return gn
 	}
 	name := path[0]
@@ -376,18 +459,9 @@ type ListNode struct {
 	GroupNode
 }
 
+// NewListNode creates a list of the element, the element is repeated in the inferred schema
+// (see Node and LegacyNode for its layout in the parquet schema)
 func NewListNode(name string, repetition parquet.Repetition, element Node) *ListNode {
-	// According to https://github.com/apache/parquet-format/blob/master/LogicalTypes.md#lists:
-	// LIST must always annotate a 3-level structure:
-	// <list-repetition> group <name> (LIST) {
-	//   repeated group list {
-	// 	   <element-repetition> <element-type> element;
-	//   }
-	// }
-	//
-	// but the arrow-go/parquet library doesn't seem to support this 3-level structure
-	//
-	// middleNode := NewGroupNode("list", parquet.Repetitions.Repeated, []Node{element}, LogicalTypeNone)
 	return &ListNode{
 		GroupNode: GroupNode{
 			node: node{
@@ -401,6 +475,61 @@ func NewListNode(name string, repetition parquet.Repetition, element Node) *List
 	}
 }
 
+// Node returns the three-level list of the parquet specification with optional elements
+// (https://github.com/apache/parquet-format/blob/master/LogicalTypes.md#lists):
+//
+//	<list-repetition> group <name> (LIST) {
+//	  repeated group list {
+//	    optional <element-type> element;
+//	  }
+//	}
+func (ln *ListNode) Node() (schema.Node, error) {
+	return ln.layoutNode(Layout{})
+}
+
+// LegacyNode returns the two-level list written by the earlier versions, it is read inconsistently
+// by the query engines:
+//
+//	<list-repetition> group <name> (LIST) {
+//	  repeated <element-type> element;
+//	}
+func (ln *ListNode) LegacyNode() (schema.Node, error) {
+	return ln.layoutNode(Layout{Lists: ListLayoutLegacy})
+}
+
+func (ln *ListNode) layoutNode(layout Layout) (schema.Node, error) {
+	element := ln.Element()
+	if layout.Lists == ListLayoutThreeLevel {
+		element = element.Clone()
+		element.SetRepetition(parquet.Repetitions.Optional)
+	}
+	elementNode, err := layoutNode(element, layout)
+	if err != nil {
+		return nil, err
+	}
+	if layout.Lists == ListLayoutThreeLevel {
+		elementNode, err = schema.NewGroupNode("list", parquet.Repetitions.Repeated, schema.FieldList{elementNode}, -1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return schema.NewGroupNodeLogical(ln.name, ln.repetition, schema.FieldList{elementNode}, ln.logicalType.ToLogicalType(), -1)
+}
+
+// FieldByPath returns the element by the path of the column in either layout
+func (ln *ListNode) FieldByPath(path []string) Node {
+	if len(path) > 1 && path[0] == "list" {
+		path = path[1:]
+	}
+	return ln.GroupNode.FieldByPath(path)
+}
+
+func (ln *ListNode) Clone() Node {
+	c := *ln
+	c.fields = cloneFields(ln.fields)
+	return &c
+}
+
 func (ln *ListNode) Element() Node {
 	return ln.fields[0]
 }
@@ -408,3 +537,31 @@ func (ln *ListNode) Element() Node {
 func (ln *ListNode) SetElement(element Node) {
 	ln.fields[0] = element
 }
+
+// Layout selects how the fields of the schema are written in the parquet schema, the zero value is
+// the default layout
+type Layout struct {
+	Lists      ListLayout
+	Timestamps TimestampType
+}
+
+// layoutNode returns the parquet node of the field in the layout
+func layoutNode(n Node, layout Layout) (schema.Node, error) {
+	if ln, ok := n.(*ListNode); ok {
+		return ln.layoutNode(layout)
+	}
+	if isTimestamp(n) {
+		return layout.Timestamps.node(n)
+	}
+	return n.Node()
+}
+
+// ListLayout is the layout of the list columns in the parquet schema
+type ListLayout int
+
+const (
+	// ListLayoutThreeLevel is the three-level layout of the parquet specification with optional elements
+	ListLayoutThreeLevel ListLayout = iota
+	// ListLayoutLegacy is the two-level layout with repeated elements written by the earlier versions
+	ListLayoutLegacy
+)
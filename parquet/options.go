@@ -0,0 +1,505 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+// WriterOption configures a Writer, the column of a column option (e.g. WithColumnCompression) must be a
+// top level column of the schema
+type WriterOption func(*writerConfig)
+
+type compressionConfig struct {
+	codec compress.Compression
+	level int
+}
+
+type writerConfig struct {
+	compression       *compressionConfig
+	columnCompression map[string]compressionConfig
+
+	dictionary          *bool
+	columnDictionary    map[string]bool
+	dictionaryPageLimit int64
+	columnEncoding      map[string]parquet.Encoding
+	autoEncoding        bool
+	dataPageSize        int64
+	dataPageVersion     parquet.DataPageVersion
+
+	rowGroupBytes int64
+	columnWorkers int
+	memoryLimit   int64
+
+	statistics          *bool
+	columnStatistics    map[string]bool
+	maxStatisticsSize   int64
+	pageIndex           bool
+	bloomFilters        map[string]float64
+	bloomFilterMaxBytes int64
+
+	metadata []keyValue
+
+	sortColumns   []SortColumn
+	sortRowGroups int
+
+	arrow      bool
+	listLayout ListLayout
+	timestamps TimestampType
+	coercion   Coercion
+	encryption *Encryption
+}
+
+type keyValue struct {
+	key, value string
+}
+
+func newWriterConfig(opts []WriterOption) *writerConfig {
+	c := &writerConfig{
+		columnCompression: make(map[string]compressionConfig),
+		columnDictionary:  make(map[string]bool),
+		columnEncoding:    make(map[string]parquet.Encoding),
+		columnStatistics:  make(map[string]bool),
+		bloomFilters:      make(map[string]float64),
+		autoEncoding:      true,
+		dataPageVersion:   parquet.DataPageV1,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// WithCompression sets the compression codec and level of all columns,
+// use compress.DefaultCompressionLevel for the default level of the codec
+func WithCompression(codec compress.Compression, level int) WriterOption {
+	return func(c *writerConfig) {
+		c.compression = &compressionConfig{codec: codec, level: level}
+	}
+}
+
+// WithColumnCompression sets the compression codec and level of a top level column (the
+// column name in the parquet schema), it takes precedence over WithCompression
+func WithColumnCompression(column string, codec compress.Compression, level int) WriterOption {
+	return func(c *writerConfig) {
+		c.columnCompression[column] = compressionConfig{codec: codec, level: level}
+	}
+}
+
+// WithDictionary enables or disables dictionary encoding of all columns (enabled by default)
+func WithDictionary(enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.dictionary = &enabled
+	}
+}
+
+// WithColumnDictionary enables or disables dictionary encoding of a top level column,
+// it takes precedence over WithDictionary and over disabling of the dictionary by an encoding
+func WithColumnDictionary(column string, enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.columnDictionary[column] = enabled
+	}
+}
+
+// WithDictionaryPageSizeLimit sets the size limit of a dictionary page, when the dictionary grows
+// larger the column falls back to its non-dictionary encoding
+func WithDictionaryPageSizeLimit(limit int64) WriterOption {
+	return func(c *writerConfig) {
+		c.dictionaryPageLimit = limit
+	}
+}
+
+// WithColumnEncoding sets the encoding of a top level column, dictionary encoding of the column
+// is disabled unless enabled by WithColumnDictionary (then the encoding is used as the fallback)
+func WithColumnEncoding(column string, encoding parquet.Encoding) WriterOption {
+	return func(c *writerConfig) {
+		c.columnEncoding[column] = encoding
+	}
+}
+
+// WithAutoEncoding enables or disables automatic selection of encodings (enabled by default):
+// timestamp columns are delta encoded and floating point columns are byte stream split.
+// Encodings set by WithColumnEncoding take precedence.
+func WithAutoEncoding(enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.autoEncoding = enabled
+	}
+}
+
+// WithDataPageSize sets the target size of data pages in bytes
+func WithDataPageSize(size int64) WriterOption {
+	return func(c *writerConfig) {
+		c.dataPageSize = size
+	}
+}
+
+// WithDataPageVersion sets the version of data pages
+func WithDataPageVersion(version parquet.DataPageVersion) WriterOption {
+	return func(c *writerConfig) {
+		c.dataPageVersion = version
+	}
+}
+
+// WithRowGroupBytes sets the target size of row groups in bytes, the batches written by the writer
+// are collected in a row group until its estimated encoded size reaches the target. When the size
+// is not set (or not positive) each batch is written as a separate row group.
+func WithRowGroupBytes(size int64) WriterOption {
+	return func(c *writerConfig) {
+		c.rowGroupBytes = size
+	}
+}
+
+// WithColumnWorkers sets the number of columns of a row group encoded and compressed in parallel,
+// the output is the same for any number of workers (1 by default)
+func WithColumnWorkers(workers int) WriterOption {
+	return func(c *writerConfig) {
+		c.columnWorkers = workers
+	}
+}
+
+// WithMemoryLimit limits the approximate memory size of the data buffered by the writer: the rows of
+// the next batch (and of the next sorted row group) and the encoded row group not yet written to the
+// output. When the limit is reached the row group is written early, so the row groups can be smaller
+// than the target size (see WithRowGroupBytes). The size is not limited when it is not positive.
+func WithMemoryLimit(size int64) WriterOption {
+	return func(c *writerConfig) {
+		c.memoryLimit = size
+	}
+}
+
+// WithStatistics enables or disables min/max statistics of all columns (enabled by default)
+func WithStatistics(enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.statistics = &enabled
+	}
+}
+
+// WithColumnStatistics enables or disables min/max statistics of a top level column,
+// it takes precedence over WithStatistics
+func WithColumnStatistics(column string, enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.columnStatistics[column] = enabled
+	}
+}
+
+// WithMaxStatisticsSize sets the length in bytes the min/max values of the column chunks are truncated to.
+// A longer min value (e.g. of long strings) is truncated to its prefix and a longer max value to its prefix
+// incremented in the last byte (or in the last character of strings), so they still bound the values of the
+// column chunk, and both are marked as inexact. The statistics of the pages and the page index are not
+// truncated. Truncated statistics are not supported by the ArrowWriter.
+func WithMaxStatisticsSize(size int64) WriterOption {
+	return func(c *writerConfig) {
+		c.maxStatisticsSize = size
+	}
+}
+
+// WithPageIndex enables or disables writing of the column and offset indexes of all columns
+func WithPageIndex(enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.pageIndex = enabled
+	}
+}
+
+// WithBloomFilter writes bloom filters of a top level column with the target false positive
+// probability, the size of the filter is selected by the number of distinct values of the
+// column in each row group
+func WithBloomFilter(column string, fpp float64) WriterOption {
+	return func(c *writerConfig) {
+		c.bloomFilters[column] = fpp
+	}
+}
+
+// WithBloomFilterMaxBytes sets the maximum size of a bloom filter in bytes
+func WithBloomFilterMaxBytes(size int64) WriterOption {
+	return func(c *writerConfig) {
+		c.bloomFilterMaxBytes = size
+	}
+}
+
+// WithKeyValueMetadata adds an entry to the footer metadata of the file, entries known only
+// after the data is written can be added by Writer.AppendKeyValueMetadata
+func WithKeyValueMetadata(key, value string) WriterOption {
+	return func(c *writerConfig) {
+		c.metadata = append(c.metadata, keyValue{key: key, value: value})
+	}
+}
+
+// WithSortingColumns records in the row group metadata that the rows are sorted by the columns, the rows
+// must be written in this order (see SortingWriter) or sorted by the writer (see WithSortedRowGroups)
+func WithSortingColumns(columns ...SortColumn) WriterOption {
+	return func(c *writerConfig) {
+		c.sortColumns = columns
+	}
+}
+
+// WithSortedRowGroups sorts the rows of each row group by the sorting columns, the rows are collected
+// in memory and a row group is written when the number of rows is reached (or earlier when the target
+// size of row groups is reached, see WithRowGroupBytes). The rows are converted when the row group is
+// written, so a row that cannot be converted fails the writer.
+func WithSortedRowGroups(rows int) WriterOption {
+	return func(c *writerConfig) {
+		c.sortRowGroups = rows
+	}
+}
+
+// withoutColumns removes the sorting columns and the column options of the columns that are not in the
+// file, e.g. the partition columns that have the same value in all the rows of the file
+func withoutColumns(keys ...string) WriterOption {
+	return func(c *writerConfig) {
+		c.sortColumns = slices.DeleteFunc(slices.Clone(c.sortColumns), func(column SortColumn) bool {
+			return slices.Contains(keys, column.Key)
+		})
+		for _, key := range keys {
+			delete(c.columnCompression, key)
+			delete(c.columnDictionary, key)
+			delete(c.columnEncoding, key)
+			delete(c.columnStatistics, key)
+			delete(c.bloomFilters, key)
+		}
+	}
+}
+
+// WithArrowWriter selects the ArrowWriter for the files created by the RollingWriter and the
+// PartitionedWriter, the rows are converted to Arrow records and the Arrow schema is embedded in the files
+func WithArrowWriter(enabled bool) WriterOption {
+	return func(c *writerConfig) {
+		c.arrow = enabled
+	}
+}
+
+// WithListLayout sets the layout of the list columns, the three-level layout of the parquet specification
+// by default. ListLayoutLegacy writes the two-level layout of the earlier versions, it is not supported
+// by the ArrowWriter.
+func WithListLayout(layout ListLayout) WriterOption {
+	return func(c *writerConfig) {
+		c.listLayout = layout
+	}
+}
+
+// WithTimestampType sets the parquet type of the timestamp columns, by default the timestamps are
+// adjusted to UTC in nanoseconds (RFC3339 strings) or milliseconds (integers with milliseconds since
+// the Unix epoch)
+func WithTimestampType(typ TimestampType) WriterOption {
+	return func(c *writerConfig) {
+		c.timestamps = typ
+	}
+}
+
+// layout returns the layout of the parquet schema
+func (c *writerConfig) layout() Layout {
+	return Layout{Lists: c.listLayout, Timestamps: c.timestamps}
+}
+
+// WithCoercion sets the rules that convert the values that do not match the type of their column,
+// by default (CoerceStrict) a value that cannot be converted fails the row
+func WithCoercion(rules Coercion) WriterOption {
+	return func(c *writerConfig) {
+		c.coercion = rules
+	}
+}
+
+// WithEncryption encrypts the files with the keys of the configuration, the key IDs are stored as the
+// key metadata of the files (see DecryptionProperties)
+func WithEncryption(enc Encryption) WriterOption {
+	return func(c *writerConfig) {
+		c.encryption = &enc
+	}
+}
+
+var encodings = map[string]parquet.Encoding{
+	"plain":                   parquet.Encodings.Plain,
+	"rle":                     parquet.Encodings.RLE,
+	"delta_binary_packed":     parquet.Encodings.DeltaBinaryPacked,
+	"delta_byte_array":        parquet.Encodings.DeltaByteArray,
+	"delta_length_byte_array": parquet.Encodings.DeltaLengthByteArray,
+	"byte_stream_split":       parquet.Encodings.ByteStreamSplit,
+}
+
+// ParseEncoding parses an encoding, one of plain, rle, delta_binary_packed, delta_byte_array,
+// delta_length_byte_array or byte_stream_split
+func ParseEncoding(s string) (parquet.Encoding, error) {
+	encoding, ok := encodings[strings.ToLower(s)]
+	if !ok {
+		return parquet.Encodings.Plain, fmt.Errorf("%w: unknown encoding(%v)", ErrOpNotSupported, s)
+	}
+	return encoding, nil
+}
+
+func isEncodingSupported(encoding parquet.Encoding, typ parquet.Type) bool {
+	switch encoding {
+	case parquet.Encodings.Plain:
+		return true
+	case parquet.Encodings.RLE:
+		return typ == parquet.Types.Boolean
+	case parquet.Encodings.DeltaBinaryPacked:
+		return typ == parquet.Types.Int32 || typ == parquet.Types.Int64
+	case parquet.Encodings.DeltaByteArray, parquet.Encodings.DeltaLengthByteArray:
+		return typ == parquet.Types.ByteArray
+	case parquet.Encodings.ByteStreamSplit:
+		return typ == parquet.Types.Float || typ == parquet.Types.Double ||
+			typ == parquet.Types.Int32 || typ == parquet.Types.Int64
+	}
+	return false
+}
+
+// autoEncoding returns the encoding selected automatically for the column
+func autoEncoding(col *schema.Column) (parquet.Encoding, bool) {
+	if _, ok := col.LogicalType().(schema.TimestampLogicalType); ok {
+		return parquet.Encodings.DeltaBinaryPacked, true
+	}
+	if col.PhysicalType() == parquet.Types.Double || col.PhysicalType() == parquet.Types.Float {
+		return parquet.Encodings.ByteStreamSplit, true
+	}
+	return parquet.Encodings.Plain, false
+}
+
+var compressionCodecs = map[string]compress.Compression{
+	"uncompressed": compress.Codecs.Uncompressed,
+	"snappy":       compress.Codecs.Snappy,
+	"gzip":         compress.Codecs.Gzip,
+	"zstd":         compress.Codecs.Zstd,
+	"brotli":       compress.Codecs.Brotli,
+	"lz4":          compress.Codecs.Lz4Raw,
+}
+
+// ParseCompression parses a compression in the format codec[:level], where codec is one of
+// uncompressed, snappy, gzip, zstd, brotli or lz4
+func ParseCompression(s string) (compress.Compression, int, error) {
+	name, levelStr, hasLevel := strings.Cut(s, ":")
+	codec, ok := compressionCodecs[strings.ToLower(name)]
+	if !ok {
+		return compress.Codecs.Uncompressed, 0, fmt.Errorf("%w: unknown compression codec(%v)", ErrOpNotSupported, name)
+	}
+	level := compress.DefaultCompressionLevel
+	if hasLevel {
+		var err error
+		level, err = strconv.Atoi(levelStr)
+		if err != nil {
+			return compress.Codecs.Uncompressed, 0, fmt.Errorf("invalid compression level(%v): %w", levelStr, err)
+		}
+	}
+	return codec, level, nil
+}
+
+func (c *writerConfig) columnEncodingProperties(col *schema.Column) ([]parquet.WriterProperty, error) {
+	path := col.ColumnPath()
+	encoding, ok := c.columnEncoding[path[0]]
+	if !ok && c.autoEncoding {
+		encoding, ok = autoEncoding(col)
+	}
+	var props []parquet.WriterProperty
+	if ok {
+		if !isEncodingSupported(encoding, col.PhysicalType()) {
+			return nil, fmt.Errorf("%w: encoding(%v) for column(%v) of type(%v)", ErrOpNotSupported, encoding,
+				path, col.PhysicalType())
+		}
+		props = append(props, parquet.WithEncodingPath(path, encoding), parquet.WithDictionaryPath(path, false))
+	}
+	if dict, ok := c.columnDictionary[path[0]]; ok {
+		props = append(props, parquet.WithDictionaryPath(path, dict))
+	}
+	return props, nil
+}
+
+// columnProperties returns the properties of the column set by the options of its top level column
+func (c *writerConfig) columnProperties(col *schema.Column) ([]parquet.WriterProperty, error) {
+	path := col.ColumnPath()
+	var props []parquet.WriterProperty
+	if cc, ok := c.columnCompression[path[0]]; ok {
+		props = append(props, parquet.WithCompressionPath(path, cc.codec), parquet.WithCompressionLevelPath(path, cc.level))
+	}
+	encodingProps, err := c.columnEncodingProperties(col)
+	if err != nil {
+		return nil, err
+	}
+	props = append(props, encodingProps...)
+	if stats, ok := c.columnStatistics[path[0]]; ok {
+		props = append(props, parquet.WithStatsPath(path, stats))
+	}
+	if fpp, ok := c.bloomFilters[path[0]]; ok {
+		if fpp <= 0 || fpp >= 1 {
+			return nil, fmt.Errorf("invalid bloom filter false positive probability(%v) for column(%v)", fpp, path)
+		}
+		props = append(props, parquet.WithBloomFilterEnabledPath(path, true), parquet.WithBloomFilterFPPPath(path, fpp),
+			parquet.WithAdaptiveBloomFilterEnabledPath(path, true))
+	}
+	return props, nil
+}
+
+// checkColumnOption returns an error for a column of the option that is not a top level column of the schema
+func checkColumnOption[V any](pqSc *schema.Schema, option string, columns map[string]V) error {
+	for _, column := range slices.Sorted(maps.Keys(columns)) {
+		if pqSc.Root().FieldIndexByName(column) < 0 {
+			return fmt.Errorf("%v of column(%v) not in the schema", option, column)
+		}
+	}
+	return nil
+}
+
+// properties converts the configuration to the properties of the parquet writer
+func (c *writerConfig) properties(sc *Schema, pqSc *schema.Schema) (*parquet.WriterProperties, error) {
+	props := []parquet.WriterProperty{parquet.WithDataPageVersion(c.dataPageVersion)}
+	if len(c.sortColumns) > 0 {
+		sorting, err := sortingColumns(sc, pqSc, c.sortColumns)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, parquet.WithSortingColumns(sorting))
+	}
+	if c.compression != nil {
+		props = append(props, parquet.WithCompression(c.compression.codec), parquet.WithCompressionLevel(c.compression.level))
+	}
+	if c.dictionary != nil {
+		props = append(props, parquet.WithDictionaryDefault(*c.dictionary))
+	}
+	if c.dictionaryPageLimit > 0 {
+		props = append(props, parquet.WithDictionaryPageSizeLimit(c.dictionaryPageLimit))
+	}
+	if c.dataPageSize > 0 {
+		props = append(props, parquet.WithDataPageSize(c.dataPageSize))
+	}
+	if c.statistics != nil {
+		props = append(props, parquet.WithStats(*c.statistics))
+	}
+	if c.maxStatisticsSize > 0 {
+		// the library omits the longer values instead of truncating them, they are truncated by the writer
+		props = append(props, parquet.WithMaxStatsSize(math.MaxInt32))
+	}
+	if c.bloomFilterMaxBytes > 0 {
+		props = append(props, parquet.WithMaxBloomFilterBytes(c.bloomFilterMaxBytes))
+	}
+	props = append(props, parquet.WithPageIndexEnabled(c.pageIndex))
+	// a misspelled column would be written with the default properties
+	if err := errors.Join(
+		checkColumnOption(pqSc, "compression", c.columnCompression),
+		checkColumnOption(pqSc, "dictionary", c.columnDictionary),
+		checkColumnOption(pqSc, "encoding", c.columnEncoding),
+		checkColumnOption(pqSc, "statistics", c.columnStatistics),
+		checkColumnOption(pqSc, "bloom filter", c.bloomFilters),
+	); err != nil {
+		return nil, err
+	}
+	if c.encryption != nil {
+		encryption, err := c.encryption.properties(pqSc)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, parquet.WithEncryptionProperties(encryption))
+	}
+	for i := range pqSc.NumColumns() {
+		columnProps, err := c.columnProperties(pqSc.Column(i))
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, columnProps...)
+	}
+	return parquet.NewWriterProperties(props...), nil
+}
@@ -0,0 +1,136 @@
+package parquet
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/thermofisher/json2parquet/log"
+)
+
+// size of the buffer of the output of the writer
+const outputBufferSize = 1 << 20
+
+// output is the destination of the parquet data of a writer, the data is written through a buffer
+type output struct {
+	file *os.File // temporary file renamed to path when the output is committed, nil for an io.Writer
+	path string
+	buf  *bufio.Writer
+	sink *countingWriter
+	kms  KMS // keys of the encrypted files, the footer of the written file is decrypted to describe the file
+}
+
+// countingWriter passes the writes to the writer and counts the written bytes
+type countingWriter struct {
+	io.Writer
+	written int64
+	err     error // first error of the writes, the errors of some writes are not returned by the parquet library
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.Writer.Write(p)
+	cw.written += int64(n)
+	if err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+// createOutput creates a temporary file in the directory of the path, so the file at path is either
+// complete or not changed at all
+func createOutput(path string) (*output, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	// the permissions of a file created by os.Create (without umask)
+	if err = f.Chmod(0o644); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+	o := newOutput(f)
+	o.file = f
+	o.path = path
+	return o, nil
+}
+
+// newOutput creates a buffered output to the writer, the writer is not closed by the output
+func newOutput(w io.Writer) *output {
+	buf := bufio.NewWriterSize(w, outputBufferSize)
+	return &output{buf: buf, sink: &countingWriter{Writer: buf}}
+}
+
+// close flushes the buffer and closes the temporary file
+func (o *output) close() error {
+	var errs []error
+	if err := o.buf.Flush(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush parquet output: %w", err))
+	}
+	if o.file != nil {
+		if err := o.file.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync parquet file: %w", err))
+		}
+		if err := o.file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close parquet file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// commit renames the closed temporary file to the path when the data was written successfully
+// (err is nil) and removes it otherwise
+func (o *output) commit(err error) error {
+	if o.file == nil {
+		return err
+	}
+	if err != nil {
+		_ = os.Remove(o.file.Name())
+		return err
+	}
+	if err = os.Rename(o.file.Name(), o.path); err != nil {
+		_ = os.Remove(o.file.Name())
+		return err
+	}
+	return nil
+}
+
+// remove closes and removes the temporary file
+func (o *output) remove() {
+	if o.file != nil {
+		_ = o.file.Close()
+		_ = os.Remove(o.file.Name())
+	}
+}
+
+// summary describes the written file by the metadata of its writer (see file.Writer.FileMetadata). The metadata
+// of the columns encrypted with column keys is encrypted in the metadata of the writer, the file is described by
+// its decrypted footer instead, so the summary of such data written to an io.Writer is not available.
+func (o *output) summary(md *metadata.FileMetaData, err error) *Summary {
+	var summary *Summary
+	if err == nil {
+		summary, err = NewSummary(md)
+	}
+	if err != nil && o.kms != nil && o.file != nil {
+		summary, err = o.readSummary()
+	}
+	if err != nil {
+		log.Logger().Debugf("failed to create summary: %v", err)
+		return nil
+	}
+	return summary
+}
+
+// readSummary describes the committed file by its footer, only the footer is read and decrypted
+func (o *output) readSummary() (*Summary, error) {
+	f, err := OpenFile(o.path, o.kms)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewSummary(f.MetaData())
+}
@@ -0,0 +1,96 @@
+package parquet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/parquet"
+)
+
+// Override forces the type of a field instead of inferring it from the JSON data
+type Override struct {
+	Type         NodeType
+	LogicalType  LogicalType
+	ExtendedType ExtendedType
+	// Repetition is used only if ForceRepetition is set, otherwise the repetition is inferred
+	Repetition      parquet.Repetition
+	ForceRepetition bool
+}
+
+// Overrides are indexed by the path of the field: the JSON key of a top level field (for flattened objects
+// the joined key) followed by [] for each level of list elements, e.g. tags[] for the elements of the list
+// tags. The repetition of list elements cannot be forced.
+type Overrides map[string]Override
+
+// elementPath returns the path of the elements of the list with the path
+func elementPath(path string) string {
+	return path + "[]"
+}
+
+// hasElements returns true if the elements of the list with the path (or of its nested lists) are overridden
+func (o Overrides) hasElements(path string) bool {
+	prefix := elementPath(path)
+	for p := range o {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// overridesColumn returns true if the field with the JSON key or its list elements are overridden
+func (o Overrides) overridesColumn(key string) bool {
+	_, ok := o[key]
+	return ok || o.hasElements(key)
+}
+
+var overrideTypes = map[string]Override{
+	"bool":              {Type: NodeTypeBoolean},
+	"int64":             {Type: NodeTypeInt64},
+	"float64":           {Type: NodeTypeFloat64},
+	"bytes":             {Type: NodeTypeByteArray},
+	"string":            {Type: NodeTypeByteArray, LogicalType: LogicalTypeUTF8},
+	"json":              {Type: NodeTypeByteArray, LogicalType: LogicalTypeJSON},
+	"timestamp_rfc3339": {Type: NodeTypeByteArray, ExtendedType: ExtendedTypeRFC3339},
+	"timestamp_millis":  {Type: NodeTypeInt64, ExtendedType: ExtendedTypeEpochMillis},
+}
+
+// ParseOverride parses an override in the format key=type[:repetition], where key is the path of the field
+// (see Overrides), type is one of bool, int64, float64, bytes, string, json, timestamp_rfc3339 or
+// timestamp_millis and repetition is required or optional
+func ParseOverride(s string) (string, Override, error) {
+	key, spec, ok := strings.Cut(s, "=")
+	if !ok || key == "" {
+		return "", Override{}, fmt.Errorf("invalid override(%v): expected key=type[:repetition]", s)
+	}
+	typ, rep, hasRep := strings.Cut(spec, ":")
+	override, ok := overrideTypes[typ]
+	if !ok {
+		return "", Override{}, fmt.Errorf("%w: invalid override(%v): unknown type(%v)", ErrTypeNotSupported, s, typ)
+	}
+	if hasRep {
+		if strings.HasSuffix(key, "[]") {
+			return "", Override{}, fmt.Errorf("invalid override(%v): the repetition of list elements cannot be forced", s)
+		}
+		switch rep {
+		case "required":
+			override.Repetition = parquet.Repetitions.Required
+		case "optional":
+			override.Repetition = parquet.Repetitions.Optional
+		default:
+			return "", Override{}, fmt.Errorf("invalid override(%v): unknown repetition(%v)", s, rep)
+		}
+		override.ForceRepetition = true
+	}
+	return key, override, nil
+}
+
+func newOverrideNode(key string, override Override, repetition parquet.Repetition) (Node, error) {
+	if override.LogicalType == LogicalTypeList {
+		return nil, fmt.Errorf("%w: cannot override field(%v) with a list", ErrTypeNotSupported, key)
+	}
+	if override.ForceRepetition {
+		repetition = override.Repetition
+	}
+	return getNodeByType(key, override.Type, override.LogicalType, override.ExtendedType, repetition, nil)
+}
@@ -0,0 +1,392 @@
+package parquet
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tfJson "github.com/thermofisher/json2parquet/json"
+)
+
+// DefaultPartitionName is the name of the partition of missing and null values
+const DefaultPartitionName = "__HIVE_DEFAULT_PARTITION__"
+
+// PartitionTransform derives the partition value from the value of the column
+type PartitionTransform int
+
+const (
+	PartitionTransformIdentity PartitionTransform = iota
+	PartitionTransformDay
+	PartitionTransformHour
+)
+
+func (pt PartitionTransform) String() string {
+	switch pt {
+	case PartitionTransformIdentity:
+		return "identity"
+	case PartitionTransformDay:
+		return "day"
+	case PartitionTransformHour:
+		return "hour"
+	}
+	return "unknown"
+}
+
+// PartitionColumn is a column the output is partitioned by
+type PartitionColumn struct {
+	// Key is the JSON key of the column
+	Key       string
+	Transform PartitionTransform
+}
+
+// ParsePartitionColumns parses a comma separated list of partition columns in the format
+// key[:day|hour], the day and hour transforms bucket timestamp columns (RFC3339 strings or
+// milliseconds since the Unix epoch) by the UTC day or hour
+func ParsePartitionColumns(s string) ([]PartitionColumn, error) {
+	var columns []PartitionColumn
+	for _, column := range strings.Split(s, ",") {
+		key, transform, hasTransform := strings.Cut(strings.TrimSpace(column), ":")
+		if key == "" {
+			return nil, fmt.Errorf("invalid partition column(%v)", column)
+		}
+		pc := PartitionColumn{Key: key}
+		if hasTransform {
+			switch strings.ToLower(transform) {
+			case "day":
+				pc.Transform = PartitionTransformDay
+			case "hour":
+				pc.Transform = PartitionTransformHour
+			default:
+				return nil, fmt.Errorf("%w: partition transform(%v)", ErrOpNotSupported, transform)
+			}
+		}
+		columns = append(columns, pc)
+	}
+	return columns, nil
+}
+
+type partitionField struct {
+	PartitionColumn
+	name      string // directory name of the partition
+	extension ExtendedType
+}
+
+func timestampOf(value interface{}, extendedType ExtendedType) (time.Time, bool) {
+	if extendedType == ExtendedTypeEpochMillis {
+		millis, ok := tfJson.CoerceToInt64(value)
+		return time.UnixMilli(millis), ok
+	}
+	// parsed without the nanoseconds since the epoch, which overflow outside of the years 1677-2262
+	s, ok := tfJson.ToString(value)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// value returns the partition value of the record
+func (pf partitionField) value(data map[string]interface{}) (string, error) {
+	v, ok := data[pf.Key]
+	if !ok || v == nil {
+		return DefaultPartitionName, nil
+	}
+	if pf.Transform == PartitionTransformIdentity {
+		s, ok := tfJson.CoerceToString(v)
+		if !ok {
+			return "", fmt.Errorf("cannot partition by value(%T) of column(%v)", v, pf.Key)
+		}
+		if s == "" {
+			return DefaultPartitionName, nil
+		}
+		return s, nil
+	}
+	t, ok := timestampOf(v, pf.extension)
+	if !ok {
+		return "", fmt.Errorf("cannot partition by value(%v) of column(%v): not a timestamp", v, pf.Key)
+	}
+	if pf.Transform == PartitionTransformDay {
+		return t.UTC().Format("2006-01-02"), nil
+	}
+	return t.UTC().Format("2006-01-02-15"), nil
+}
+
+// escapePartitionValue escapes the characters of the partition value that are not allowed in a path
+// (the same characters as Hive escapes) and the braces of the file name template
+func escapePartitionValue(value string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c < 0x20 || c == 0x7f || strings.IndexByte("\"#%'*/:=?\\{}[]^", c) >= 0 {
+			fmt.Fprintf(&sb, "%%%02X", c)
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+type partition struct {
+	dir     string
+	values  map[string]string
+	writer  *RollingWriter
+	files   int // number of files written by the closed writers of the partition
+	element *list.Element
+}
+
+// PartitionedWriter writes the rows to Hive style partition directories (e.g. out/region=eu/part-0000.parquet).
+// At most maxOpen partitions are written at the same time, the least recently used partition is closed
+// when another partition is opened and a new file is started when it is opened again.
+type PartitionedWriter struct {
+	dir          string
+	fileTemplate string
+	maxRows      int64
+	maxBytes     int64
+	maxOpen      int
+	batchSize    uint
+	schema       *Schema
+	opts         []WriterOption
+	fields       []partitionField
+
+	partitions map[string]*partition
+	open       *list.List // open partitions, the most recently used first
+	metadata   []keyValue // entries added to the open partitions and to the partitions opened after them
+	manifest   Manifest
+	closed     bool
+}
+
+// partitionSchema returns the schema of the files, the columns of the identity partitions are removed
+// unless they are kept. The partition fields are returned in the order of the columns.
+func partitionSchema(sc *Schema, columns []PartitionColumn, keepColumns bool) (*Schema, []partitionField, error) {
+	fields := make([]partitionField, 0, len(columns))
+	var removed []string
+	for _, column := range columns {
+		field, ok := sc.fields[column.Key]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown partition column(%v)", column.Key)
+		}
+		if field.GetType() == NodeTypeNone {
+			return nil, nil, fmt.Errorf("%w: partition by list or group column(%v)", ErrOpNotSupported, column.Key)
+		}
+		pf := partitionField{PartitionColumn: column, name: field.GetName(), extension: field.GetExtendedType()}
+		if column.Transform == PartitionTransformIdentity {
+			removed = append(removed, column.Key)
+		} else {
+			if pf.extension != ExtendedTypeRFC3339 && pf.extension != ExtendedTypeEpochMillis {
+				return nil, nil, fmt.Errorf("%w: %v transform of column(%v) that is not a timestamp", ErrOpNotSupported,
+					column.Transform, column.Key)
+			}
+			pf.name += "_" + column.Transform.String()
+		}
+		fields = append(fields, pf)
+	}
+	if keepColumns {
+		return sc, fields, nil
+	}
+	fileSchema := sc.Without(removed...)
+	if len(fileSchema.fields) == 0 {
+		return nil, nil, errors.New("no columns left in the file schema after removing the partition columns")
+	}
+	return fileSchema, fields, nil
+}
+
+// removedColumns returns the keys of the columns of the identity partitions
+func removedColumns(fields []partitionField) []string {
+	var keys []string
+	for _, f := range fields {
+		if f.Transform == PartitionTransformIdentity {
+			keys = append(keys, f.Key)
+		}
+	}
+	return keys
+}
+
+// NewPartitionedWriter creates a writer of partition directories in dir, the files of a partition are named
+// by the file template and split by maxRows and maxBytes (see NewRollingWriter), the template must contain
+// the index placeholder. The columns of the identity partitions are removed from the schema of the files
+// unless keepColumns is set, the timestamp columns of the day and hour partitions are kept.
+func NewPartitionedWriter(dir, fileTemplate string, columns []PartitionColumn, keepColumns bool, maxOpen int,
+	maxRows, maxBytes int64, batchSize uint, sc *Schema, opts ...WriterOption,
+) (*PartitionedWriter, error) {
+	if !HasIndexPlaceholder(fileTemplate) {
+		return nil, fmt.Errorf("%w: file name template(%v) without {index} placeholder", ErrOpNotSupported, fileTemplate)
+	}
+	if len(columns) == 0 {
+		return nil, errors.New("no partition columns")
+	}
+	fileSchema, fields, err := partitionSchema(sc, columns, keepColumns)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = fileSchema.Schema(); err != nil {
+		return nil, err
+	}
+	if !keepColumns {
+		// the removed columns have the same value in all the rows of a file
+		opts = append(slices.Clone(opts), withoutColumns(removedColumns(fields)...))
+	}
+	return &PartitionedWriter{
+		dir:          dir,
+		fileTemplate: fileTemplate,
+		maxRows:      maxRows,
+		maxBytes:     maxBytes,
+		maxOpen:      max(maxOpen, 1),
+		batchSize:    batchSize,
+		schema:       fileSchema,
+		opts:         opts,
+		fields:       fields,
+		partitions:   make(map[string]*partition),
+		open:         list.New(),
+		manifest: Manifest{
+			Files:    []ManifestFile{},
+			Schema:   fileSchema.Describe(),
+			Metadata: make(map[string]string),
+		},
+	}, nil
+}
+
+// partitionOf returns the partition of the record, the partition is created if it does not exist
+func (pw *PartitionedWriter) partitionOf(data map[string]interface{}) (*partition, error) {
+	dirs := make([]string, 0, len(pw.fields))
+	for _, f := range pw.fields {
+		value, err := f.value(data)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, f.name+"="+escapePartitionValue(value))
+	}
+	dir := filepath.Join(dirs...)
+	p, ok := pw.partitions[dir]
+	if !ok {
+		p = &partition{dir: dir, values: make(map[string]string, len(pw.fields))}
+		for i, f := range pw.fields {
+			p.values[f.name] = strings.SplitN(dirs[i], "=", 2)[1]
+		}
+		pw.partitions[dir] = p
+	}
+	return p, nil
+}
+
+// closePartition closes the writer of the partition and adds its files to the manifest
+func (pw *PartitionedWriter) closePartition(p *partition) error {
+	pw.open.Remove(p.element)
+	p.element = nil
+	wr := p.writer
+	p.writer = nil
+	err := wr.Close()
+	for _, f := range wr.Manifest().Files {
+		f.Partition = p.values
+		pw.manifest.Files = append(pw.manifest.Files, f)
+		pw.manifest.Rows += f.Rows
+		p.files++
+	}
+	return err
+}
+
+// openPartition opens the writer of the partition, the least recently used partition is closed
+// if the number of open partitions reaches the limit
+func (pw *PartitionedWriter) openPartition(p *partition) error {
+	if pw.open.Len() >= pw.maxOpen {
+		if err := pw.closePartition(pw.open.Back().Value.(*partition)); err != nil {
+			return err
+		}
+	}
+	dir := filepath.Join(pw.dir, p.dir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	wr, err := NewRollingWriter(filepath.Join(dir, pw.fileTemplate), pw.maxRows, pw.maxBytes, pw.batchSize, pw.schema, pw.opts...)
+	if err != nil {
+		return err
+	}
+	wr.firstIndex = p.files
+	for _, e := range pw.metadata {
+		if err = wr.AppendKeyValueMetadata(e.key, e.value); err != nil {
+			wr.Abort()
+			return err
+		}
+	}
+	p.writer = wr
+	p.element = pw.open.PushFront(p)
+	return nil
+}
+
+func (pw *PartitionedWriter) Write(data map[string]interface{}) error {
+	p, err := pw.partitionOf(data)
+	if err != nil {
+		return err
+	}
+	if p.writer == nil {
+		if err = pw.openPartition(p); err != nil {
+			return err
+		}
+	} else {
+		pw.open.MoveToFront(p.element)
+	}
+	return p.writer.Write(data)
+}
+
+// AppendKeyValueMetadata adds an entry to the footer metadata of the files of the open partitions, of
+// the partitions opened after it and to the manifest, the files already closed are not changed. An
+// entry added before the rows are written is in all the files.
+func (pw *PartitionedWriter) AppendKeyValueMetadata(key, value string) error {
+	if pw.closed {
+		return errors.New("writer is closed")
+	}
+	pw.manifest.Metadata[key] = value
+	pw.metadata = append(pw.metadata, keyValue{key: key, value: value})
+	for e := pw.open.Front(); e != nil; e = e.Next() {
+		if err := e.Value.(*partition).writer.AppendKeyValueMetadata(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the writers of the open partitions. When a partition cannot be closed the files of all the
+// partitions are removed like by Abort.
+func (pw *PartitionedWriter) Close() error {
+	if pw.closed {
+		return nil
+	}
+	pw.closed = true
+	for pw.open.Len() > 0 {
+		if err := pw.closePartition(pw.open.Front().Value.(*partition)); err != nil {
+			// the output is incomplete without the files of the partition
+			pw.abortPartitions()
+			return err
+		}
+	}
+	return nil
+}
+
+// Abort discards the files of the open partitions and removes the files already written
+func (pw *PartitionedWriter) Abort() {
+	if pw.closed {
+		return
+	}
+	pw.closed = true
+	pw.abortPartitions()
+}
+
+// abortPartitions discards the files of the open partitions and removes the files already written
+func (pw *PartitionedWriter) abortPartitions() {
+	for e := pw.open.Front(); e != nil; e = e.Next() {
+		e.Value.(*partition).writer.Abort()
+	}
+	pw.open.Init()
+	for _, f := range pw.manifest.Files {
+		_ = os.Remove(f.Path)
+	}
+	pw.manifest = Manifest{Files: []ManifestFile{}, Schema: pw.manifest.Schema}
+}
+
+// Manifest returns the description of the written files, it is complete after the writer is closed
+func (pw *PartitionedWriter) Manifest() *Manifest {
+	return &pw.manifest
+}
@@ -0,0 +1,180 @@
+package parquet_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func TestParsePartitionColumns(t *testing.T) {
+	columns, err := parquet.ParsePartitionColumns("region, event_time:day,ts:HOUR")
+	require.NoError(t, err)
+	require.Equal(t, []parquet.PartitionColumn{
+		{Key: "region"},
+		{Key: "event_time", Transform: parquet.PartitionTransformDay},
+		{Key: "ts", Transform: parquet.PartitionTransformHour},
+	}, columns)
+	_, err = parquet.ParsePartitionColumns("ts:week")
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+	_, err = parquet.ParsePartitionColumns("region,")
+	require.Error(t, err)
+}
+
+func testPartitionSchema(t *testing.T) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"id":         json.Number("1"),
+		"region":     "eu",
+		"event_time": "2024-10-01T10:00:00Z",
+		"tags":       []interface{}{"a"},
+	}))
+	// the records of the tests do not have tags
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"id":         json.Number("2"),
+		"region":     "eu",
+		"event_time": "2024-10-01T10:00:00Z",
+	}))
+	return sb.Schema()
+}
+
+func TestPartitionedWriter(t *testing.T) {
+	columns, err := parquet.ParsePartitionColumns("event_time:day,region")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	// a single open partition forces the partitions to be reopened
+	wr, err := parquet.NewPartitionedWriter(dir, "part-{index:04}.parquet", columns, false, 1, 0, 0, 10, testPartitionSchema(t))
+	require.NoError(t, err)
+	records := []map[string]interface{}{
+		{"id": json.Number("1"), "region": "eu", "event_time": "2024-10-01T10:00:00Z"},
+		{"id": json.Number("2"), "region": "us/east", "event_time": "2024-10-01T23:59:59Z"},
+		{"id": json.Number("3"), "region": "eu", "event_time": "2024-10-02T00:00:00+02:00"},
+		{"id": json.Number("4"), "event_time": "2024-10-02T01:00:00Z"},
+		{"id": json.Number("5"), "region": "eu", "event_time": "2024-10-01T11:00:00Z"},
+	}
+	// the metadata added before the rows are written is in the files of the closed partitions as well
+	require.NoError(t, wr.AppendKeyValueMetadata("sources", "all"))
+	for _, record := range records {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.AppendKeyValueMetadata("key", "value"))
+	require.NoError(t, wr.Close())
+
+	manifest := wr.Manifest()
+	require.EqualValues(t, 5, manifest.Rows)
+	paths := make(map[string]int64)
+	for _, f := range manifest.Files {
+		rel, err := filepath.Rel(dir, f.Path)
+		require.NoError(t, err)
+		paths[filepath.ToSlash(rel)] = f.Rows
+
+		reader, err := file.OpenParquetFile(f.Path, false)
+		require.NoError(t, err)
+		require.Equal(t, f.Rows, reader.NumRows())
+		// the identity partition column is removed, the bucketed timestamp column is kept
+		require.Equal(t, -1, reader.MetaData().Schema.ColumnIndexByName("region"))
+		require.NotEqual(t, -1, reader.MetaData().Schema.ColumnIndexByName("event_time"))
+		kv := reader.MetaData().KeyValueMetadata()
+		require.Equal(t, "all", *kv.FindValue("sources"))
+		require.Equal(t, f.Path == manifest.Files[len(manifest.Files)-1].Path, kv.FindValue("key") != nil)
+		require.NoError(t, reader.Close())
+	}
+	require.Equal(t, map[string]int64{
+		"event_time_day=2024-10-01/region=eu/part-0000.parquet":                         1,
+		"event_time_day=2024-10-01/region=us%2Feast/part-0000.parquet":                  1,
+		"event_time_day=2024-10-01/region=eu/part-0001.parquet":                         1,
+		"event_time_day=2024-10-02/region=__HIVE_DEFAULT_PARTITION__/part-0000.parquet": 1,
+		"event_time_day=2024-10-01/region=eu/part-0002.parquet":                         1,
+	}, paths)
+	require.Equal(t, "value", manifest.Metadata["key"])
+	last := manifest.Files[len(manifest.Files)-1]
+	require.Equal(t, map[string]string{"event_time_day": "2024-10-01", "region": "eu"}, last.Partition)
+}
+
+func TestPartitionedWriterDistantTimestamps(t *testing.T) {
+	columns, err := parquet.ParsePartitionColumns("event_time:hour")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	// the timestamps out of the range of nanoseconds since the epoch are written in microseconds
+	wr, err := parquet.NewPartitionedWriter(dir, "part-{index}.parquet", columns, false, 10, 0, 0, 10, testPartitionSchema(t),
+		parquet.WithTimestampType(parquet.TimestampType{Unit: parquet.TimestampUnitMicros}))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "region": "eu", "event_time": "1600-03-01T10:30:00.123456789Z"}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "region": "eu", "event_time": "2300-01-01T00:00:00+01:00"}))
+	require.NoError(t, wr.Close())
+	var partitions []string
+	for _, f := range wr.Manifest().Files {
+		partitions = append(partitions, f.Partition["event_time_hour"])
+	}
+	require.ElementsMatch(t, []string{"1600-03-01-10", "2299-12-31-23"}, partitions)
+}
+
+func TestPartitionedWriterKeepColumns(t *testing.T) {
+	columns := []parquet.PartitionColumn{{Key: "region"}}
+	dir := t.TempDir()
+	wr, err := parquet.NewPartitionedWriter(dir, "part-{index}.parquet", columns, true, 10, 0, 0, 10, testPartitionSchema(t))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "region": "eu", "event_time": "2024-10-01T10:00:00Z"}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "region": "us", "event_time": "2024-10-01T10:00:00Z"}))
+	require.NoError(t, wr.Close())
+	require.Len(t, wr.Manifest().Files, 2)
+	reader, err := file.OpenParquetFile(filepath.Join(dir, "region=eu", "part-0.parquet"), false)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.NotEqual(t, -1, reader.MetaData().Schema.ColumnIndexByName("region"))
+}
+
+func TestPartitionedWriterAbort(t *testing.T) {
+	columns := []parquet.PartitionColumn{{Key: "region"}}
+	dir := t.TempDir()
+	wr, err := parquet.NewPartitionedWriter(dir, "part-{index}.parquet", columns, false, 1, 0, 0, 10, testPartitionSchema(t))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "region": "eu", "event_time": "2024-10-01T10:00:00Z"}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "region": "us", "event_time": "2024-10-01T10:00:00Z"}))
+	wr.Abort()
+	for _, region := range []string{"eu", "us"} {
+		entries, err := os.ReadDir(filepath.Join(dir, "region="+region))
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	}
+}
+
+func TestPartitionedWriterCloseFailed(t *testing.T) {
+	columns := []parquet.PartitionColumn{{Key: "region"}}
+	dir := t.TempDir()
+	// the sorted rows are converted when the partition is closed
+	wr, err := parquet.NewPartitionedWriter(dir, "part-{index}.parquet", columns, false, 10, 0, 0, 10, testPartitionSchema(t),
+		parquet.WithSortingColumns(parquet.SortColumn{Key: "id"}), parquet.WithSortedRowGroups(100))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "region": "eu", "event_time": "2024-10-01T10:00:00Z"}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("2"), "region": "us", "event_time": true}))
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("3"), "region": "ch", "event_time": "2024-10-01T10:00:00Z"}))
+	require.ErrorContains(t, wr.Close(), "cannot convert")
+	// the files of all the partitions are removed
+	require.Empty(t, wr.Manifest().Files)
+	for _, region := range []string{"eu", "us", "ch"} {
+		entries, err := os.ReadDir(filepath.Join(dir, "region="+region))
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	}
+}
+
+func TestPartitionedWriterInvalidColumns(t *testing.T) {
+	sc := testPartitionSchema(t)
+	_, err := parquet.NewPartitionedWriter(t.TempDir(), "part-{index}.parquet",
+		[]parquet.PartitionColumn{{Key: "unknown"}}, false, 1, 0, 0, 10, sc)
+	require.Error(t, err)
+	_, err = parquet.NewPartitionedWriter(t.TempDir(), "part-{index}.parquet",
+		[]parquet.PartitionColumn{{Key: "tags"}}, false, 1, 0, 0, 10, sc)
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+	_, err = parquet.NewPartitionedWriter(t.TempDir(), "part-{index}.parquet",
+		[]parquet.PartitionColumn{{Key: "region", Transform: parquet.PartitionTransformDay}}, false, 1, 0, 0, 10, sc)
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+	_, err = parquet.NewPartitionedWriter(t.TempDir(), "part.parquet",
+		[]parquet.PartitionColumn{{Key: "region"}}, false, 1, 0, 0, 10, sc)
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+}
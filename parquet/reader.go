@@ -0,0 +1,194 @@
+package parquet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	tfJson "github.com/thermofisher/json2parquet/json"
+)
+
+// number of rows of the Arrow records the rows are read from
+const readBatchSize = 1024
+
+// Reader reads the rows of a parquet file written by json2parquet back into JSON records, the values
+// are converted to the JSON values the file was written from (e.g. RFC3339 strings of timestamps), so
+// the records can be written again
+type Reader struct {
+	file   *file.Reader
+	schema *Schema
+}
+
+// OpenReader opens a parquet file written by json2parquet, the schema is restored from the footer
+// metadata (see SchemaMetadataKey). The encrypted files are decrypted with the keys of the KMS (nil for
+// plaintext files).
+func OpenReader(path string, kms KMS) (*Reader, error) {
+	f, err := OpenFile(path, kms)
+	if err != nil {
+		return nil, err
+	}
+	md := f.MetaData()
+	fields, err := SchemaDescription(md.KeyValueMetadata())
+	if err == nil && fields == nil {
+		err = fmt.Errorf("file(%v) has no %v footer metadata, it was not written by json2parquet", path, SchemaMetadataKey)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	sc, err := NewSchemaFromDescription(fields, md.NumRows)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	sc.origin = path
+	return &Reader{file: f, schema: sc}, nil
+}
+
+// Schema returns the inferred schema the file was written with
+func (r *Reader) Schema() *Schema {
+	return r.schema
+}
+
+// NumRows returns the number of rows of the file
+func (r *Reader) NumRows() int64 {
+	return r.file.NumRows()
+}
+
+// Metadata returns the footer metadata of the file
+func (r *Reader) Metadata() metadata.KeyValueMetadata {
+	return r.file.MetaData().KeyValueMetadata()
+}
+
+// Read passes the rows of the file to the function in order, the null values are omitted from the records
+func (r *Reader) Read(ctx context.Context, fn func(map[string]interface{}) error) error {
+	fr, err := pqarrow.NewFileReader(r.file, pqarrow.ArrowReadProperties{BatchSize: readBatchSize}, memory.DefaultAllocator)
+	if err != nil {
+		return err
+	}
+	rr, err := fr.GetRecordReader(ctx, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer rr.Release()
+	byName := make(map[string]Node, len(r.schema.fields))
+	for _, field := range r.schema.fields {
+		byName[field.GetName()] = field
+	}
+	fields := make([]Node, rr.Schema().NumFields())
+	for i, f := range rr.Schema().Fields() {
+		field, ok := byName[f.Name]
+		if !ok {
+			return fmt.Errorf("column(%v) not in the schema of the file", f.Name)
+		}
+		fields[i] = field
+	}
+	for rr.Next() {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		rec := rr.RecordBatch()
+		for row := range int(rec.NumRows()) {
+			data := make(map[string]interface{}, len(fields))
+			for i, field := range fields {
+				v, err := recordValue(rec.Column(i), row, field)
+				if err != nil {
+					return err
+				}
+				if v != nil {
+					data[field.GetKey()] = v
+				}
+			}
+			if err = fn(data); err != nil {
+				return err
+			}
+		}
+	}
+	if err = rr.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Close closes the file
+func (r *Reader) Close() error {
+	return r.file.Close()
+}
+
+// recordValue returns the JSON value of the row of the Arrow array of the field, nil for a null
+func recordValue(arr arrow.Array, i int, field Node) (interface{}, error) {
+	if arr.IsNull(i) {
+		return nil, nil
+	}
+	switch a := arr.(type) {
+	case array.ExtensionArray:
+		return recordValue(a.Storage(), i, field)
+	case *array.Boolean:
+		return a.Value(i), nil
+	case *array.Int64:
+		return json.Number(strconv.FormatInt(a.Value(i), 10)), nil
+	case *array.Float64:
+		return json.Number(strconv.FormatFloat(a.Value(i), 'g', -1, 64)), nil
+	case *array.String:
+		return stringValue(a.Value(i), field), nil
+	case *array.Binary:
+		return stringValue(string(a.Value(i)), field), nil
+	case *array.Timestamp:
+		tm := a.Value(i).ToTime(a.DataType().(*arrow.TimestampType).Unit)
+		if field.GetExtendedType() == ExtendedTypeEpochMillis {
+			return json.Number(strconv.FormatInt(tm.UnixMilli(), 10)), nil
+		}
+		return tm.UTC().Format(time.RFC3339Nano), nil
+	case *array.List:
+		ln, ok := field.(*ListNode)
+		if !ok {
+			return nil, fmt.Errorf("%w: list column(%v) of field(%v)", ErrTypeMismatch, arr.DataType(), field.Print())
+		}
+		start, end := a.ValueOffsets(i)
+		values := make([]interface{}, 0, end-start)
+		for j := start; j < end; j++ {
+			v, err := recordValue(a.ListValues(), int(j), ln.Element())
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case *array.Struct:
+		fields, _ := field.Fields()
+		typ := a.DataType().(*arrow.StructType)
+		data := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			idx, ok := typ.FieldIdx(f.GetName())
+			if !ok {
+				continue
+			}
+			v, err := recordValue(a.Field(idx), i, f)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				data[f.GetKey()] = v
+			}
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%w: column type(%v) of field(%v)", ErrTypeNotSupported, arr.DataType(), field.GetName())
+}
+
+// stringValue returns the value of a byte array, the JSON text is kept as raw JSON
+func stringValue(s string, field Node) interface{} {
+	if field.GetLogicalType() == LogicalTypeJSON {
+		return tfJson.Raw(s)
+	}
+	return s
+}
@@ -0,0 +1,196 @@
+package parquet_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/stretchr/testify/require"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+var testReaderRecords = []map[string]interface{}{
+	{
+		"id":       json.Number("1"),
+		"userName": "Alice!",
+		"score":    json.Number("1.5"),
+		"ok":       true,
+		"ts":       "2024-10-01T10:00:00.123+02:00",
+		"ms":       json.Number("1727776800123"),
+		"payload":  tfJson.Raw(`{"a":1}`),
+		"data":     "aGVsbG8=",
+		"tags":     []interface{}{"a!", nil, "b!"},
+	},
+	{"id": json.Number("2"), "tags": []interface{}{}},
+}
+
+// the records read back, the timestamps are in UTC and the nulls are omitted
+var testReaderExpected = []map[string]interface{}{
+	{
+		"id":       json.Number("1"),
+		"userName": "Alice!",
+		"score":    json.Number("1.5"),
+		"ok":       true,
+		"ts":       "2024-10-01T08:00:00.123Z",
+		"ms":       json.Number("1727776800123"),
+		"payload":  tfJson.Raw(`{"a":1}`),
+		"data":     "aGVsbG8=",
+		"tags":     []interface{}{"a!", nil, "b!"},
+	},
+	{"id": json.Number("2"), "tags": []interface{}{}},
+}
+
+func testReaderSchema(t *testing.T) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	sb.SetColumnNaming(parquet.ColumnNaming{SnakeCase: true})
+	sb.SetOverrides(parquet.Overrides{
+		"ms":     {Type: parquet.NodeTypeInt64, ExtendedType: parquet.ExtendedTypeEpochMillis},
+		"id":     {Type: parquet.NodeTypeInt64, Repetition: pq.Repetitions.Required, ForceRepetition: true},
+		"tags[]": {Type: parquet.NodeTypeByteArray, LogicalType: parquet.LogicalTypeUTF8},
+	})
+	for _, record := range testReaderRecords {
+		require.NoError(t, sb.UpdateSchema(record))
+	}
+	return sb.Schema()
+}
+
+func readRecords(t *testing.T, path string, kms parquet.KMS) (*parquet.Schema, []map[string]interface{}) {
+	reader, err := parquet.OpenReader(path, kms)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.EqualValues(t, len(testReaderRecords), reader.NumRows())
+	var records []map[string]interface{}
+	require.NoError(t, reader.Read(context.Background(), func(data map[string]interface{}) error {
+		records = append(records, data)
+		return nil
+	}))
+	return reader.Schema(), records
+}
+
+func TestReader(t *testing.T) {
+	sc := testReaderSchema(t)
+	dir := t.TempDir()
+	for name, opts := range map[string][]parquet.WriterOption{
+		"default":    nil,
+		"timestamps": {parquet.WithTimestampType(parquet.TimestampType{Unit: parquet.TimestampUnitMicros, INT96: true})},
+		"encrypted":  {parquet.WithEncryption(parquet.Encryption{KMS: testKMS, FooterKeyID: "footer"})},
+	} {
+		path := filepath.Join(dir, name+".parquet")
+		wr, err := parquet.NewWriter(path, 10, sc, opts...)
+		require.NoError(t, err)
+		for _, record := range testReaderRecords {
+			require.NoError(t, wr.Write(record))
+		}
+		require.NoError(t, wr.Close())
+
+		readSc, records := readRecords(t, path, testKMS)
+		require.Equal(t, sc.Describe(), readSc.Describe(), name)
+		require.Equal(t, sc.Overrides(), readSc.Overrides(), name)
+		require.Equal(t, testReaderExpected, records, name)
+	}
+
+	path := filepath.Join(dir, "arrow.parquet")
+	wr, err := parquet.NewArrowWriter(path, 10, sc)
+	require.NoError(t, err)
+	for _, record := range testReaderRecords {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.Close())
+	_, records := readRecords(t, path, nil)
+	require.Equal(t, testReaderExpected, records)
+}
+
+func TestReaderMergeOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.parquet")
+	writeReaderRecords(t, path, testReaderSchema(t))
+	reader, err := parquet.OpenReader(path, nil)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// the forced repetition of the file is kept when records without the field are merged
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOverrides(reader.Schema().Overrides())
+	require.NoError(t, sb.Merge(reader.Schema()))
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"tags": []interface{}{json.Number("1")}}))
+	fields := sb.Schema().Describe()
+	require.Contains(t, fields, parquet.FieldDescription{
+		Name: "id", Type: "INT64", Repetition: "required", Overridden: true, ForcedRepetition: true,
+	})
+	require.Contains(t, fields, parquet.FieldDescription{
+		Name: "tags", LogicalType: "List", Repetition: "required", Fields: []parquet.FieldDescription{
+			{Name: "element", Type: "BYTE_ARRAY", LogicalType: "String", Repetition: "repeated", Overridden: true},
+		},
+	})
+
+	// the values of the overridden elements are converted
+	mergedPath := filepath.Join(t.TempDir(), "merged.parquet")
+	wr, err := parquet.NewWriter(mergedPath, 10, sb.Schema())
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("3"), "tags": []interface{}{json.Number("1"), "a"}}))
+	require.NoError(t, wr.Close())
+	merged, err := parquet.OpenReader(mergedPath, nil)
+	require.NoError(t, err)
+	defer merged.Close()
+	var records []map[string]interface{}
+	require.NoError(t, merged.Read(context.Background(), func(data map[string]interface{}) error {
+		records = append(records, data)
+		return nil
+	}))
+	require.Equal(t, []map[string]interface{}{{"id": json.Number("3"), "tags": []interface{}{"1", "a"}}}, records)
+}
+
+func TestReaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "records.parquet")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	wr, err := parquet.NewArrowRecordWriterTo(f, arrow.NewSchema([]arrow.Field{{Name: "id", Type: arrow.PrimitiveTypes.Int64}}, nil))
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+	require.NoError(t, f.Close())
+	_, err = parquet.OpenReader(path, nil)
+	require.ErrorContains(t, err, "not written by json2parquet")
+
+	_, err = parquet.OpenReader(filepath.Join(dir, "missing.parquet"), nil)
+	require.Error(t, err)
+}
+
+func TestMergeWrittenSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.parquet")
+	wr, err := parquet.NewWriter(path, 10, testReaderSchema(t))
+	require.NoError(t, err)
+	for _, record := range testReaderRecords {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.Close())
+	reader, err := parquet.OpenReader(path, nil)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// the appended data widens the schema of the file
+	sb := parquet.NewSchemaBuilder()
+	sb.SetColumnNaming(parquet.ColumnNaming{SnakeCase: true})
+	sb.SetOverrides(reader.Schema().Overrides())
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("3"), "score": json.Number("2"), "extra": "x!"}))
+	require.NoError(t, sb.Merge(reader.Schema()))
+	described := make(map[string]parquet.FieldDescription)
+	for _, d := range sb.Schema().Describe() {
+		described[d.Name] = d
+	}
+	require.Equal(t, "required", described["id"].Repetition)
+	require.Equal(t, "optional", described["extra"].Repetition)
+	require.Equal(t, "DOUBLE", described["score"].Type)
+	require.Equal(t, "userName", described["user_name"].Key)
+
+	sb = parquet.NewSchemaBuilder()
+	sb.SetOrigin("new.json")
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": "x!"}))
+	err = sb.Merge(reader.Schema())
+	require.ErrorIs(t, err, parquet.ErrTypeMismatch)
+	require.ErrorContains(t, err, path)
+}
@@ -0,0 +1,241 @@
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+)
+
+var indexPlaceholder = regexp.MustCompile(`\{index(?::(\d+))?\}`)
+
+// FileName returns the name of the file with the index from the naming template, the template contains
+// the placeholder {index} or {index:width} (e.g. out-{index:05}.parquet), a width with a leading zero
+// pads the index with zeros
+func FileName(template string, index int) string {
+	return indexPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		format := indexPlaceholder.FindStringSubmatch(placeholder)[1]
+		if format == "" {
+			return strconv.Itoa(index)
+		}
+		return fmt.Sprintf("%"+format+"d", index)
+	})
+}
+
+// HasIndexPlaceholder returns true if the naming template contains the index placeholder
+func HasIndexPlaceholder(template string) bool {
+	return indexPlaceholder.MatchString(template)
+}
+
+// ManifestFile describes a file written by the RollingWriter
+type ManifestFile struct {
+	Path  string `json:"path"`
+	Rows  int64  `json:"rows"`
+	Bytes int64  `json:"bytes"`
+	// Partition holds the partition values indexed by the directory name of the partition column
+	Partition map[string]string `json:"partition,omitempty"`
+
+	Summary *Summary `json:"-"`
+}
+
+// Manifest describes the set of files written by the RollingWriter, all the files have the same schema
+type Manifest struct {
+	Files    []ManifestFile     `json:"files"`
+	Rows     int64              `json:"rows"`
+	Schema   []FieldDescription `json:"schema"`
+	Metadata map[string]string  `json:"metadata,omitempty"`
+}
+
+// fileWriter writes the rows to a single file, the Writer or the ArrowWriter
+type fileWriter interface {
+	RecordWriter
+	EstimatedSize() int64
+	Summary() (*Summary, error)
+}
+
+// newFileWriter creates the writer of the file selected by WithArrowWriter
+func newFileWriter(path string, batchSize uint, sc *Schema, opts []WriterOption) (fileWriter, error) {
+	if newWriterConfig(opts).arrow {
+		return NewArrowWriter(path, batchSize, sc, opts...)
+	}
+	return NewWriter(path, batchSize, sc, opts...)
+}
+
+// RollingWriter writes the rows to a sequence of files named by a template, the next file is started
+// when the current file reaches the maximum number of rows or bytes. The size of a file can exceed the
+// maximum by the size of a batch and the footer.
+type RollingWriter struct {
+	template  string
+	batchSize uint
+	schema    *Schema
+	opts      []WriterOption
+	maxRows   int64
+	maxBytes  int64
+	// firstIndex is the index of the first file, the PartitionedWriter continues the numbering of a reopened partition
+	firstIndex int
+
+	current  fileWriter
+	rows     int64
+	full     bool       // the current file is closed on the next write, so metadata can be added to the last file
+	metadata []keyValue // entries added to the current file and to the files started after it
+	manifest Manifest
+	closed   bool
+}
+
+// NewRollingWriter creates a writer of files named by the template, a limit that is not positive is
+// not applied. The template must contain the index placeholder when a limit is set (see FileName).
+func NewRollingWriter(template string, maxRows, maxBytes int64, batchSize uint, sc *Schema, opts ...WriterOption) (*RollingWriter, error) {
+	if (maxRows > 0 || maxBytes > 0) && !HasIndexPlaceholder(template) {
+		return nil, fmt.Errorf("%w: file name template(%v) without {index} placeholder", ErrOpNotSupported, template)
+	}
+	if _, err := sc.Schema(); err != nil {
+		return nil, err
+	}
+	return &RollingWriter{
+		template:  template,
+		batchSize: batchSize,
+		schema:    sc,
+		opts:      opts,
+		maxRows:   maxRows,
+		maxBytes:  maxBytes,
+		manifest: Manifest{
+			Files:    []ManifestFile{},
+			Schema:   sc.Describe(),
+			Metadata: make(map[string]string),
+		},
+	}, nil
+}
+
+func (rw *RollingWriter) next() error {
+	path := FileName(rw.template, rw.firstIndex+len(rw.manifest.Files))
+	wr, err := newFileWriter(path, rw.batchSize, rw.schema, rw.opts)
+	if err != nil {
+		return err
+	}
+	for _, e := range rw.metadata {
+		if err = wr.AppendKeyValueMetadata(e.key, e.value); err != nil {
+			wr.Abort()
+			return err
+		}
+	}
+	rw.current = wr
+	rw.rows = 0
+	rw.full = false
+	rw.manifest.Files = append(rw.manifest.Files, ManifestFile{Path: path})
+	return nil
+}
+
+// closeCurrent closes the current file and records its size in the manifest
+func (rw *RollingWriter) closeCurrent() error {
+	wr := rw.current
+	rw.current = nil
+	if err := wr.Close(); err != nil {
+		// the file was not written, so it is not a part of the output
+		rw.manifest.Files = rw.manifest.Files[:len(rw.manifest.Files)-1]
+		return err
+	}
+	f := &rw.manifest.Files[len(rw.manifest.Files)-1]
+	f.Rows = rw.rows
+	f.Summary, _ = wr.Summary()
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	f.Bytes = info.Size()
+	rw.manifest.Rows += rw.rows
+	return nil
+}
+
+func (rw *RollingWriter) isFull() bool {
+	if rw.maxRows > 0 && rw.rows >= rw.maxRows {
+		return true
+	}
+	// the size changes only when a full batch is written, i.e. on the write of the first row of the next batch
+	return rw.maxBytes > 0 && rw.rows > 1 && (rw.rows-1)%int64(rw.batchSize) == 0 &&
+		rw.current.EstimatedSize() >= rw.maxBytes
+}
+
+func (rw *RollingWriter) Write(data map[string]interface{}) error {
+	if rw.full {
+		if err := rw.closeCurrent(); err != nil {
+			return err
+		}
+	}
+	if rw.current == nil {
+		if err := rw.next(); err != nil {
+			return err
+		}
+	}
+	if err := rw.current.Write(data); err != nil {
+		return err
+	}
+	rw.rows++
+	rw.full = rw.isFull()
+	return nil
+}
+
+// AppendKeyValueMetadata adds an entry to the footer metadata of the current file (the last file
+// when it is called after all the rows are written), of the files started after it and to the
+// manifest, the files already closed are not changed. An entry added before the rows are written
+// is in all the files.
+func (rw *RollingWriter) AppendKeyValueMetadata(key, value string) error {
+	if rw.closed {
+		return errors.New("writer is closed")
+	}
+	if rw.current == nil {
+		if err := rw.next(); err != nil {
+			return err
+		}
+	}
+	rw.manifest.Metadata[key] = value
+	rw.metadata = append(rw.metadata, keyValue{key: key, value: value})
+	return rw.current.AppendKeyValueMetadata(key, value)
+}
+
+// Close closes the last file, a file is written even if there are no rows. When the last file cannot be
+// written the files already written are removed like by Abort, so the output is either complete or empty.
+func (rw *RollingWriter) Close() error {
+	if rw.closed {
+		return nil
+	}
+	rw.closed = true
+	var err error
+	if rw.current == nil {
+		err = rw.next()
+	}
+	if err == nil {
+		err = rw.closeCurrent()
+	}
+	if err != nil {
+		rw.removeFiles()
+	}
+	return err
+}
+
+// Abort discards the current file and removes the files already written
+func (rw *RollingWriter) Abort() {
+	if rw.closed {
+		return
+	}
+	rw.closed = true
+	if rw.current != nil {
+		rw.current.Abort()
+		rw.current = nil
+		rw.manifest.Files = rw.manifest.Files[:len(rw.manifest.Files)-1]
+	}
+	rw.removeFiles()
+}
+
+// removeFiles removes the files of the manifest, the current file must be closed or aborted
+func (rw *RollingWriter) removeFiles() {
+	for _, f := range rw.manifest.Files {
+		_ = os.Remove(f.Path)
+	}
+	rw.manifest = Manifest{Files: []ManifestFile{}, Schema: rw.manifest.Schema}
+}
+
+// Manifest returns the description of the written files, it is complete after the writer is closed
+func (rw *RollingWriter) Manifest() *Manifest {
+	return &rw.manifest
+}
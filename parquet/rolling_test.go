@@ -0,0 +1,105 @@
+package parquet_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func TestFileName(t *testing.T) {
+	require.Equal(t, "out-00003.parquet", parquet.FileName("out-{index:05}.parquet", 3))
+	require.Equal(t, "out-3.parquet", parquet.FileName("out-{index}.parquet", 3))
+	require.Equal(t, "out-  3.parquet", parquet.FileName("out-{index:3}.parquet", 3))
+	require.Equal(t, "out.parquet", parquet.FileName("out.parquet", 3))
+	require.False(t, parquet.HasIndexPlaceholder("out.parquet"))
+}
+
+func testRollingWriter(t *testing.T, rows int, maxRows, maxBytes int64, batchSize uint) (*parquet.Manifest, string) {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("1"), "text": "some text"}))
+	dir := t.TempDir()
+	wr, err := parquet.NewRollingWriter(filepath.Join(dir, "out-{index:03}.parquet"), maxRows, maxBytes, batchSize, sb.Schema())
+	require.NoError(t, err)
+	require.NoError(t, wr.AppendKeyValueMetadata("sources", "all"))
+	for i := range rows {
+		require.NoError(t, wr.Write(map[string]interface{}{
+			"id":   json.Number(strings.Repeat("1", 1+i%10)),
+			"text": strings.Repeat("some text ", 1+i%10),
+		}))
+	}
+	require.NoError(t, wr.AppendKeyValueMetadata("key", "value"))
+	require.NoError(t, wr.Close())
+	return wr.Manifest(), dir
+}
+
+func TestRollingWriterMaxRows(t *testing.T) {
+	manifest, dir := testRollingWriter(t, 30, 10, 0, 4)
+	require.EqualValues(t, 30, manifest.Rows)
+	require.Len(t, manifest.Files, 3)
+	var schemas []string
+	for i, f := range manifest.Files {
+		require.Equal(t, filepath.Join(dir, parquet.FileName("out-{index:03}.parquet", i)), f.Path)
+		reader, err := file.OpenParquetFile(f.Path, false)
+		require.NoError(t, err)
+		require.Equal(t, f.Rows, reader.NumRows())
+		// metadata added after the rows are written is in the last file only
+		require.Equal(t, i == len(manifest.Files)-1, reader.MetaData().KeyValueMetadata().FindValue("key") != nil)
+		// metadata added before the rows are written is in all the files
+		require.Equal(t, "all", *reader.MetaData().KeyValueMetadata().FindValue("sources"))
+		schemas = append(schemas, reader.MetaData().Schema.String())
+		require.NoError(t, reader.Close())
+	}
+	require.Equal(t, []int64{10, 10, 10}, []int64{manifest.Files[0].Rows, manifest.Files[1].Rows, manifest.Files[2].Rows})
+	require.Equal(t, schemas[0], schemas[1])
+	require.Equal(t, schemas[0], schemas[2])
+	require.Equal(t, "value", manifest.Metadata["key"])
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+}
+
+func TestRollingWriterMaxBytes(t *testing.T) {
+	const maxBytes = 16 << 10
+	manifest, _ := testRollingWriter(t, 5000, 0, maxBytes, 100)
+	require.EqualValues(t, 5000, manifest.Rows)
+	require.Greater(t, len(manifest.Files), 2)
+	for _, f := range manifest.Files[:len(manifest.Files)-1] {
+		require.GreaterOrEqual(t, f.Bytes, int64(maxBytes))
+		// a file exceeds the maximum by at most a batch and the footer
+		require.Less(t, f.Bytes, int64(2*maxBytes))
+	}
+}
+
+func TestRollingWriterCloseFailed(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("1"), "text": "some text"}))
+	dir := t.TempDir()
+	// the sorted rows are converted when the file is closed
+	wr, err := parquet.NewRollingWriter(filepath.Join(dir, "out-{index:03}.parquet"), 10, 0, 4, sb.Schema(),
+		parquet.WithSortingColumns(parquet.SortColumn{Key: "id"}), parquet.WithSortedRowGroups(100))
+	require.NoError(t, err)
+	for i := range 25 {
+		require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number(strconv.Itoa(i)), "text": "some text"}))
+	}
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("25"), "text": true}))
+	require.ErrorContains(t, wr.Close(), "cannot convert")
+	// the files already written are removed
+	require.Empty(t, wr.Manifest().Files)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestRollingWriterTemplate(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("1")}))
+	_, err := parquet.NewRollingWriter("out.parquet", 10, 0, 100, sb.Schema())
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+}
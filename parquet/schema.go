@@ -19,6 +19,12 @@ type SchemaBuilder struct {
 
 	firstRun       bool
 	requiredFields map[string]struct{}
+
+	naming    ColumnNaming
+	overrides Overrides
+
+	records int64
+	origin  string
 }
 
 var (
@@ -35,6 +41,22 @@ func NewSchemaBuilder() *SchemaBuilder {
 	}
 }
 
+// SetColumnNaming sets the policy used to convert JSON keys to column names
+func (sb *SchemaBuilder) SetColumnNaming(naming ColumnNaming) {
+	sb.naming = naming
+}
+
+// SetOverrides sets the fields whose type is forced instead of inferred from the JSON data.
+// Overridden fields are exempt from type upgrades and mismatch checks.
+func (sb *SchemaBuilder) SetOverrides(overrides Overrides) {
+	sb.overrides = overrides
+}
+
+// SetOrigin sets the description of the inferred data (e.g. the file name) used in merge conflicts
+func (sb *SchemaBuilder) SetOrigin(origin string) {
+	sb.origin = origin
+}
+
 type inferedTypeAction int
 
 const (
@@ -78,6 +100,10 @@ func checkOrUpdateInferedType(field, newField Node) (inferedTypeAction, Node) {
 		f1EType := field.GetExtendedType()
 		f2EType := newField.GetExtendedType()
 		if f1EType == ExtendedTypeNone && f2EType == ExtendedTypeNone {
+			if f1LType == LogicalTypeJSON || newField.GetLogicalType() == LogicalTypeJSON {
+				// JSON text mixed with other strings -> the common type is always a UTF8 string
+				return inferedTypeActionUpgrade, NewByteArrayNode(field.GetName(), field.GetRepetition(), LogicalTypeUTF8, ExtendedTypeNone)
+			}
 			// a valid base64 string is also a valid string
 			if f1LType == LogicalTypeUTF8 {
 				return inferedTypeActionNone, nil
@@ -128,6 +154,9 @@ func getNodeType(key string, value interface{}) (NodeType, LogicalType, Extended
 	case reflect.Bool:
 		return NodeTypeBoolean, LogicalTypeNone, ExtendedTypeNone, nil
 	case reflect.String:
+		if _, ok := value.(tfJson.Raw); ok {
+			return NodeTypeByteArray, LogicalTypeJSON, ExtendedTypeNone, nil
+		}
 		if number, ok := value.(json.Number); ok {
 			_, err := number.Int64()
 			if err == nil {
@@ -153,17 +182,19 @@ func getNodeType(key string, value interface{}) (NodeType, LogicalType, Extended
 	return NodeTypeNone, LogicalTypeNone, ExtendedTypeNone, fmt.Errorf("%w: unrecognized type(%v:%T)", ErrTypeNotSupported, key, value)
 }
 
-func inferArrayElementNode(slice []interface{}) (Node, error) {
-	if len(slice) == 0 {
-		return NewTemporaryNode("element", parquet.Repetitions.Repeated), nil
-	}
+// inferArrayElementNode infers the common field of the elements, the field of an element is returned by infer
+func inferArrayElementNode(slice []interface{}, infer func(e interface{}) (Node, error)) (Node, error) {
 	var arrayNode Node
-	for i, e := range slice {
-		node, err := getNode("element", e, parquet.Repetitions.Repeated)
+	for _, e := range slice {
+		// null elements do not determine the type of the elements
+		if e == nil {
+			continue
+		}
+		node, err := infer(e)
 		if err != nil {
 			return nil, err
 		}
-		if i == 0 {
+		if arrayNode == nil {
 			arrayNode = node
 			continue
 		}
@@ -182,11 +213,16 @@ func inferArrayElementNode(slice []interface{}) (Node, error) {
 		return nil, fmt.Errorf("%w: array field(%v) does not match expected array field(%v) ", ErrTypeMismatch, node.Print(),
 			arrayNode.Print())
 	}
+	if arrayNode == nil {
+		return NewTemporaryNode("element", parquet.Repetitions.Repeated), nil
+	}
 	return arrayNode, nil
 }
 
 func newArrayNode(key string, slice []interface{}, repetition parquet.Repetition) (Node, error) {
-	element, err := inferArrayElementNode(slice)
+	element, err := inferArrayElementNode(slice, func(e interface{}) (Node, error) {
+		return getNode("element", e, parquet.Repetitions.Repeated)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -200,12 +236,18 @@ func getNodeByType(key string, nodeType NodeType, logicalType LogicalType, exten
 		}
 		return nil, fmt.Errorf("invalid physical type(%v) for extended type(%v)", nodeType, extendedType)
 	}
+	if extendedType == ExtendedTypeEpochMillis {
+		if nodeType == NodeTypeInt64 {
+			return NewEpochMillisNode(key, repetition), nil
+		}
+		return nil, fmt.Errorf("invalid physical type(%v) for extended type(%v)", nodeType, extendedType)
+	}
 	// ExtendedTypeNone
 
 	switch logicalType {
-	case LogicalTypeUTF8:
+	case LogicalTypeUTF8, LogicalTypeJSON:
 		if nodeType == NodeTypeByteArray {
-			return NewByteArrayNode(key, repetition, LogicalTypeUTF8, ExtendedTypeNone), nil
+			return NewByteArrayNode(key, repetition, logicalType, ExtendedTypeNone), nil
 		}
 		return nil, fmt.Errorf("invalid physical type(%v) for logical type(%v)", nodeType, logicalType)
 	case LogicalTypeList:
@@ -240,14 +282,54 @@ func getNode(key string, value interface{}, repetition parquet.Repetition) (Node
 	return getNodeByType(key, nodeType, logicalType, extendedType, repetition, value)
 }
 
+func (sb *SchemaBuilder) overrideField(key string, override Override, repetition parquet.Repetition) (Node, error) {
+	if field, ok := sb.fields[key]; ok {
+		return field, nil
+	}
+	field, err := newOverrideNode(key, override, repetition)
+	if err != nil {
+		return nil, err
+	}
+	sb.fields[key] = field
+	return field, nil
+}
+
 func (sb *SchemaBuilder) updateField(key string, value interface{}, repetition parquet.Repetition) (Node, error) {
-	parsedNode, err := getNode(key, value, repetition)
+	if override, ok := sb.overrides[key]; ok {
+		return sb.overrideField(key, override, repetition)
+	}
+	parsedNode, err := sb.getNode(key, key, value, repetition)
 	if err != nil {
 		return nil, err
 	}
 	return sb.checkOrUpdateNode(key, parsedNode)
 }
 
+// getNode returns the field of the value with the path like getNode, the elements of a list whose path
+// is overridden (see Overrides) are forced instead of inferred
+func (sb *SchemaBuilder) getNode(path, key string, value interface{}, repetition parquet.Repetition) (Node, error) {
+	slice, ok := value.([]interface{})
+	if !ok || !sb.overrides.hasElements(path) {
+		return getNode(key, value, repetition)
+	}
+	path = elementPath(path)
+	var element Node
+	var err error
+	if override, ok := sb.overrides[path]; ok {
+		// the elements of a list are always repeated
+		override.ForceRepetition = false
+		element, err = newOverrideNode("element", override, parquet.Repetitions.Repeated)
+	} else {
+		element, err = inferArrayElementNode(slice, func(e interface{}) (Node, error) {
+			return sb.getNode(path, "element", e, parquet.Repetitions.Repeated)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewListNode(key, repetition, element), nil
+}
+
 func (sb *SchemaBuilder) UpdateSchema(obj tfJson.NDJsonRecord) error {
 	repetition := parquet.Repetitions.Required
 	if !sb.firstRun {
@@ -259,6 +341,7 @@ func (sb *SchemaBuilder) UpdateSchema(obj tfJson.NDJsonRecord) error {
 	}
 	defer func() {
 		sb.firstRun = false
+		sb.records++
 	}()
 
 	for key, value := range obj {
@@ -273,6 +356,9 @@ func (sb *SchemaBuilder) UpdateSchema(obj tfJson.NDJsonRecord) error {
 	}
 
 	for key := range missingFields {
+		if override, ok := sb.overrides[key]; ok && override.ForceRepetition {
+			continue
+		}
 		field, ok := sb.fields[key]
 		if ok {
 			field.SetRepetition(parquet.Repetitions.Optional)
@@ -284,19 +370,89 @@ func (sb *SchemaBuilder) UpdateSchema(obj tfJson.NDJsonRecord) error {
 }
 
 func (sb *SchemaBuilder) Schema() *Schema {
+	if !sb.naming.enabled() {
+		return &Schema{
+			fields:    maps.Clone(sb.fields),
+			overrides: sb.overrides,
+			records:   sb.records,
+			origin:    sb.origin,
+		}
+	}
+	keys := make([]string, 0, len(sb.fields))
+	for key := range sb.fields {
+		keys = append(keys, key)
+	}
+	names := sb.naming.ResolveColumnNames(keys)
+	fields := make(map[string]Node, len(sb.fields))
+	for key, field := range sb.fields {
+		renamed := field.Clone()
+		renamed.SetName(names[key])
+		fields[key] = renamed
+	}
 	return &Schema{
-		fields: maps.Clone(sb.fields),
+		fields:    fields,
+		overrides: sb.overrides,
+		records:   sb.records,
+		origin:    sb.origin,
 	}
 }
 
 type Schema struct {
-	fields map[string]Node
+	fields    map[string]Node
+	overrides Overrides
+
+	records int64
+	origin  string
+}
+
+// NumRecords returns the number of JSON records the schema was inferred from
+func (s *Schema) NumRecords() int64 {
+	return s.records
 }
 
-func (s *Schema) root() (*schema.GroupNode, error) {
+// IsOverridden returns true if the type of the field with the path (see Overrides) was forced by an override
+func (s *Schema) IsOverridden(path string) bool {
+	_, ok := s.overrides[path]
+	return ok
+}
+
+// Overrides returns the overrides of the fields whose type was forced, indexed by the path
+func (s *Schema) Overrides() Overrides {
+	return s.overrides
+}
+
+// OriginalNames returns the JSON keys of the renamed columns indexed by the column name
+func (s *Schema) OriginalNames() map[string]string {
+	names := make(map[string]string)
+	for key, field := range s.fields {
+		if field.GetName() != key {
+			names[field.GetName()] = key
+		}
+	}
+	return names
+}
+
+// Without returns a copy of the schema without the fields with the JSON keys
+func (s *Schema) Without(keys ...string) *Schema {
+	fields := make(map[string]Node, len(s.fields))
+	for key, field := range s.fields {
+		fields[key] = field
+	}
+	for _, key := range keys {
+		delete(fields, key)
+	}
+	return &Schema{
+		fields:    fields,
+		overrides: s.overrides,
+		records:   s.records,
+		origin:    s.origin,
+	}
+}
+
+func (s *Schema) root(layout Layout) (*schema.GroupNode, error) {
 	fields := make(schema.FieldList, 0, len(s.fields))
 	for _, node := range s.fields {
-		pqNode, err := node.Node()
+		pqNode, err := layoutNode(node, layout)
 		if err != nil {
 			return nil, err
 		}
@@ -309,8 +465,14 @@ func (s *Schema) root() (*schema.GroupNode, error) {
 	return schema.NewGroupNode("schema", parquet.Repetitions.Required, fields, -1)
 }
 
+// Schema returns the parquet schema in the default layout, see Layout
 func (s *Schema) Schema() (*schema.Schema, error) {
-	root, err := s.root()
+	return s.SchemaWithLayout(Layout{})
+}
+
+// SchemaWithLayout returns the parquet schema with the lists and the timestamps in the layout
+func (s *Schema) SchemaWithLayout(layout Layout) (*schema.Schema, error) {
+	root, err := s.root(layout)
 	if err != nil {
 		return nil, err
 	}
@@ -318,7 +480,7 @@ func (s *Schema) Schema() (*schema.Schema, error) {
 }
 
 func (s *Schema) FieldByPath(path []string) Node {
-	if path == nil {
+	if len(path) == 0 {
 		return nil
 	}
 	name := path[0]
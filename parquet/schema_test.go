@@ -3,9 +3,11 @@ package parquet_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"os"
 	"testing"
 
+	pq "github.com/apache/arrow-go/v18/parquet"
 	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
 	"github.com/stretchr/testify/require"
 	tfJson "github.com/thermofisher/json2parquet/json"
@@ -117,10 +119,14 @@ func TestBoolArraySchema(t *testing.T) {
 		`{"availability":[false], "presence": []}`
 	schema := `required group field_id=-1 schema {
   required group field_id=-1 availability (List) {
-    repeated boolean field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional boolean field_id=-1 element;
+    }
   }
   optional group field_id=-1 presence (List) {
-    repeated boolean field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional boolean field_id=-1 element;
+    }
   }
 }
 `
@@ -133,10 +139,14 @@ func TestIntAndFloatArraySchema(t *testing.T) {
 		`{"float":[13, 3.4, 37], "int":[]}`
 	schema := `required group field_id=-1 schema {
   required group field_id=-1 float (List) {
-    repeated double field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional double field_id=-1 element;
+    }
   }
   optional group field_id=-1 int (List) {
-    repeated int64 field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element;
+    }
   }
 }
 `
@@ -149,10 +159,14 @@ func TestStringArraySchema(t *testing.T) {
 		`{"base64": ["anNvbiB0byBwYXJxdWV0!"]}`
 	schema := `required group field_id=-1 schema {
   optional group field_id=-1 base64 (List) {
-    repeated byte_array field_id=-1 element (String);
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element (String);
+    }
   }
   optional group field_id=-1 string (List) {
-    repeated byte_array field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element;
+    }
   }
 }
 `
@@ -164,7 +178,9 @@ func TestRFC3339StringArraySchema(t *testing.T) {
 		`{"rfc3339": ["2014-04-15T18:00:15-07:00"]}`
 	schema := `required group field_id=-1 schema {
   required group field_id=-1 rfc3339 (List) {
-    repeated int64 field_id=-1 element (Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false));
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element (Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false));
+    }
   }
 }
 `
@@ -174,12 +190,177 @@ func TestRFC3339StringArraySchema(t *testing.T) {
 		`{"optional": ["2014-04-15T18:00:15-07:00"], "string": ["2014-04-15T18:00:15-07:00"]}`
 	schema2 := `required group field_id=-1 schema {
   optional group field_id=-1 optional (List) {
-    repeated int64 field_id=-1 element (Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false));
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element (Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false));
+    }
   }
   required group field_id=-1 string (List) {
-    repeated byte_array field_id=-1 element (String);
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element (String);
+    }
   }
 }
 `
 	testBuildSchemaForJSON(t, stringJSON2, schema2)
 }
+
+func TestFlattenedObjectsSchema(t *testing.T) {
+	nestedJSON := `{"a": {"b": {"c": 1, "d": {"e": true}}, "f": "some text"}, "g": [{"h": 1}]}` + "\n" +
+		`{"a": {"b": {"c": 2}, "f": "SGVsbG8="}, "i": null}`
+
+	var input bytes.Buffer
+	input.WriteString(nestedJSON)
+	reader, err := tfJson.New(&input)
+	require.NoError(t, err)
+	reader.SetFlattenNestedObjects(".", 2)
+
+	sb := parquet.NewSchemaBuilder()
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+
+	sc, err := sb.Schema().Schema()
+	require.NoError(t, err)
+	schema := `required group field_id=-1 schema {
+  required int64 field_id=-1 a.b.c;
+  optional byte_array field_id=-1 a.b.d (JSON);
+  required byte_array field_id=-1 a.f (String);
+  optional byte_array field_id=-1 g (JSON);
+}
+`
+	require.Equal(t, schema, sc.String())
+}
+
+func TestFlattenedKeyCollision(t *testing.T) {
+	jsonStr := `{"a.b": 1, "a": {"b": 2}}` + "\n" +
+		`{"a": {"b": 3}, "c": {"a.b": 4, "a": {"b": 5}}}` + "\n" +
+		`{"a": {"b": 6}, "a.c": 7}`
+
+	var input bytes.Buffer
+	input.WriteString(jsonStr)
+	reader, err := tfJson.New(&input)
+	require.NoError(t, err)
+	reader.SetFlattenNestedObjects(".", 0)
+
+	// the records with colliding keys are skipped instead of keeping one of the values
+	var records []tfJson.NDJsonRecord
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		records = append(records, data)
+	})
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.EqualValues(t, "6", records[0]["a.b"])
+	require.EqualValues(t, "7", records[0]["a.c"])
+}
+
+func TestOverriddenSchema(t *testing.T) {
+	jsonStr := `{"zip": 12345, "id": 1, "created": 1713196815000, "score": 1}` + "\n" +
+		`{"zip": "01234", "created": 1713196816000, "score": 2.5}`
+
+	var input bytes.Buffer
+	input.WriteString(jsonStr)
+	reader, err := tfJson.New(&input)
+	require.NoError(t, err)
+
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOverrides(parquet.Overrides{
+		"zip": {Type: parquet.NodeTypeByteArray, LogicalType: parquet.LogicalTypeUTF8},
+		"id": {
+			Type:            parquet.NodeTypeInt64,
+			Repetition:      pq.Repetitions.Optional,
+			ForceRepetition: true,
+		},
+		"created": {Type: parquet.NodeTypeInt64, ExtendedType: parquet.ExtendedTypeEpochMillis},
+	})
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+
+	sc, err := sb.Schema().Schema()
+	require.NoError(t, err)
+	schema := `required group field_id=-1 schema {
+  required int64 field_id=-1 created (Timestamp(isAdjustedToUTC=true, timeUnit=milliseconds, is_from_converted_type=false, force_set_converted_type=false));
+  optional int64 field_id=-1 id;
+  required double field_id=-1 score;
+  required byte_array field_id=-1 zip (String);
+}
+`
+	require.Equal(t, schema, sc.String())
+}
+
+func TestOverriddenListElements(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOverrides(parquet.Overrides{
+		"codes[]":     {Type: parquet.NodeTypeByteArray, LogicalType: parquet.LogicalTypeUTF8},
+		"matrix[][]":  {Type: parquet.NodeTypeFloat64},
+		"required[]":  {Type: parquet.NodeTypeInt64, Repetition: pq.Repetitions.Required, ForceRepetition: true},
+		"unused.path": {Type: parquet.NodeTypeInt64},
+	})
+	records := []map[string]interface{}{
+		{"codes": []interface{}{json.Number("1"), "a"}, "matrix": []interface{}{[]interface{}{json.Number("1")}}, "required": []interface{}{json.Number("1")}},
+		{"codes": []interface{}{true}, "matrix": []interface{}{[]interface{}{json.Number("2.5"), "3"}}, "required": []interface{}{}},
+	}
+	for _, record := range records {
+		require.NoError(t, sb.UpdateSchema(record))
+	}
+	require.True(t, sb.Schema().IsOverridden("codes[]"))
+	require.False(t, sb.Schema().IsOverridden("codes"))
+
+	sc, err := sb.Schema().Schema()
+	require.NoError(t, err)
+	schema := `required group field_id=-1 schema {
+  required group field_id=-1 codes (List) {
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element (String);
+    }
+  }
+  required group field_id=-1 matrix (List) {
+    repeated group field_id=-1 list {
+      optional group field_id=-1 element (List) {
+        repeated group field_id=-1 list {
+          optional double field_id=-1 element;
+        }
+      }
+    }
+  }
+  required group field_id=-1 required (List) {
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element;
+    }
+  }
+}
+`
+	require.Equal(t, schema, sc.String())
+}
+
+func TestParseOverride(t *testing.T) {
+	key, override, err := parquet.ParseOverride("id=int64:required")
+	require.NoError(t, err)
+	require.Equal(t, "id", key)
+	require.Equal(t, parquet.Override{
+		Type:            parquet.NodeTypeInt64,
+		Repetition:      pq.Repetitions.Required,
+		ForceRepetition: true,
+	}, override)
+
+	key, override, err = parquet.ParseOverride("a.b=timestamp_millis")
+	require.NoError(t, err)
+	require.Equal(t, "a.b", key)
+	require.Equal(t, parquet.Override{Type: parquet.NodeTypeInt64, ExtendedType: parquet.ExtendedTypeEpochMillis}, override)
+
+	key, override, err = parquet.ParseOverride("tags[]=string")
+	require.NoError(t, err)
+	require.Equal(t, "tags[]", key)
+	require.Equal(t, parquet.Override{Type: parquet.NodeTypeByteArray, LogicalType: parquet.LogicalTypeUTF8}, override)
+	_, _, err = parquet.ParseOverride("tags[]=string:required")
+	require.Error(t, err)
+
+	_, _, err = parquet.ParseOverride("id=uint8")
+	require.ErrorIs(t, err, parquet.ErrTypeNotSupported)
+	_, _, err = parquet.ParseOverride("id=int64:repeated")
+	require.Error(t, err)
+	_, _, err = parquet.ParseOverride("id")
+	require.Error(t, err)
+}
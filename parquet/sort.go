@@ -0,0 +1,530 @@
+package parquet
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/log"
+)
+
+// maximum number of spill files merged at once, more files are merged in several passes
+const mergeFanIn = 64
+
+// SortColumn is a column the rows are sorted by
+type SortColumn struct {
+	// Key is the JSON key of the column
+	Key        string
+	Descending bool
+	NullsFirst bool
+}
+
+// ParseSortColumns parses a comma separated list of sort columns in the format
+// key[:asc|desc][:nulls_first|nulls_last]. The nulls are first in ascending order
+// and last in descending order unless set otherwise.
+func ParseSortColumns(s string) ([]SortColumn, error) {
+	var columns []SortColumn
+	for _, column := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(column), ":")
+		if parts[0] == "" {
+			return nil, fmt.Errorf("invalid sort column(%v)", column)
+		}
+		sc := SortColumn{Key: parts[0]}
+		var nulls string
+		for _, part := range parts[1:] {
+			switch strings.ToLower(part) {
+			case "asc":
+				sc.Descending = false
+			case "desc":
+				sc.Descending = true
+			case "nulls_first", "nulls_last":
+				nulls = strings.ToLower(part)
+			default:
+				return nil, fmt.Errorf("%w: sort order(%v) of column(%v)", ErrOpNotSupported, part, parts[0])
+			}
+		}
+		sc.NullsFirst = nulls == "nulls_first" || (nulls == "" && !sc.Descending)
+		columns = append(columns, sc)
+	}
+	return columns, nil
+}
+
+// sortValue is the value of a sort column converted to its physical type, only one of the
+// values is set depending on the type of the column
+type sortValue struct {
+	valid bool
+	i     int64
+	f     float64
+	s     string
+}
+
+func compareSortValues(a, b sortValue) int {
+	if c := cmp.Compare(a.i, b.i); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(a.f, b.f); c != 0 {
+		return c
+	}
+	return strings.Compare(a.s, b.s)
+}
+
+type sortKey struct {
+	SortColumn
+	convert func(v interface{}) (sortValue, bool)
+}
+
+func newSortKey(column SortColumn, field Node) (sortKey, error) {
+	key := sortKey{SortColumn: column}
+	switch {
+	case field.GetExtendedType() == ExtendedTypeRFC3339:
+		key.convert = func(v interface{}) (sortValue, bool) {
+			nanos, ok := tfJson.ToRFC3339ToTimestampNano(v)
+			return sortValue{valid: ok, i: nanos}, ok
+		}
+	case field.GetType() == NodeTypeBoolean:
+		key.convert = func(v interface{}) (sortValue, bool) {
+			b, ok := tfJson.CoerceToBool(v)
+			if b {
+				return sortValue{valid: ok, i: 1}, ok
+			}
+			return sortValue{valid: ok}, ok
+		}
+	case field.GetType() == NodeTypeInt64:
+		key.convert = func(v interface{}) (sortValue, bool) {
+			i, ok := tfJson.CoerceToInt64(v)
+			return sortValue{valid: ok, i: i}, ok
+		}
+	case field.GetType() == NodeTypeFloat64:
+		key.convert = func(v interface{}) (sortValue, bool) {
+			f, ok := tfJson.CoerceToFloat64(v)
+			return sortValue{valid: ok, f: f}, ok
+		}
+	case field.GetType() == NodeTypeByteArray:
+		// byte arrays are ordered as unsigned bytes like in the parquet statistics
+		key.convert = func(v interface{}) (sortValue, bool) {
+			s, ok := tfJson.CoerceToString(v)
+			return sortValue{valid: ok, s: s}, ok
+		}
+	default:
+		return key, fmt.Errorf("%w: sort by list or group column(%v)", ErrOpNotSupported, column.Key)
+	}
+	return key, nil
+}
+
+// newSortKeys returns the sort keys of the columns of the schema
+func newSortKeys(sc *Schema, columns []SortColumn) ([]sortKey, error) {
+	keys := make([]sortKey, 0, len(columns))
+	for _, column := range columns {
+		field, ok := sc.fields[column.Key]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort column(%v)", column.Key)
+		}
+		key, err := newSortKey(column, field)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// sortingColumns returns the sorting columns of the row group metadata
+func sortingColumns(sc *Schema, pqSc *schema.Schema, columns []SortColumn) ([]parquet.SortingColumn, error) {
+	sorting := make([]parquet.SortingColumn, 0, len(columns))
+	for _, column := range columns {
+		// the columns of Arrow records written without the schema are named by the keys
+		name := column.Key
+		if sc != nil {
+			field, ok := sc.fields[column.Key]
+			if !ok {
+				return nil, fmt.Errorf("unknown sort column(%v)", column.Key)
+			}
+			name = field.GetName()
+		}
+		idx := pqSc.ColumnIndexByName(name)
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: sort by list or group column(%v)", ErrOpNotSupported, column.Key)
+		}
+		sorting = append(sorting, parquet.SortingColumn{
+			ColumnIdx:  int32(idx), //nolint:gosec
+			Descending: column.Descending,
+			NullsFirst: column.NullsFirst,
+		})
+	}
+	return sorting, nil
+}
+
+// sortRecord is a record with the values of its sort columns
+type sortRecord struct {
+	data   map[string]interface{}
+	values []sortValue
+}
+
+func newSortRecord(keys []sortKey, data map[string]interface{}) sortRecord {
+	r := sortRecord{data: data, values: make([]sortValue, len(keys))}
+	for i, k := range keys {
+		if v, ok := data[k.Key]; ok && v != nil {
+			r.values[i], _ = k.convert(v)
+		}
+	}
+	return r
+}
+
+// compareSortRecords compares the records by the sort keys, the values that cannot be converted to
+// the type of the column are ordered as nulls
+func compareSortRecords(keys []sortKey, a, b sortRecord) int {
+	for i, k := range keys {
+		va, vb := a.values[i], b.values[i]
+		switch {
+		case !va.valid && !vb.valid:
+			continue
+		case !va.valid || !vb.valid:
+			if va.valid == k.NullsFirst {
+				return 1
+			}
+			return -1
+		}
+		c := compareSortValues(va, vb)
+		if k.Descending {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortRecords sorts the records by the sort keys, the order of equal records is kept
+func sortRecords(keys []sortKey, records []map[string]interface{}) {
+	sorted := make([]sortRecord, len(records))
+	for i, data := range records {
+		sorted[i] = newSortRecord(keys, data)
+	}
+	slices.SortStableFunc(sorted, func(a, b sortRecord) int {
+		return compareSortRecords(keys, a, b)
+	})
+	for i, r := range sorted {
+		records[i] = r.data
+	}
+}
+
+// RecordWriter is the interface of the Writer, RollingWriter and PartitionedWriter
+type RecordWriter interface {
+	Write(data map[string]interface{}) error
+	AppendKeyValueMetadata(key, value string) error
+	Close() error
+	Abort()
+}
+
+// SortingWriter sorts all the rows by the sort columns before they are written to the writer. The rows
+// are sorted in memory up to the buffer size, larger inputs are sorted in runs spilled to temporary files
+// that are merged when the writer is closed (external merge sort).
+type SortingWriter struct {
+	writer     RecordWriter
+	keys       []sortKey
+	bufferRows int
+	tempDir    string
+
+	// approximate memory size of the buffered rows, see SetMemoryLimit
+	memoryLimit int64
+	bytes       int64
+	peakBytes   int64
+
+	records  []map[string]interface{}
+	runs     []string // spill files of the sorted runs in the order of the input
+	metadata []keyValue
+	closed   bool
+}
+
+// NewSortingWriter creates a writer sorting the rows by the columns of the schema before they are written
+// to the writer, at most bufferRows rows are kept in memory and the sorted runs are spilled to temporary
+// files in tempDir (the default directory for temporary files when empty). The writer should be created with
+// the WithSortingColumns option to record the order in the row group metadata.
+func NewSortingWriter(writer RecordWriter, sc *Schema, columns []SortColumn, bufferRows int, tempDir string) (*SortingWriter, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("no sort columns")
+	}
+	if bufferRows <= 0 {
+		return nil, fmt.Errorf("invalid sort buffer size(%v)", bufferRows)
+	}
+	keys, err := newSortKeys(sc, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &SortingWriter{
+		writer:     writer,
+		keys:       keys,
+		bufferRows: bufferRows,
+		tempDir:    tempDir,
+	}, nil
+}
+
+// SetMemoryLimit limits the approximate memory size of the buffered rows, the sorted run is spilled
+// when the limit is reached before the buffer size (see WithMemoryLimit)
+func (sw *SortingWriter) SetMemoryLimit(size int64) {
+	sw.memoryLimit = size
+}
+
+// PeakBufferedBytes returns the peak approximate memory size of the buffered rows
+func (sw *SortingWriter) PeakBufferedBytes() int64 {
+	return sw.peakBytes
+}
+
+// Unwrap returns the writer the sorted rows are written to
+func (sw *SortingWriter) Unwrap() RecordWriter {
+	return sw.writer
+}
+
+func (sw *SortingWriter) Write(data map[string]interface{}) error {
+	if sw.closed {
+		return errors.New("writer is closed")
+	}
+	sw.records = append(sw.records, data)
+	sw.bytes += recordSize(data)
+	sw.peakBytes = max(sw.peakBytes, sw.bytes)
+	if len(sw.records) < sw.bufferRows && (sw.memoryLimit <= 0 || sw.bytes < sw.memoryLimit) {
+		return nil
+	}
+	return sw.spill()
+}
+
+// spill sorts the buffered rows and writes them to a temporary file
+func (sw *SortingWriter) spill() error {
+	sortRecords(sw.keys, sw.records)
+	name, err := sw.writeRun(func(write func(map[string]interface{}) error) error {
+		for _, data := range sw.records {
+			if err := write(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sw.records = nil
+	sw.bytes = 0
+	if err != nil {
+		return err
+	}
+	sw.runs = append(sw.runs, name)
+	return nil
+}
+
+// writeRun writes the rows passed by the produce function to a new spill file as NDJSON and returns
+// the name of the file, the file is removed on failure
+func (sw *SortingWriter) writeRun(produce func(write func(map[string]interface{}) error) error) (string, error) {
+	f, err := os.CreateTemp(sw.tempDir, "json2parquet-sort-*.ndjson")
+	if err != nil {
+		return "", err
+	}
+	buf := bufio.NewWriterSize(f, outputBufferSize)
+	enc := json.NewEncoder(buf)
+	rows := 0
+	err = produce(func(data map[string]interface{}) error {
+		rows++
+		return enc.Encode(data)
+	})
+	if err == nil {
+		err = buf.Flush()
+	}
+	if err == nil {
+		err = f.Close()
+	} else {
+		_ = f.Close()
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	log.Logger().Debugf("spilled %v sorted rows to %v", rows, f.Name())
+	return f.Name(), nil
+}
+
+// run reads the records of a spill file
+type run struct {
+	index   int
+	file    *os.File
+	decoder *json.Decoder
+	current sortRecord
+}
+
+func (r *run) next(keys []sortKey) error {
+	var data map[string]interface{}
+	if err := r.decoder.Decode(&data); err != nil {
+		return err
+	}
+	r.current = newSortRecord(keys, data)
+	return nil
+}
+
+// runHeap orders the runs by their current records, the runs earlier in the input first when the records
+// are equal, so the merge is stable
+type runHeap struct {
+	keys []sortKey
+	runs []*run
+}
+
+func (h *runHeap) Len() int { return len(h.runs) }
+
+func (h *runHeap) Less(i, j int) bool {
+	c := compareSortRecords(h.keys, h.runs[i].current, h.runs[j].current)
+	if c == 0 {
+		return h.runs[i].index < h.runs[j].index
+	}
+	return c < 0
+}
+
+func (h *runHeap) Swap(i, j int) { h.runs[i], h.runs[j] = h.runs[j], h.runs[i] }
+
+func (h *runHeap) Push(x any) { h.runs = append(h.runs, x.(*run)) }
+
+func (h *runHeap) Pop() any {
+	r := h.runs[len(h.runs)-1]
+	h.runs = h.runs[:len(h.runs)-1]
+	return r
+}
+
+// merge merges the sorted spill files and passes the rows to the write function in order
+func (sw *SortingWriter) merge(files []string, write func(map[string]interface{}) error) error {
+	h := &runHeap{keys: sw.keys}
+	defer func() {
+		for _, r := range h.runs {
+			_ = r.file.Close()
+		}
+	}()
+	for i, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		decoder := json.NewDecoder(bufio.NewReader(f))
+		decoder.UseNumber()
+		r := &run{index: i, file: f, decoder: decoder}
+		if err = r.next(sw.keys); errors.Is(err, io.EOF) {
+			_ = f.Close()
+			continue
+		} else if err != nil {
+			_ = f.Close()
+			return err
+		}
+		h.runs = append(h.runs, r)
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		r := h.runs[0]
+		if err := write(r.current.data); err != nil {
+			return err
+		}
+		err := r.next(sw.keys)
+		if errors.Is(err, io.EOF) {
+			_ = r.file.Close()
+			heap.Pop(h)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		heap.Fix(h, 0)
+	}
+	return nil
+}
+
+// mergeRuns merges the spill files into the writer, when there are more files than can be merged at once
+// the first files are merged into a new spill file that takes their place until the number of files is
+// small enough
+func (sw *SortingWriter) mergeRuns() error {
+	for len(sw.runs) > mergeFanIn {
+		files := sw.runs[:mergeFanIn]
+		name, err := sw.writeRun(func(write func(map[string]interface{}) error) error {
+			return sw.merge(files, write)
+		})
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			_ = os.Remove(f)
+		}
+		sw.runs = append([]string{name}, sw.runs[mergeFanIn:]...)
+	}
+	return sw.merge(sw.runs, sw.writer.Write)
+}
+
+func (sw *SortingWriter) removeRuns() {
+	for _, name := range sw.runs {
+		_ = os.Remove(name)
+	}
+	sw.runs = nil
+}
+
+// AppendKeyValueMetadata adds an entry to the footer metadata, the entry is added to the writer
+// before the sorted rows are written when the writer is closed, so it is in all the files of a
+// RollingWriter or a PartitionedWriter
+func (sw *SortingWriter) AppendKeyValueMetadata(key, value string) error {
+	if sw.closed {
+		return errors.New("writer is closed")
+	}
+	sw.metadata = append(sw.metadata, keyValue{key: key, value: value})
+	return nil
+}
+
+// Close writes the sorted rows to the writer and closes it, the writer is aborted on failure
+func (sw *SortingWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	defer sw.removeRuns()
+	if err := sw.writeSorted(); err != nil {
+		sw.writer.Abort()
+		return err
+	}
+	return sw.writer.Close()
+}
+
+func (sw *SortingWriter) writeSorted() error {
+	for _, e := range sw.metadata {
+		if err := sw.writer.AppendKeyValueMetadata(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	if len(sw.runs) == 0 {
+		sortRecords(sw.keys, sw.records)
+		for _, data := range sw.records {
+			if err := sw.writer.Write(data); err != nil {
+				return err
+			}
+		}
+		sw.records = nil
+		sw.bytes = 0
+	} else {
+		if len(sw.records) > 0 {
+			if err := sw.spill(); err != nil {
+				return err
+			}
+		}
+		if err := sw.mergeRuns(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Abort discards the buffered rows and the spill files and aborts the writer
+func (sw *SortingWriter) Abort() {
+	if sw.closed {
+		return
+	}
+	sw.closed = true
+	sw.records = nil
+	sw.removeRuns()
+	sw.writer.Abort()
+}
@@ -0,0 +1,207 @@
+package parquet_test
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	pqparquet "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func TestParseSortColumns(t *testing.T) {
+	columns, err := parquet.ParseSortColumns("tenant_id, timestamp:desc,name:asc:nulls_last,id:desc:nulls_first")
+	require.NoError(t, err)
+	require.Equal(t, []parquet.SortColumn{
+		{Key: "tenant_id", NullsFirst: true},
+		{Key: "timestamp", Descending: true},
+		{Key: "name"},
+		{Key: "id", Descending: true, NullsFirst: true},
+	}, columns)
+	_, err = parquet.ParseSortColumns("id:up")
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+	_, err = parquet.ParseSortColumns(",id")
+	require.Error(t, err)
+}
+
+func testSortSchema(t *testing.T) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"tenant": "a",
+		"ts":     "2024-10-01T10:00:00Z",
+		"seq":    json.Number("1"),
+	}))
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"seq": json.Number("2")}))
+	return sb.Schema()
+}
+
+// testSortRecords returns records in random order with some null tenants, seq is the input order
+func testSortRecords(rows int) []map[string]interface{} {
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec
+	records := make([]map[string]interface{}, rows)
+	for i := range records {
+		record := map[string]interface{}{
+			"ts":  "2024-10-01T10:00:0" + strconv.Itoa(rnd.Intn(10)) + "+00:00",
+			"seq": json.Number(strconv.Itoa(i)),
+		}
+		if tenant := rnd.Intn(5); tenant > 0 {
+			record["tenant"] = "tenant-" + strconv.Itoa(tenant)
+		}
+		records[i] = record
+	}
+	return records
+}
+
+// testExpectedOrder returns the sequence numbers of the records sorted by tenant (nulls first) and ts descending
+func testExpectedOrder(records []map[string]interface{}) []int64 {
+	sorted := slices.Clone(records)
+	slices.SortStableFunc(sorted, func(a, b map[string]interface{}) int {
+		ta, okA := a["tenant"].(string)
+		tb, okB := b["tenant"].(string)
+		if okA != okB {
+			if okA {
+				return 1
+			}
+			return -1
+		}
+		if c := strings.Compare(ta, tb); c != 0 {
+			return c
+		}
+		return strings.Compare(b["ts"].(string), a["ts"].(string))
+	})
+	seq := make([]int64, len(sorted))
+	for i, r := range sorted {
+		seq[i], _ = r["seq"].(json.Number).Int64()
+	}
+	return seq
+}
+
+// readSeq returns the sequence numbers of the rows of the row groups of the file
+func readSeq(t *testing.T, reader *file.Reader) [][]int64 {
+	var seq [][]int64
+	for rg := range reader.NumRowGroups() {
+		rgr := reader.RowGroup(rg)
+		cr, err := rgr.Column(reader.MetaData().Schema.ColumnIndexByName("seq"))
+		require.NoError(t, err)
+		values := make([]int64, rgr.NumRows())
+		_, n, err := cr.(*file.Int64ColumnChunkReader).ReadBatch(rgr.NumRows(), values, nil, nil)
+		require.NoError(t, err)
+		require.EqualValues(t, rgr.NumRows(), n)
+		seq = append(seq, values)
+	}
+	return seq
+}
+
+func requireSortingColumns(t *testing.T, reader *file.Reader) {
+	sc := reader.MetaData().Schema
+	for rg := range reader.NumRowGroups() {
+		require.Equal(t, []pqparquet.SortingColumn{
+			{ColumnIdx: int32(sc.ColumnIndexByName("tenant")), NullsFirst: true}, //nolint:gosec
+			{ColumnIdx: int32(sc.ColumnIndexByName("ts")), Descending: true},     //nolint:gosec
+		}, reader.MetaData().RowGroup(rg).SortingColumns())
+	}
+}
+
+func testSortingWriter(t *testing.T, rows, bufferRows int) {
+	sc := testSortSchema(t)
+	columns, err := parquet.ParseSortColumns("tenant,ts:desc")
+	require.NoError(t, err)
+	dir := t.TempDir()
+	tempDir := t.TempDir()
+	path := filepath.Join(dir, "sorted.parquet")
+	wr, err := parquet.NewWriter(path, 100, sc, parquet.WithSortingColumns(columns...))
+	require.NoError(t, err)
+	sw, err := parquet.NewSortingWriter(wr, sc, columns, bufferRows, tempDir)
+	require.NoError(t, err)
+	records := testSortRecords(rows)
+	for _, record := range records {
+		require.NoError(t, sw.Write(record))
+	}
+	require.NoError(t, sw.AppendKeyValueMetadata("key", "value"))
+	require.NoError(t, sw.Close())
+	// the spill files are removed
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	reader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, testExpectedOrder(records), slices.Concat(readSeq(t, reader)...))
+	requireSortingColumns(t, reader)
+	require.Equal(t, "value", *reader.MetaData().KeyValueMetadata().FindValue("key"))
+}
+
+func TestSortingWriter(t *testing.T) {
+	testSortingWriter(t, 500, 1000)
+}
+
+func TestSortingWriterSpill(t *testing.T) {
+	// more runs than merged at once
+	testSortingWriter(t, 500, 7)
+}
+
+func TestWriteSortedRowGroupsParquet(t *testing.T) {
+	sc := testSortSchema(t)
+	columns, err := parquet.ParseSortColumns("tenant,ts:desc")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sorted.parquet")
+	wr, err := parquet.NewWriter(path, 10, sc, parquet.WithSortingColumns(columns...),
+		parquet.WithSortedRowGroups(50), parquet.WithRowGroupBytes(1<<20))
+	require.NoError(t, err)
+	records := testSortRecords(120)
+	for _, record := range records {
+		require.NoError(t, wr.Write(record))
+	}
+	require.NoError(t, wr.Close())
+
+	reader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	seq := readSeq(t, reader)
+	require.Len(t, seq, 3)
+	for i, rg := range seq {
+		require.Equal(t, testExpectedOrder(records[i*50:min(len(records), (i+1)*50)]), rg)
+	}
+	requireSortingColumns(t, reader)
+}
+
+func TestWriteSortedRowGroupsInvalidRowParquet(t *testing.T) {
+	sc := testSortSchema(t)
+	columns, err := parquet.ParseSortColumns("tenant,ts:desc")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sorted.parquet")
+	wr, err := parquet.NewWriter(path, 10, sc, parquet.WithSortingColumns(columns...),
+		parquet.WithSortedRowGroups(50))
+	require.NoError(t, err)
+	records := testSortRecords(50)
+	records[10]["seq"] = true
+	// the rows are converted when the row group is written, so the row group is incomplete
+	for _, record := range records[:49] {
+		require.NoError(t, wr.Write(record))
+	}
+	err = wr.Write(records[49])
+	require.ErrorContains(t, err, "cannot convert")
+	require.ErrorContains(t, wr.Write(records[0]), "writer failed")
+	require.ErrorContains(t, wr.Close(), "cannot convert")
+	_, err = os.Stat(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSortingWriterInvalidColumns(t *testing.T) {
+	sc := testSortSchema(t)
+	wr, err := parquet.NewWriterTo(io.Discard, 10, sc)
+	require.NoError(t, err)
+	_, err = parquet.NewSortingWriter(wr, sc, []parquet.SortColumn{{Key: "unknown"}}, 10, "")
+	require.Error(t, err)
+	_, err = parquet.NewWriterTo(io.Discard, 10, sc, parquet.WithSortingColumns(parquet.SortColumn{Key: "unknown"}))
+	require.Error(t, err)
+}
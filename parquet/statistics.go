@@ -0,0 +1,93 @@
+package parquet
+
+import (
+	"unicode/utf8"
+
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+)
+
+// truncateStatistics truncates the min/max values of the column chunks longer than size bytes (see
+// WithMaxStatisticsSize). The statistics of the metadata are the statistics of the file writer, so they
+// must be truncated after the last row group is flushed and before the footer is written. The metadata of
+// the columns encrypted with column keys is already encrypted, their statistics are not truncated.
+func truncateStatistics(md *metadata.FileMetaData, size int) {
+	for _, rg := range md.RowGroups {
+		for i, cc := range rg.Columns {
+			if cc.MetaData == nil || cc.MetaData.Statistics == nil {
+				continue
+			}
+			col := md.Schema.Column(i)
+			// the values of fixed length byte arrays cannot be shortened
+			if col.PhysicalType() != parquet.Types.ByteArray {
+				continue
+			}
+			text := isText(col.LogicalType())
+			stats := cc.MetaData.Statistics
+			inexact := false
+			if len(stats.MinValue) > size {
+				stats.MinValue = truncatedPrefix(stats.MinValue, size, text)
+				stats.IsMinValueExact = &inexact
+			}
+			if len(stats.MaxValue) > size {
+				stats.MaxValue = incrementPrefix(truncatedPrefix(stats.MaxValue, size, text), text)
+				stats.IsMaxValueExact = &inexact
+				if stats.MaxValue == nil {
+					// the prefix cannot be incremented, the max is unknown
+					stats.IsMaxValueExact = nil
+				}
+			}
+		}
+	}
+}
+
+// isText returns true for the logical types of UTF-8 strings, their values are truncated at the boundaries
+// of the characters
+func isText(lt schema.LogicalType) bool {
+	switch lt.(type) {
+	case schema.StringLogicalType, schema.JSONLogicalType, schema.EnumLogicalType:
+		return true
+	}
+	return false
+}
+
+// truncatedPrefix returns the longest prefix of the value of at most size bytes, the lower bound of the
+// values starting with the prefix
+func truncatedPrefix(value []byte, size int, text bool) []byte {
+	if text {
+		for size > 0 && !utf8.RuneStart(value[size]) {
+			size--
+		}
+	}
+	return value[:size:size]
+}
+
+// incrementPrefix returns the shortest value greater than all the values starting with the prefix by
+// incrementing its last byte (or the last character of a string), nil when the prefix consists of bytes
+// (or characters) that cannot be incremented
+func incrementPrefix(prefix []byte, text bool) []byte {
+	if text {
+		runes := []rune(string(prefix))
+		for i := len(runes) - 1; i >= 0; i-- {
+			r := runes[i] + 1
+			if r >= 0xD800 && r <= 0xDFFF {
+				// the surrogates are not valid characters
+				r = 0xE000
+			}
+			if r <= utf8.MaxRune {
+				runes[i] = r
+				return []byte(string(runes[:i+1]))
+			}
+		}
+		return nil
+	}
+	incremented := append([]byte(nil), prefix...)
+	for i := len(incremented) - 1; i >= 0; i-- {
+		if incremented[i] < 0xFF {
+			incremented[i]++
+			return incremented[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package parquet
+
+import (
+	"errors"
+
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+)
+
+var ErrNoSummary = errors.New("summary not available")
+
+// ColumnSummary describes the size of a column in the written file
+type ColumnSummary struct {
+	Path              string
+	Compression       compress.Compression
+	CompressedBytes   int64
+	UncompressedBytes int64
+	// CoercedNulls is the number of the values written as nulls because they could not be converted
+	// (see CoerceNull)
+	CoercedNulls int64
+}
+
+func ratio(uncompressed, compressed int64) float64 {
+	if compressed == 0 {
+		return 0
+	}
+	return float64(uncompressed) / float64(compressed)
+}
+
+// Ratio returns the compression ratio (uncompressed size / compressed size)
+func (cs ColumnSummary) Ratio() float64 {
+	return ratio(cs.UncompressedBytes, cs.CompressedBytes)
+}
+
+// Summary describes the written file
+type Summary struct {
+	Rows              int64
+	RowGroups         int
+	CompressedBytes   int64
+	UncompressedBytes int64
+	Columns           []ColumnSummary
+	// PeakBufferedBytes is the peak approximate memory size of the data buffered by the writer (see
+	// WithMemoryLimit)
+	PeakBufferedBytes int64
+}
+
+// Ratio returns the compression ratio of all columns
+func (s *Summary) Ratio() float64 {
+	return ratio(s.UncompressedBytes, s.CompressedBytes)
+}
+
+// NewSummary describes the file of the metadata, e.g. of a file opened by OpenFile. The coerced nulls
+// and the peak buffered data are only known by the writer of the file.
+func NewSummary(md *metadata.FileMetaData) (*Summary, error) {
+	s := &Summary{
+		Rows:      md.NumRows,
+		RowGroups: len(md.RowGroups),
+		Columns:   make([]ColumnSummary, md.Schema.NumColumns()),
+	}
+	for i := range md.Schema.NumColumns() {
+		s.Columns[i].Path = md.Schema.Column(i).Path()
+	}
+	for rg := range len(md.RowGroups) {
+		rgMd := md.RowGroup(rg)
+		for i := range rgMd.NumColumns() {
+			cc, err := rgMd.ColumnChunk(i)
+			if err != nil {
+				return nil, err
+			}
+			s.Columns[i].Compression = cc.Compression()
+			s.Columns[i].CompressedBytes += cc.TotalCompressedSize()
+			s.Columns[i].UncompressedBytes += cc.TotalUncompressedSize()
+			s.CompressedBytes += cc.TotalCompressedSize()
+			s.UncompressedBytes += cc.TotalUncompressedSize()
+		}
+	}
+	return s, nil
+}
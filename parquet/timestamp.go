@@ -0,0 +1,228 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	tfJson "github.com/thermofisher/json2parquet/json"
+)
+
+var ErrTimestampOverflow = errors.New("timestamp out of range")
+
+// TimestampUnit is the unit of the timestamps written to the int64 timestamp columns
+type TimestampUnit int
+
+const (
+	// TimestampUnitDefault keeps the precision of the values, nanoseconds for RFC3339 strings and
+	// milliseconds for integers with milliseconds since the Unix epoch
+	TimestampUnitDefault TimestampUnit = iota
+	TimestampUnitMillis
+	TimestampUnitMicros
+	TimestampUnitNanos
+)
+
+func (u TimestampUnit) String() string {
+	switch u {
+	case TimestampUnitDefault:
+		return "default"
+	case TimestampUnitMillis:
+		return "millis"
+	case TimestampUnitMicros:
+		return "micros"
+	case TimestampUnitNanos:
+		return "nanos"
+	}
+	return "unknown"
+}
+
+// ParseTimestampUnit parses the unit millis, micros or nanos, an empty string is the default unit
+func ParseTimestampUnit(s string) (TimestampUnit, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return TimestampUnitDefault, nil
+	case "millis":
+		return TimestampUnitMillis, nil
+	case "micros":
+		return TimestampUnitMicros, nil
+	case "nanos":
+		return TimestampUnitNanos, nil
+	}
+	return TimestampUnitDefault, fmt.Errorf("%w: timestamp unit(%v)", ErrOpNotSupported, s)
+}
+
+// the range of the timestamps of a unit
+var timestampRanges = map[TimestampUnit][2]time.Time{
+	TimestampUnitMillis: {time.UnixMilli(math.MinInt64), time.UnixMilli(math.MaxInt64)},
+	TimestampUnitMicros: {time.UnixMicro(math.MinInt64), time.UnixMicro(math.MaxInt64)},
+	TimestampUnitNanos:  {time.Unix(0, math.MinInt64), time.Unix(0, math.MaxInt64)},
+}
+
+const (
+	julianUnixEpoch = 2440588 // Julian day of 1970-01-01
+	secondsPerDay   = 24 * 60 * 60
+)
+
+// the range of the INT96 timestamps, the Julian day is a signed 32-bit integer
+var int96Range = [2]time.Time{
+	time.Unix(-julianUnixEpoch*secondsPerDay, 0),
+	time.Unix((math.MaxInt32-julianUnixEpoch+1)*secondsPerDay, -1),
+}
+
+// TimestampType is the parquet type of the timestamp columns, the columns of RFC3339 strings and of
+// integers with milliseconds since the Unix epoch (see ExtendedType). The zero value writes the
+// timestamps adjusted to UTC in the default unit.
+type TimestampType struct {
+	Unit TimestampUnit
+	// Local writes the timestamps not adjusted to UTC (isAdjustedToUTC=false), the RFC3339 strings are
+	// written as their local date and time without the offset
+	Local bool
+	// INT96 writes the timestamps as legacy INT96 values (nanoseconds of the Julian day) read by older
+	// versions of Hive, Impala and Spark, the unit is ignored
+	INT96 bool
+}
+
+// isTimestamp returns whether the values of the field are written as timestamps
+func isTimestamp(field Node) bool {
+	return (field.GetType() == NodeTypeByteArray && field.GetExtendedType() == ExtendedTypeRFC3339) ||
+		(field.GetType() == NodeTypeInt64 && field.GetExtendedType() == ExtendedTypeEpochMillis)
+}
+
+// unit returns the unit of the int64 values of the timestamp field
+func (t TimestampType) unit(field Node) TimestampUnit {
+	switch {
+	case t.INT96:
+		return TimestampUnitNanos
+	case t.Unit != TimestampUnitDefault:
+		return t.Unit
+	case field.GetExtendedType() == ExtendedTypeEpochMillis:
+		return TimestampUnitMillis
+	}
+	return TimestampUnitNanos
+}
+
+// node returns the parquet node of the timestamp field
+func (t TimestampType) node(field Node) (schema.Node, error) {
+	if t.INT96 {
+		return schema.NewPrimitiveNode(field.GetName(), field.GetRepetition(), parquet.Types.Int96, -1, -1)
+	}
+	units := map[TimestampUnit]schema.TimeUnitType{
+		TimestampUnitMillis: schema.TimeUnitMillis,
+		TimestampUnitMicros: schema.TimeUnitMicros,
+		TimestampUnitNanos:  schema.TimeUnitNanos,
+	}
+	return schema.NewPrimitiveNodeLogical(field.GetName(), field.GetRepetition(),
+		schema.NewTimestampLogicalType(!t.Local, units[t.unit(field)]), parquet.Types.Int64, 0, -1)
+}
+
+// arrowType returns the Arrow type of the timestamp field, INT96 timestamps are written from nanoseconds
+func (t TimestampType) arrowType(field Node) arrow.DataType {
+	units := map[TimestampUnit]arrow.TimeUnit{
+		TimestampUnitMillis: arrow.Millisecond,
+		TimestampUnitMicros: arrow.Microsecond,
+		TimestampUnitNanos:  arrow.Nanosecond,
+	}
+	typ := &arrow.TimestampType{Unit: units[t.unit(field)], TimeZone: "UTC"}
+	if t.Local {
+		typ.TimeZone = ""
+	}
+	return typ
+}
+
+// timeConverter returns the converter of the values of the timestamp field to the time
+func (t TimestampType) timeConverter(field Node, rules Coercion) ValueConverter[time.Time] {
+	if field.GetExtendedType() == ExtendedTypeEpochMillis {
+		millis := int64Converter(rules)
+		return func(v interface{}) (time.Time, bool) {
+			m, ok := millis(v)
+			if !ok {
+				return time.Time{}, false
+			}
+			return time.UnixMilli(m), true
+		}
+	}
+	return func(v interface{}) (time.Time, bool) {
+		s, ok := tfJson.ToString(v)
+		if !ok {
+			return time.Time{}, false
+		}
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return time.Time{}, false
+		}
+		if t.Local {
+			// the wall clock of the offset of the string
+			year, month, day := tm.Date()
+			hour, minute, sec := tm.Clock()
+			tm = time.Date(year, month, day, hour, minute, sec, tm.Nanosecond(), time.UTC)
+		}
+		return tm, true
+	}
+}
+
+func inRange(tm time.Time, r [2]time.Time) bool {
+	return !tm.Before(r[0]) && !tm.After(r[1])
+}
+
+// int64Converter returns the converter of the values of the timestamp field to the int64 values of its unit,
+// the timestamps out of the range of the unit cannot be converted
+func (t TimestampType) int64Converter(field Node, rules Coercion) ValueConverter[int64] {
+	toTime := t.timeConverter(field, rules)
+	unit := t.unit(field)
+	return func(v interface{}) (int64, bool) {
+		tm, ok := toTime(v)
+		if !ok || !inRange(tm, timestampRanges[unit]) {
+			return 0, false
+		}
+		switch unit {
+		case TimestampUnitMillis:
+			return tm.UnixMilli(), true
+		case TimestampUnitMicros:
+			return tm.UnixMicro(), true
+		}
+		return tm.UnixNano(), true
+	}
+}
+
+// int96Converter returns the converter of the values of the timestamp field to INT96 timestamps
+func (t TimestampType) int96Converter(field Node, rules Coercion) ValueConverter[parquet.Int96] {
+	toTime := t.timeConverter(field, rules)
+	return func(v interface{}) (parquet.Int96, bool) {
+		var i96 parquet.Int96
+		tm, ok := toTime(v)
+		if !ok || !inRange(tm, int96Range) {
+			return i96, false
+		}
+		sec := tm.Unix()
+		days := sec / secondsPerDay
+		if sec%secondsPerDay < 0 {
+			days--
+		}
+		nanos := (sec-days*secondsPerDay)*int64(time.Second) + int64(tm.Nanosecond())
+		binary.LittleEndian.PutUint64(i96[:8], uint64(nanos))
+		binary.LittleEndian.PutUint32(i96[8:], uint32(days+julianUnixEpoch))
+		return i96, true
+	}
+}
+
+// overflowError returns the function describing the values of the timestamp field that are valid
+// timestamps out of the range of the column, it returns nil for the other values
+func (t TimestampType) overflowError(field Node, rules Coercion) func(v interface{}) error {
+	toTime := t.timeConverter(field, rules)
+	typ := t.unit(field).String()
+	if t.INT96 {
+		typ = "INT96"
+	}
+	return func(v interface{}) error {
+		if _, ok := toTime(v); !ok {
+			return nil
+		}
+		return fmt.Errorf("%w: timestamp(%v) cannot be written in %v", ErrTimestampOverflow, v, typ)
+	}
+}
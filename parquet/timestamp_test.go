@@ -0,0 +1,172 @@
+package parquet_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/stretchr/testify/require"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func TestParseTimestampUnit(t *testing.T) {
+	for s, unit := range map[string]parquet.TimestampUnit{
+		"":       parquet.TimestampUnitDefault,
+		"millis": parquet.TimestampUnitMillis,
+		"MICROS": parquet.TimestampUnitMicros,
+		"nanos":  parquet.TimestampUnitNanos,
+	} {
+		parsed, err := parquet.ParseTimestampUnit(s)
+		require.NoError(t, err)
+		require.Equal(t, unit, parsed)
+	}
+	_, err := parquet.ParseTimestampUnit("seconds")
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+}
+
+func testTimestampSchema(t *testing.T) *parquet.Schema {
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOverrides(parquet.Overrides{
+		"ms": {Type: parquet.NodeTypeInt64, ExtendedType: parquet.ExtendedTypeEpochMillis},
+	})
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"ts":   "2024-10-01T10:00:00Z",
+		"ms":   json.Number("1"),
+		"list": []interface{}{"2024-10-01T10:00:00Z"},
+	}))
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"ts": "2024-10-01T10:00:00Z"}))
+	return sb.Schema()
+}
+
+// the record in the order of the columns: list, ms, ts
+var testTimestampRecord = map[string]interface{}{
+	"ts":   "2024-10-01T10:00:00.123456789+02:00",
+	"ms":   json.Number("1727776800123"),
+	"list": []interface{}{"1969-12-31T23:59:59.5Z"},
+}
+
+func writeTimestamps(t *testing.T, sc *parquet.Schema, typ parquet.TimestampType) *file.Reader {
+	var buf bytes.Buffer
+	wr, err := parquet.NewWriterTo(&buf, 10, sc, parquet.WithTimestampType(typ))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(testTimestampRecord))
+	require.NoError(t, wr.Close())
+	reader, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = reader.Close() })
+	return reader
+}
+
+func readInt64Values(t *testing.T, reader *file.Reader, i int) []int64 {
+	col, err := reader.RowGroup(0).Column(i)
+	require.NoError(t, err)
+	values := make([]int64, 10)
+	_, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(10, values, make([]int16, 10), make([]int16, 10))
+	require.NoError(t, err)
+	return values[:n]
+}
+
+func TestWriteTimestampTypes(t *testing.T) {
+	sc := testTimestampSchema(t)
+	ts := time.Date(2024, 10, 1, 8, 0, 0, 123456789, time.UTC)
+
+	reader := writeTimestamps(t, sc, parquet.TimestampType{})
+	schema := reader.MetaData().Schema
+	require.Equal(t, "Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false)",
+		schema.Column(2).LogicalType().String())
+	require.Equal(t, "Timestamp(isAdjustedToUTC=true, timeUnit=milliseconds, is_from_converted_type=false, force_set_converted_type=false)",
+		schema.Column(1).LogicalType().String())
+	require.Equal(t, []int64{-500000000}, readInt64Values(t, reader, 0))
+	require.Equal(t, []int64{1727776800123}, readInt64Values(t, reader, 1))
+	require.Equal(t, []int64{ts.UnixNano()}, readInt64Values(t, reader, 2))
+
+	reader = writeTimestamps(t, sc, parquet.TimestampType{Unit: parquet.TimestampUnitMicros})
+	schema = reader.MetaData().Schema
+	for i := range 3 {
+		require.Contains(t, schema.Column(i).LogicalType().String(), "timeUnit=microseconds")
+	}
+	require.Equal(t, []int64{-500000}, readInt64Values(t, reader, 0))
+	require.Equal(t, []int64{1727776800123000}, readInt64Values(t, reader, 1))
+	require.Equal(t, []int64{ts.UnixMicro()}, readInt64Values(t, reader, 2))
+
+	// the local date and time of the RFC3339 strings
+	reader = writeTimestamps(t, sc, parquet.TimestampType{Unit: parquet.TimestampUnitMillis, Local: true})
+	schema = reader.MetaData().Schema
+	require.Equal(t, "Timestamp(isAdjustedToUTC=false, timeUnit=milliseconds, is_from_converted_type=false, force_set_converted_type=false)",
+		schema.Column(2).LogicalType().String())
+	require.Equal(t, []int64{ts.Add(2 * time.Hour).UnixMilli()}, readInt64Values(t, reader, 2))
+	require.Equal(t, []int64{1727776800123}, readInt64Values(t, reader, 1))
+
+	reader = writeTimestamps(t, sc, parquet.TimestampType{INT96: true})
+	schema = reader.MetaData().Schema
+	expected := []time.Time{time.Unix(-1, 500000000), time.UnixMilli(1727776800123), ts}
+	for i := range 3 {
+		require.Equal(t, pq.Types.Int96, schema.Column(i).PhysicalType())
+		col, err := reader.RowGroup(0).Column(i)
+		require.NoError(t, err)
+		values := make([]pq.Int96, 1)
+		_, _, err = col.(*file.Int96ColumnChunkReader).ReadBatch(1, values, make([]int16, 1), make([]int16, 1))
+		require.NoError(t, err)
+		require.True(t, expected[i].Equal(values[0].ToTime()), "column %v: %v", i, values[0].ToTime())
+	}
+}
+
+func TestWriteTimestampOverflow(t *testing.T) {
+	sc := testTimestampSchema(t)
+	wr, err := parquet.NewWriterTo(io.Discard, 10, sc)
+	require.NoError(t, err)
+	// nanoseconds since the Unix epoch are limited to the years 1677 to 2262
+	require.ErrorIs(t, wr.Write(map[string]interface{}{"ts": "2300-01-01T00:00:00Z"}), parquet.ErrTimestampOverflow)
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"ts": "x"}), "cannot convert")
+	require.NoError(t, wr.Write(map[string]interface{}{"ts": "2262-01-01T00:00:00Z"}))
+	require.NoError(t, wr.Close())
+
+	wr, err = parquet.NewWriterTo(io.Discard, 10, sc, parquet.WithTimestampType(parquet.TimestampType{Unit: parquet.TimestampUnitMicros}))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"ts": "2300-01-01T00:00:00Z"}))
+	require.ErrorIs(t, wr.Write(map[string]interface{}{"ts": "2300-01-01T00:00:00Z", "ms": json.Number("9223372036854775807")}),
+		parquet.ErrTimestampOverflow)
+	require.NoError(t, wr.Close())
+
+	// the overflowing values are counted as the values that cannot be converted
+	wr, err = parquet.NewWriterTo(io.Discard, 10, sc, parquet.WithCoercion(parquet.CoerceNull),
+		parquet.WithTimestampType(parquet.TimestampType{INT96: true}))
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"ts": "2300-01-01T00:00:00Z", "ms": json.Number("9223372036854775807")}))
+	require.NoError(t, wr.Close())
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	require.EqualValues(t, 1, summary.Columns[1].CoercedNulls)
+	require.EqualValues(t, 0, summary.Columns[2].CoercedNulls)
+}
+
+func TestArrowWriterTimestampTypes(t *testing.T) {
+	sc := testTimestampSchema(t)
+	arrowSc, err := sc.ArrowSchemaWithLayout(parquet.Layout{
+		Timestamps: parquet.TimestampType{Unit: parquet.TimestampUnitMicros, Local: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "timestamp[us]", arrowSc.Field(2).Type.String())
+	_, err = sc.ArrowSchemaWithLayout(parquet.Layout{Lists: parquet.ListLayoutLegacy})
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+
+	var buf bytes.Buffer
+	wr, err := parquet.NewArrowWriterTo(&buf, 10, sc, parquet.WithTimestampType(parquet.TimestampType{INT96: true}))
+	require.NoError(t, err)
+	require.ErrorIs(t, wr.Write(map[string]interface{}{"ts": "2300-01-01T00:00:00Z"}), parquet.ErrTimestampOverflow)
+	require.NoError(t, wr.Write(testTimestampRecord))
+	require.NoError(t, wr.Close())
+
+	table, reader := readArrowTable(t, buf.Bytes())
+	require.Equal(t, pq.Types.Int96, reader.MetaData().Schema.Column(2).PhysicalType())
+	tr := array.NewTableReader(table, -1)
+	defer tr.Release()
+	require.True(t, tr.Next())
+	ts := tr.RecordBatch().Column(2).(*array.Timestamp)
+	require.Equal(t, time.Date(2024, 10, 1, 8, 0, 0, 123456789, time.UTC).UnixNano(), int64(ts.Value(0)))
+}
@@ -3,10 +3,20 @@ package parquet_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 
+	pq "github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
 	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
 	pqSchema "github.com/apache/arrow-go/v18/parquet/schema"
 	"github.com/stretchr/testify/require"
 	tfJson "github.com/thermofisher/json2parquet/json"
@@ -45,7 +55,7 @@ func testConvertJSON2Parquet(t *testing.T, json string, expSchema string, expRow
 		require.NoError(t, errW)
 	})
 	require.NoError(t, err)
-	wr.Close()
+	require.NoError(t, wr.Close())
 
 	f, err := os.Open("test.parquet")
 	require.NoError(t, err)
@@ -140,13 +150,19 @@ func TestWriteBoolArraysParquet(t *testing.T) {
 
 	schema := `required group field_id=-1 schema {
   optional group field_id=-1 array (List) {
-    repeated boolean field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional boolean field_id=-1 element;
+    }
   }
   required group field_id=-1 available (List) {
-    repeated boolean field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional boolean field_id=-1 element;
+    }
   }
   optional group field_id=-1 optional (List) {
-    repeated boolean field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional boolean field_id=-1 element;
+    }
   }
 }
 `
@@ -159,13 +175,19 @@ func TestWriteInt64ArraysParquet(t *testing.T) {
 		`{"available":[7], "optional":[9]}`
 	schema := `required group field_id=-1 schema {
   optional group field_id=-1 array (List) {
-    repeated int64 field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element;
+    }
   }
   required group field_id=-1 available (List) {
-    repeated int64 field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element;
+    }
   }
   optional group field_id=-1 optional (List) {
-    repeated int64 field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element;
+    }
   }
 }
 `
@@ -179,13 +201,19 @@ func TestWriteFloat64ArraysParquet(t *testing.T) {
 		`{"available":[],"array":[123.4]}`
 	schema := `required group field_id=-1 schema {
   optional group field_id=-1 array (List) {
-    repeated double field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional double field_id=-1 element;
+    }
   }
   required group field_id=-1 available (List) {
-    repeated double field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional double field_id=-1 element;
+    }
   }
   optional group field_id=-1 optional (List) {
-    repeated double field_id=-1 element;
+    repeated group field_id=-1 list {
+      optional double field_id=-1 element;
+    }
   }
 }
 `
@@ -198,13 +226,19 @@ func TestWriteStringArraysParquet(t *testing.T) {
 		`{"available":["Good day, gentlemen"],"optional":["I know I've made some very poor decisions recently"]}`
 	schema := `required group field_id=-1 schema {
   optional group field_id=-1 array (List) {
-    repeated byte_array field_id=-1 element (String);
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element (String);
+    }
   }
   required group field_id=-1 available (List) {
-    repeated byte_array field_id=-1 element (String);
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element (String);
+    }
   }
   optional group field_id=-1 optional (List) {
-    repeated byte_array field_id=-1 element (String);
+    repeated group field_id=-1 list {
+      optional byte_array field_id=-1 element (String);
+    }
   }
 }
 `
@@ -218,9 +252,629 @@ func TestWriteRFC3339StringArrayParquet(t *testing.T) {
 
 	schema := `required group field_id=-1 schema {
   required group field_id=-1 rfc3339 (List) {
-    repeated int64 field_id=-1 element (Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false));
+    repeated group field_id=-1 list {
+      optional int64 field_id=-1 element (Timestamp(isAdjustedToUTC=true, timeUnit=nanoseconds, is_from_converted_type=false, force_set_converted_type=false));
+    }
   }
 }
 `
 	testConvertJSON2Parquet(t, jsonStr, schema, 3)
 }
+
+func TestWriteListLayoutsParquet(t *testing.T) {
+	records := []map[string]interface{}{
+		{"id": json.Number("1"), "list": []interface{}{json.Number("1"), nil, json.Number("2")}},
+		{"id": json.Number("2"), "list": []interface{}{}},
+		{"id": json.Number("3")},
+	}
+	sb := parquet.NewSchemaBuilder()
+	for _, record := range records {
+		require.NoError(t, sb.UpdateSchema(record))
+	}
+	write := func(records []map[string]interface{}, opts ...parquet.WriterOption) []byte {
+		var buf bytes.Buffer
+		wr, err := parquet.NewWriterTo(&buf, 10, sb.Schema(), opts...)
+		require.NoError(t, err)
+		for _, record := range records {
+			require.NoError(t, wr.Write(record))
+		}
+		require.NoError(t, wr.Close())
+		return buf.Bytes()
+	}
+	readLevels := func(data []byte) (*file.Reader, []int64, []int16, []int16) {
+		reader, err := file.NewParquetReader(bytes.NewReader(data))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = reader.Close() })
+		col, err := reader.RowGroup(0).Column(1)
+		require.NoError(t, err)
+		values, defLevels, repLevels := make([]int64, 10), make([]int16, 10), make([]int16, 10)
+		total, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(10, values, defLevels, repLevels)
+		require.NoError(t, err)
+		return reader, values[:n], defLevels[:total], repLevels[:total]
+	}
+
+	// a missing list, an empty list and a null element are distinguished by the definition levels
+	data := write(records)
+	reader, values, defLevels, repLevels := readLevels(data)
+	require.Equal(t, "list.list.element", reader.MetaData().Schema.Column(1).ColumnPath().String())
+	require.Equal(t, []int64{1, 2}, values)
+	require.Equal(t, []int16{3, 2, 3, 1, 0}, defLevels)
+	require.Equal(t, []int16{0, 1, 1, 0, 0}, repLevels)
+	// the lists are read back by other readers
+	table, _ := readArrowTable(t, data)
+	require.Equal(t, `[[1 (null) 2] [] (null)]`, table.Column(1).Data().Chunk(0).String())
+
+	// the legacy layout has no null elements
+	legacy := parquet.WithListLayout(parquet.ListLayoutLegacy)
+	wr, err := parquet.NewWriterTo(io.Discard, 10, sb.Schema(), legacy)
+	require.NoError(t, err)
+	require.ErrorContains(t, wr.Write(records[0]), "null element")
+	wr.Abort()
+	reader, values, defLevels, repLevels = readLevels(write([]map[string]interface{}{
+		{"id": json.Number("1"), "list": []interface{}{json.Number("1"), json.Number("2")}},
+		records[1],
+		records[2],
+	}, legacy))
+	require.Equal(t, `required group field_id=-1 schema {
+  required int64 field_id=-1 id;
+  optional group field_id=-1 list (List) {
+    repeated int64 field_id=-1 element;
+  }
+}
+`, reader.MetaData().Schema.String())
+	require.Equal(t, []int64{1, 2}, values)
+	require.Equal(t, []int16{2, 2, 1, 0}, defLevels)
+	require.Equal(t, []int16{0, 1, 0, 0}, repLevels)
+
+	_, err = parquet.NewArrowWriterTo(io.Discard, 10, sb.Schema(), legacy)
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+}
+
+func TestWriteFlattenedObjectsParquet(t *testing.T) {
+	jsonStr := `{"a": {"b": {"c": 1, "d": {"e": true}}}}` + "\n" +
+		`{"a": {"b": {"c": 2, "d": {"e": false, "f": [1, 2]}}}}`
+
+	parquetReader, _ := testWriteJSONWith(t, jsonStr, "flatten.parquet", parquet.NewSchemaBuilder(),
+		func(r *tfJson.Reader) { r.SetFlattenNestedObjects("_", 2) }, 1000)
+	schema := `required group field_id=-1 schema {
+  required int64 field_id=-1 a_b_c;
+  required byte_array field_id=-1 a_b_d (JSON);
+}
+`
+	require.Equal(t, schema, parquetReader.MetaData().Schema.String())
+
+	col, err := parquetReader.RowGroup(0).Column(1)
+	require.NoError(t, err)
+	values := make([]pq.ByteArray, 2)
+	_, n, err := col.(*file.ByteArrayColumnChunkReader).ReadBatch(2, values, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, `{"e":true}`, string(values[0]))
+	require.Equal(t, `{"e":false,"f":[1,2]}`, string(values[1]))
+}
+
+func TestWriteOverriddenParquet(t *testing.T) {
+	jsonStr := `{"zip": 12345, "id": "1", "score": 1, "active": "true"}` + "\n" +
+		`{"zip": "01234", "id": 2, "score": "2.5", "active": false}`
+
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOverrides(parquet.Overrides{
+		"zip":    {Type: parquet.NodeTypeByteArray, LogicalType: parquet.LogicalTypeUTF8},
+		"id":     {Type: parquet.NodeTypeInt64},
+		"score":  {Type: parquet.NodeTypeFloat64},
+		"active": {Type: parquet.NodeTypeBoolean},
+	})
+	parquetReader, _ := testWriteJSONWith(t, jsonStr, "override.parquet", sb, nil, 1000)
+	require.Equal(t, int64(2), parquetReader.MetaData().NumRows)
+
+	rgr := parquetReader.RowGroup(0)
+	col, err := rgr.Column(1)
+	require.NoError(t, err)
+	ids := make([]int64, 2)
+	_, _, err = col.(*file.Int64ColumnChunkReader).ReadBatch(2, ids, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []int64{1, 2}, ids)
+
+	col, err = rgr.Column(3)
+	require.NoError(t, err)
+	zips := make([]pq.ByteArray, 2)
+	_, _, err = col.(*file.ByteArrayColumnChunkReader).ReadBatch(2, zips, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, "12345", string(zips[0]))
+	require.Equal(t, "01234", string(zips[1]))
+}
+
+func TestWriteCompressedParquet(t *testing.T) {
+	var sb strings.Builder
+	for i := range 1000 {
+		sb.WriteString(fmt.Sprintf(`{"id": %d, "text": "the same text in every row", "flag": true}`+"\n", i))
+	}
+	jsonStr := sb.String()
+
+	codec, level, err := parquet.ParseCompression("zstd:3")
+	require.NoError(t, err)
+	require.Equal(t, compress.Codecs.Zstd, codec)
+	require.Equal(t, 3, level)
+
+	schemaBuilder := parquet.NewSchemaBuilder()
+	parquetReader, wr := testWriteJSONWith(t, jsonStr, "compressed.parquet", schemaBuilder, nil, 1000,
+		parquet.WithCompression(codec, level),
+		parquet.WithColumnCompression("flag", compress.Codecs.Gzip, compress.DefaultCompressionLevel))
+	rgMd := parquetReader.MetaData().RowGroup(0)
+	expCodecs := []compress.Compression{compress.Codecs.Gzip, compress.Codecs.Zstd, compress.Codecs.Zstd}
+	for i, expCodec := range expCodecs {
+		cc, errC := rgMd.ColumnChunk(i)
+		require.NoError(t, errC)
+		require.Equal(t, expCodec, cc.Compression())
+	}
+
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), summary.Rows)
+	require.Equal(t, 1, summary.RowGroups)
+	require.Len(t, summary.Columns, 3)
+	require.Equal(t, "text", summary.Columns[2].Path)
+	require.Equal(t, compress.Codecs.Zstd, summary.Columns[2].Compression)
+	require.Equal(t, "id", summary.Columns[1].Path)
+	require.Greater(t, summary.Columns[1].Ratio(), 1.0)
+	require.Greater(t, summary.Ratio(), 1.0)
+
+	_, _, err = parquet.ParseCompression("lzo")
+	require.Error(t, err)
+
+	// a misspelled column would be written with the default properties
+	for _, opt := range []parquet.WriterOption{
+		parquet.WithColumnCompression("flags", compress.Codecs.Gzip, compress.DefaultCompressionLevel),
+		parquet.WithColumnDictionary("flags", false),
+		parquet.WithColumnEncoding("flags", pq.Encodings.Plain),
+		parquet.WithColumnStatistics("flags", false),
+		parquet.WithBloomFilter("flags", 0.01),
+	} {
+		_, err = parquet.NewWriterTo(io.Discard, 1000, schemaBuilder.Schema(), opt)
+		require.ErrorContains(t, err, "column(flags) not in the schema")
+	}
+}
+
+func testWriteJSON(t *testing.T, jsonStr string, name string, opts ...parquet.WriterOption) *file.Reader {
+	return testWriteJSONBatches(t, jsonStr, name, 1000, opts...)
+}
+
+func testWriteJSONBatches(t *testing.T, jsonStr string, name string, batchSize uint, opts ...parquet.WriterOption) *file.Reader {
+	reader, _ := testWriteJSONWith(t, jsonStr, name, parquet.NewSchemaBuilder(), nil, batchSize, opts...)
+	return reader
+}
+
+// testWriteJSONWith infers the schema of the JSON lines by the schema builder and writes them to the file with
+// the name in a temporary directory, the configure function (if not nil) is called for each JSON reader. The
+// reader of the file and the closed writer are returned.
+func testWriteJSONWith(t *testing.T, jsonStr string, name string, sb *parquet.SchemaBuilder, configure func(*tfJson.Reader),
+	batchSize uint, opts ...parquet.WriterOption,
+) (*file.Reader, *parquet.Writer) {
+	read := func(fn func(data tfJson.NDJsonRecord)) {
+		reader, err := tfJson.New(strings.NewReader(jsonStr))
+		require.NoError(t, err)
+		if configure != nil {
+			configure(reader)
+		}
+		require.NoError(t, reader.Read(context.Background(), fn))
+	}
+	read(func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+
+	path := filepath.Join(t.TempDir(), name)
+	wr, err := parquet.NewWriter(path, batchSize, sb.Schema(), opts...)
+	require.NoError(t, err)
+	read(func(data tfJson.NDJsonRecord) {
+		require.NoError(t, wr.Write(data))
+	})
+	require.NoError(t, wr.Close())
+
+	parquetReader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = parquetReader.Close()
+	})
+	return parquetReader, wr
+}
+
+func TestWriteEncodingsParquet(t *testing.T) {
+	var sb strings.Builder
+	for i := range 100 {
+		sb.WriteString(fmt.Sprintf(`{"time": "2014-04-15T18:00:%02dZ", "value": %d.5, "text": "text %d", "count": %d}`+"\n", i%60, i, i%10, i))
+	}
+	jsonStr := sb.String()
+
+	hasEncoding := func(encodings []pq.Encoding, encoding pq.Encoding) bool {
+		for _, e := range encodings {
+			if e == encoding {
+				return true
+			}
+		}
+		return false
+	}
+
+	// columns: count, text, time, value
+	parquetReader := testWriteJSON(t, jsonStr, "encodings.parquet",
+		parquet.WithColumnEncoding("text", pq.Encodings.DeltaByteArray),
+		parquet.WithColumnDictionary("count", false),
+		parquet.WithDataPageVersion(pq.DataPageV2))
+	rgMd := parquetReader.MetaData().RowGroup(0)
+	expEncodings := []pq.Encoding{pq.Encodings.Plain, pq.Encodings.DeltaByteArray, pq.Encodings.DeltaBinaryPacked, pq.Encodings.ByteStreamSplit}
+	for i, expEncoding := range expEncodings {
+		cc, err := rgMd.ColumnChunk(i)
+		require.NoError(t, err)
+		require.True(t, hasEncoding(cc.Encodings(), expEncoding), "column %v: %v", i, cc.Encodings())
+		require.False(t, cc.HasDictionaryPage())
+	}
+	pageReader, err := parquetReader.RowGroup(0).GetColumnPageReader(0)
+	require.NoError(t, err)
+	require.True(t, pageReader.Next())
+	_, ok := pageReader.Page().(*file.DataPageV2)
+	require.True(t, ok)
+
+	parquetReader = testWriteJSON(t, jsonStr, "noautoencodings.parquet", parquet.WithAutoEncoding(false))
+	rgMd = parquetReader.MetaData().RowGroup(0)
+	for i := range rgMd.NumColumns() {
+		cc, err := rgMd.ColumnChunk(i)
+		require.NoError(t, err)
+		require.True(t, cc.HasDictionaryPage())
+	}
+
+	var input bytes.Buffer
+	input.WriteString(jsonStr)
+	reader, err := tfJson.New(&input)
+	require.NoError(t, err)
+	schemaBuilder := parquet.NewSchemaBuilder()
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, schemaBuilder.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+	_, err = parquet.NewWriter(filepath.Join(t.TempDir(), "invalid.parquet"), 1000, schemaBuilder.Schema(),
+		parquet.WithColumnEncoding("text", pq.Encodings.DeltaBinaryPacked))
+	require.ErrorIs(t, err, parquet.ErrOpNotSupported)
+}
+
+func TestWriteRowGroupBytesParquet(t *testing.T) {
+	var sb strings.Builder
+	for i := range 10000 {
+		sb.WriteString(fmt.Sprintf(`{"id": %d, "text": "some longer text of row %d"}`+"\n", i, i))
+	}
+	jsonStr := sb.String()
+
+	// each batch is a row group without the target size
+	reader := testWriteJSONBatches(t, jsonStr, "test_row_group_bytes.parquet", 100)
+	require.Equal(t, 100, reader.NumRowGroups())
+
+	const target = 32 << 10
+	reader = testWriteJSONBatches(t, jsonStr, "test_row_group_bytes.parquet", 100,
+		parquet.WithRowGroupBytes(target), parquet.WithDictionary(false))
+	require.EqualValues(t, 10000, reader.NumRows())
+	require.Greater(t, reader.NumRowGroups(), 1)
+	require.Less(t, reader.NumRowGroups(), 100)
+	for i := range reader.NumRowGroups() - 1 {
+		size := reader.MetaData().RowGroup(i).TotalByteSize()
+		require.GreaterOrEqual(t, size, int64(target), "row group %v", i)
+		// a row group exceeds the target by at most one batch
+		require.Less(t, size, int64(2*target), "row group %v", i)
+	}
+}
+
+func TestWriteStatisticsPageIndexBloomFilterParquet(t *testing.T) {
+	var sb strings.Builder
+	for i := range 1000 {
+		sb.WriteString(fmt.Sprintf(`{"id": %d, "user": "user-%d", "text": "%v %d", "note": "note %d"}`+"\n",
+			i, i, strings.Repeat("long text ", 20), i, i))
+	}
+	reader := testWriteJSON(t, sb.String(), "test_statistics.parquet",
+		parquet.WithMaxStatisticsSize(64),
+		parquet.WithColumnStatistics("note", false),
+		parquet.WithPageIndex(true),
+		parquet.WithBloomFilter("user", 0.01))
+	require.Equal(t, 1, reader.NumRowGroups())
+	sc := reader.MetaData().Schema
+	id, user, text, note := sc.ColumnIndexByName("id"), sc.ColumnIndexByName("user"),
+		sc.ColumnIndexByName("text"), sc.ColumnIndexByName("note")
+
+	rg := reader.MetaData().RowGroup(0)
+	statistics := func(col int) metadata.TypedStatistics {
+		cc, err := rg.ColumnChunk(col)
+		require.NoError(t, err)
+		set, err := cc.StatsSet()
+		require.NoError(t, err)
+		if !set {
+			return nil
+		}
+		stats, err := cc.Statistics()
+		require.NoError(t, err)
+		return stats
+	}
+	idStats := statistics(id).(*metadata.Int64Statistics)
+	require.True(t, idStats.HasMinMax())
+	require.EqualValues(t, 0, idStats.Min())
+	require.EqualValues(t, 999, idStats.Max())
+	// values longer than the limit are truncated, the max is incremented to bound the values
+	textStats := statistics(text).(*metadata.ByteArrayStatistics)
+	require.True(t, textStats.HasMinMax())
+	prefix := strings.Repeat("long text ", 20)[:63]
+	require.Equal(t, prefix+"g", string(textStats.Min()))
+	require.Equal(t, prefix+"h", string(textStats.Max()))
+	thriftStats := reader.MetaData().RowGroups[0].Columns[text].MetaData.Statistics
+	require.False(t, thriftStats.GetIsMinValueExact())
+	require.False(t, thriftStats.GetIsMaxValueExact())
+	require.True(t, statistics(user).HasMinMax())
+	require.Nil(t, reader.MetaData().RowGroups[0].Columns[user].MetaData.Statistics.IsMaxValueExact)
+	require.Nil(t, statistics(note))
+
+	rgIndex, err := reader.GetPageIndexReader().RowGroup(0)
+	require.NoError(t, err)
+	colIndex, err := rgIndex.GetColumnIndex(id)
+	require.NoError(t, err)
+	require.NotNil(t, colIndex)
+	require.EqualValues(t, 0, colIndex.(*metadata.TypedColumnIndex[int64]).MinValues()[0])
+	offsetIndex, err := rgIndex.GetOffsetIndex(id)
+	require.NoError(t, err)
+	require.NotEmpty(t, offsetIndex.GetPageLocations())
+
+	rgFilters, err := reader.GetBloomFilterReader().RowGroup(0)
+	require.NoError(t, err)
+	filter, err := rgFilters.GetColumnBloomFilter(user)
+	require.NoError(t, err)
+	require.NotNil(t, filter)
+	userFilter := metadata.TypedBloomFilter[pq.ByteArray]{BloomFilter: filter}
+	for i := range 1000 {
+		require.True(t, userFilter.Check(pq.ByteArray(fmt.Sprintf("user-%d", i))))
+	}
+	falsePositives := 0
+	for i := range 1000 {
+		if userFilter.Check(pq.ByteArray(fmt.Sprintf("other-%d", i))) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 50)
+	filter, err = rgFilters.GetColumnBloomFilter(id)
+	require.NoError(t, err)
+	require.Nil(t, filter)
+}
+
+func TestWriteKeyValueMetadataParquet(t *testing.T) {
+	jsonStr := `{"id": 1, "time": "2014-04-15T18:00:00Z", "zip": 12345}
+{"id": 2, "time": "2014-04-15T18:00:01Z", "zip": 23456}`
+	path := filepath.Join(t.TempDir(), "key_value_metadata.parquet")
+	reader, err := tfJson.New(strings.NewReader(jsonStr))
+	require.NoError(t, err)
+	sb := parquet.NewSchemaBuilder()
+	sb.SetOverrides(parquet.Overrides{"zip": {Type: parquet.NodeTypeByteArray, LogicalType: parquet.LogicalTypeUTF8}})
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+
+	wr, err := parquet.NewWriter(path, 1000, sb.Schema(), parquet.WithKeyValueMetadata("owner", "data team"))
+	require.NoError(t, err)
+	reader, err = tfJson.New(strings.NewReader(jsonStr))
+	require.NoError(t, err)
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, wr.Write(data))
+	})
+	require.NoError(t, err)
+	require.NoError(t, wr.AppendKeyValueMetadata("rows", "2"))
+	require.NoError(t, wr.Close())
+	require.Error(t, wr.AppendKeyValueMetadata("late", "entry"))
+
+	parquetReader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer parquetReader.Close()
+	kv := parquetReader.MetaData().KeyValueMetadata()
+	require.Equal(t, "data team", *kv.FindValue("owner"))
+	require.Equal(t, "2", *kv.FindValue("rows"))
+	require.Nil(t, kv.FindValue("late"))
+
+	fields, err := parquet.SchemaDescription(kv)
+	require.NoError(t, err)
+	require.Equal(t, []parquet.FieldDescription{
+		{Name: "id", Type: "INT64", Repetition: "required"},
+		{Name: "time", Type: "BYTE_ARRAY", ExtendedType: "RFC3339", Repetition: "required"},
+		{Name: "zip", Type: "BYTE_ARRAY", LogicalType: "String", Repetition: "required", Overridden: true},
+	}, fields)
+}
+
+func TestWriteToBufferParquet(t *testing.T) {
+	jsonStr := `{"id": 1, "name": "first"}
+{"id": 2, "name": "second"}`
+	reader, err := tfJson.New(strings.NewReader(jsonStr))
+	require.NoError(t, err)
+	sb := parquet.NewSchemaBuilder()
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, sb.UpdateSchema(data))
+	})
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	wr, err := parquet.NewWriterTo(&out, 1000, sb.Schema())
+	require.NoError(t, err)
+	reader, err = tfJson.New(strings.NewReader(jsonStr))
+	require.NoError(t, err)
+	err = reader.Read(context.Background(), func(data tfJson.NDJsonRecord) {
+		require.NoError(t, wr.Write(data))
+	})
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+
+	parquetReader, err := file.NewParquetReader(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+	defer parquetReader.Close()
+	require.EqualValues(t, 2, parquetReader.NumRows())
+	summary, err := wr.Summary()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, summary.Rows)
+}
+
+func TestWriteAtomicParquet(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "atomic.parquet")
+	require.NoError(t, os.WriteFile(path, []byte("previous"), 0o600))
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("1")}))
+	sc := sb.Schema()
+
+	requireUnchanged := func() {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "previous", string(data))
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	}
+
+	// missing required column fails when the row is written
+	wr, err := parquet.NewWriter(path, 1000, sc)
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1")}))
+	require.ErrorContains(t, wr.Write(map[string]interface{}{"other": json.Number("1")}), "missing required column")
+	wr.Abort()
+	require.Error(t, wr.Close())
+	requireUnchanged()
+
+	wr, err = parquet.NewWriter(path, 1000, sc)
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1")}))
+	wr.Abort()
+	requireUnchanged()
+
+	wr, err = parquet.NewWriter(path, 1000, sc)
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number("1")}))
+	require.NoError(t, wr.Close())
+	reader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.EqualValues(t, 1, reader.NumRows())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
+
+func TestWriteInvalidRowParquet(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{
+		"a":    json.Number("1"),
+		"list": []interface{}{json.Number("1")},
+		"z":    json.Number("1"),
+	}))
+	var buf bytes.Buffer
+	wr, err := parquet.NewWriterTo(&buf, 2, sb.Schema())
+	require.NoError(t, err)
+	require.NoError(t, wr.Write(map[string]interface{}{"a": json.Number("1"), "list": []interface{}{json.Number("1")}, "z": json.Number("1")}))
+	// the values of the columns converted before the invalid value are removed
+	require.ErrorContains(t, wr.Write(map[string]interface{}{
+		"a": json.Number("2"), "list": []interface{}{json.Number("2"), "x"}, "z": json.Number("2"),
+	}), "cannot convert")
+	require.ErrorContains(t, wr.Write(map[string]interface{}{
+		"a": json.Number("2"), "list": []interface{}{json.Number("2")}, "z": "x",
+	}), "cannot convert")
+	require.NoError(t, wr.Write(map[string]interface{}{"a": json.Number("3"), "list": []interface{}{json.Number("3"), json.Number("4")}, "z": json.Number("3")}))
+	require.NoError(t, wr.Close())
+
+	reader, err := file.NewParquetReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer reader.Close()
+	require.EqualValues(t, 2, reader.NumRows())
+	rgr := reader.RowGroup(0)
+	for i, exp := range [][]int64{{1, 3}, {1, 3, 4}, {1, 3}} {
+		col, err := rgr.Column(i)
+		require.NoError(t, err)
+		values := make([]int64, 4)
+		_, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(4, values, make([]int16, 4), make([]int16, 4))
+		require.NoError(t, err)
+		require.Equal(t, exp, values[:n], "column %v", i)
+	}
+}
+
+// failingWriter fails the writes after the limit of bytes
+type failingWriter struct {
+	limit int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > fw.limit {
+		return 0, errWriteFailed
+	}
+	fw.limit -= len(p)
+	return len(p), nil
+}
+
+func TestWriteFailedBatchParquet(t *testing.T) {
+	sb := parquet.NewSchemaBuilder()
+	require.NoError(t, sb.UpdateSchema(map[string]interface{}{"id": json.Number("1"), "text": "a"}))
+	sc := sb.Schema()
+
+	// the invalid rows are rejected before the batches of the buffered row group are written
+	path := filepath.Join(t.TempDir(), "failed.parquet")
+	wr, err := parquet.NewWriter(path, 2, sc, parquet.WithRowGroupBytes(1<<20))
+	require.NoError(t, err)
+	for i := range 10 {
+		require.NoError(t, wr.Write(map[string]interface{}{"id": json.Number(strconv.Itoa(i)), "text": "valid"}))
+		require.ErrorContains(t, wr.Write(map[string]interface{}{"id": json.Number("-1"), "text": true}), "cannot convert")
+	}
+	require.NoError(t, wr.Close())
+	reader, err := file.OpenParquetFile(path, false)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.Equal(t, 1, reader.NumRowGroups())
+	col, err := reader.RowGroup(0).Column(0)
+	require.NoError(t, err)
+	values := make([]int64, 20)
+	_, n, err := col.(*file.Int64ColumnChunkReader).ReadBatch(20, values, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, values[:n])
+
+	// a batch that fails to be written fails the writer
+	wr, err = parquet.NewWriterTo(&failingWriter{limit: 1 << 20}, 100, sc, parquet.WithDictionary(false))
+	require.NoError(t, err)
+	for i := 0; ; i++ {
+		err = wr.Write(map[string]interface{}{
+			"id": json.Number(strconv.Itoa(i)), "text": fmt.Sprintf("%v %d", strings.Repeat("text ", 20), i),
+		})
+		if err != nil {
+			break
+		}
+	}
+	require.ErrorIs(t, err, errWriteFailed)
+	require.ErrorIs(t, wr.Write(map[string]interface{}{"id": json.Number("1"), "text": "a"}), errWriteFailed)
+	require.ErrorIs(t, wr.WriteBatch(), errWriteFailed)
+	require.ErrorIs(t, wr.Close(), errWriteFailed)
+	_, err = wr.Summary()
+	require.ErrorIs(t, err, parquet.ErrNoSummary)
+}
+
+func TestWriteColumnWorkersParquet(t *testing.T) {
+	records := benchmarkRecords(5000, true)
+	sb := parquet.NewSchemaBuilder()
+	for _, record := range records[:10] {
+		require.NoError(t, sb.UpdateSchema(record))
+	}
+	write := func(opts ...parquet.WriterOption) []byte {
+		var buf bytes.Buffer
+		opts = append(opts, parquet.WithCompression(compress.Codecs.Zstd, compress.DefaultCompressionLevel),
+			parquet.WithRowGroupBytes(64<<10), parquet.WithPageIndex(true), parquet.WithBloomFilter("name", 0.01))
+		wr, err := parquet.NewWriterTo(&buf, 100, sb.Schema(), opts...)
+		require.NoError(t, err)
+		for _, record := range records {
+			require.NoError(t, wr.Write(record))
+		}
+		require.NoError(t, wr.Close())
+		return buf.Bytes()
+	}
+	serial := write()
+	reader, err := file.NewParquetReader(bytes.NewReader(serial))
+	require.NoError(t, err)
+	require.EqualValues(t, 5000, reader.NumRows())
+	require.Greater(t, reader.NumRowGroups(), 1)
+	require.NoError(t, reader.Close())
+	// the output does not depend on the number of workers
+	require.True(t, bytes.Equal(serial, write(parquet.WithColumnWorkers(4))))
+	require.True(t, bytes.Equal(serial, write(parquet.WithColumnWorkers(64))))
+}
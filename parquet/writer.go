@@ -1,291 +1,460 @@
 package parquet
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"io"
+	"sync"
 
-	"github.com/apache/arrow-go/v18/parquet"
 	"github.com/apache/arrow-go/v18/parquet/file"
-	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
 	"github.com/thermofisher/json2parquet/log"
 )
 
 type Writer struct {
 	writer *file.Writer
+	output *output
 
-	schema    *Schema
-	batch     []map[string]interface{}
-	batchSize uint
+	schema     *Schema
+	columns    []columnBuffer // buffers of the leaf columns in the order of the parquet schema
+	rows       uint           // number of rows in the column buffers
+	batchSize  uint
+	coercion   Coercion // rules of the values that do not match the type of their column
+	timestamps TimestampType
+
+	rowGroup      file.BufferedRowGroupWriter
+	rowGroupBytes int64
+	rowGroupSize  int64 // estimated size of the row group after the last batch
+	columnWorkers int
+
+	maxStatisticsSize int // length of the truncated min/max values, see WithMaxStatisticsSize
+
+	// approximate memory size of the buffered data, see WithMemoryLimit
+	memoryLimit int64
+	peakBytes   int64
+
+	// rows of the next sorted row group, see WithSortedRowGroups
+	sortKeys    []sortKey
+	sortRows    int
+	sorted      []map[string]interface{}
+	sortedBytes int64 // approximate memory size of the sorted rows not yet written
+
+	summary  *Summary
+	failed   error // error that left the written data incomplete, see fail
+	closed   bool
+	closeErr error
 }
 
-func NewWriter(path string, batchSize uint, sc *Schema) (*Writer, error) {
-	out, err := os.Create(path)
+// NewWriter creates a writer of the parquet file. The data is written to a temporary file in the same
+// directory which is renamed to path when the writer is closed successfully, so the file at path is
+// either complete or not changed at all.
+func NewWriter(path string, batchSize uint, sc *Schema, opts ...WriterOption) (*Writer, error) {
+	out, err := createOutput(path)
 	if err != nil {
 		return nil, err
 	}
-	pqSc, err := sc.Schema()
+	w, err := newWriter(out, batchSize, sc, opts)
 	if err != nil {
+		out.remove()
 		return nil, err
 	}
-	return &Writer{
-		writer:    file.NewParquetWriter(out, pqSc.Root()),
-		schema:    sc,
-		batchSize: batchSize,
-	}, nil
+	return w, nil
 }
 
-func (w *Writer) Close() {
-	if len(w.batch) > 0 {
-		if err := w.WriteBatch(); err != nil {
-			log.Logger().Errorf("failed to write last batch data: %v", err)
+// NewWriterTo creates a writer of parquet data to the output (e.g. stdout or an in-memory buffer),
+// the output is buffered and flushed when the writer is closed, but it is not closed by the writer
+func NewWriterTo(out io.Writer, batchSize uint, sc *Schema, opts ...WriterOption) (*Writer, error) {
+	return newWriter(newOutput(out), batchSize, sc, opts)
+}
+
+func newWriter(out *output, batchSize uint, sc *Schema, opts []WriterOption) (*Writer, error) {
+	config := newWriterConfig(opts)
+	pqSc, err := sc.SchemaWithLayout(config.layout())
+	if err != nil {
+		return nil, err
+	}
+	kv, err := schemaMetadata(sc, config.metadata)
+	if err != nil {
+		return nil, err
+	}
+	props, err := config.properties(sc, pqSc)
+	if err != nil {
+		return nil, err
+	}
+	var sortKeys []sortKey
+	if config.sortRowGroups > 0 {
+		if sortKeys, err = newSortKeys(sc, config.sortColumns); err != nil {
+			return nil, err
 		}
 	}
-	if err := w.writer.FlushWithFooter(); err != nil {
-		log.Logger().Errorf("failed to flush parquet writer: %v", err)
+	if config.encryption != nil {
+		out.kms = config.encryption.KMS
 	}
-	if err := w.writer.Close(); err != nil {
-		log.Logger().Errorf("failed to close parquet writer: %v", err)
+	w := &Writer{
+		output:        out,
+		schema:        sc,
+		batchSize:     batchSize,
+		coercion:      config.coercion,
+		timestamps:    config.timestamps,
+		rowGroupBytes: config.rowGroupBytes,
+		columnWorkers: config.columnWorkers,
+		memoryLimit:   config.memoryLimit,
+
+		maxStatisticsSize: int(config.maxStatisticsSize),
+		sortKeys:          sortKeys,
+		sortRows:          config.sortRowGroups,
 	}
+	if w.columns, err = w.newColumnBuffers(pqSc); err != nil {
+		return nil, err
+	}
+	w.writer = file.NewParquetWriter(out.sink, pqSc.Root(), file.WithWriteMetadata(kv), file.WithWriterProps(props))
+	return w, nil
 }
 
-// Define a type for conversion functions
-type ValueConverter[T any] func(interface{}) (T, bool)
-
-func writeSingleColumn[T any](w *Writer, cw file.ColumnChunkWriter, convert ValueConverter[T]) ([]T, []int16, []int16, error) {
-	var values []T
-	defLevels := make([]int16, len(w.batch))
-	var repLevels []int16
-	name := cw.Descr().SchemaNode().Name()
-	for i, row := range w.batch {
-		if value, ok := row[name]; ok {
-			v, ok := convert(value)
-			if !ok {
-				return nil, nil, nil, fmt.Errorf("cannot convert(%T) to %T", value, *new(T))
+func schemaMetadata(sc *Schema, entries []keyValue) (metadata.KeyValueMetadata, error) {
+	kv := metadata.NewKeyValueMetadata()
+	// the schema is not known when the Arrow records are written by NewArrowRecordWriter
+	if sc != nil {
+		description, err := json.Marshal(sc.Describe())
+		if err != nil {
+			return nil, err
+		}
+		if err = kv.Append(SchemaMetadataKey, string(description)); err != nil {
+			return nil, err
+		}
+		if names := sc.OriginalNames(); len(names) > 0 {
+			data, err := json.Marshal(names)
+			if err != nil {
+				return nil, err
+			}
+			if err = kv.Append(ColumnNamesMetadataKey, string(data)); err != nil {
+				return nil, err
 			}
-			defLevels[i] = cw.LevelInfo().DefLevel
-			values = append(values, v)
 		}
 	}
-	return values, defLevels, repLevels, nil
+	for _, e := range entries {
+		if err := kv.Append(e.key, e.value); err != nil {
+			return nil, err
+		}
+	}
+	return kv, nil
 }
 
-func (w *Writer) writeBoolColumn(cw *file.BooleanColumnChunkWriter) error {
-	values, defLevels, repLevels, err := writeSingleColumn[bool](w, cw, tfJson.ToBool)
-	if err != nil {
-		return err
+// AppendKeyValueMetadata adds an entry to the footer metadata of the file, it must be called
+// before the writer is closed
+func (w *Writer) AppendKeyValueMetadata(key, value string) error {
+	if w.closed {
+		return errors.New("writer is closed")
 	}
-	_, err = cw.WriteBatch(values, defLevels, repLevels)
-	return err
+	if w.failed != nil {
+		return w.failedError()
+	}
+	return w.writer.AppendKeyValueMetadata(key, value)
 }
 
-func writeArrayColumn[T any](row map[string]interface{}, cw file.ColumnChunkWriter, convert ValueConverter[T]) ([]T, []int16, []int16, error) {
-	parent := cw.Descr().SchemaNode().Parent()
-	if parent.RepetitionType() == parquet.Repetitions.Repeated {
-		return nil, nil, nil, errors.New("nested elements are not supported")
-	}
-	rep := cw.Descr().SchemaNode().RepetitionType()
-	defLevel := cw.LevelInfo().DefLevel
-	name := parent.Name()
-	value, ok := row[name]
-	if !ok {
-		if rep == parquet.Repetitions.Required {
-			return nil, nil, nil, fmt.Errorf("missing required column(%v)", name)
-		}
-		// missing at current level
-		curDefLevel := defLevel - 1
-		if rep == parquet.Repetitions.Repeated {
-			// missing at the parent level
-			curDefLevel = defLevel - 2
-		}
-		return nil, []int16{curDefLevel}, []int16{0}, nil
+// Close writes the buffered data and the footer. The temporary file of NewWriter is renamed to the
+// path on success and removed on failure. A writer that failed to write a batch discards the data
+// like Abort and returns the error. Subsequent calls return the result of the first call.
+func (w *Writer) Close() error {
+	if w.closed {
+		return w.closeErr
 	}
-	values, ok := value.([]interface{})
-	if !ok {
-		return nil, nil, nil, fmt.Errorf("unexpected type(%T)", value)
+	w.closed = true
+	if w.failed != nil {
+		w.closeErr = w.failedError()
+		w.output.remove()
+		return w.closeErr
 	}
-	if len(values) == 0 {
-		// missing at current level
-		return nil, []int16{defLevel - 1}, []int16{0}, nil
+	w.closeErr = w.output.commit(w.finish())
+	if w.closeErr != nil {
+		return w.closeErr
 	}
-
-	array := make([]T, len(values))
-	repLevel := cw.LevelInfo().RepLevel
-	defLevels := make([]int16, len(values))
-	repLevels := make([]int16, len(values))
-	for i, v := range values {
-		array[i], ok = convert(v)
-		if !ok {
-			return nil, nil, nil, fmt.Errorf("cannot convert(%T) to %T", v, array[0])
-		}
-		// non-NULL so take the max defLevel
-		defLevels[i] = cw.LevelInfo().DefLevel
-		// same array, so take the max repLevel
-		repLevels[i] = repLevel
-		if i == 0 {
-			// new entry start
-			repLevels[i] = 0
+	w.summary = w.output.summary(w.writer.FileMetadata())
+	if w.summary != nil {
+		for i, c := range w.columns {
+			w.summary.Columns[i].CoercedNulls = c.coercedNulls()
 		}
+		w.summary.PeakBufferedBytes = w.peakBytes
 	}
-	return array, defLevels, repLevels, nil
+	return nil
 }
 
-func (w *Writer) writeBoolArrayColumn(cw *file.BooleanColumnChunkWriter) error {
-	for _, row := range w.batch {
-		boolArray, defLevels, repLevels, err := writeArrayColumn[bool](row, cw, tfJson.ToBool)
-		if err != nil {
-			return err
+// Abort discards the written data without writing the footer, the temporary file of NewWriter is
+// removed and the file at the path is not changed. It has no effect on a closed writer.
+func (w *Writer) Abort() {
+	if w.closed {
+		return
+	}
+	w.closed = true
+	w.closeErr = errors.New("writer is aborted")
+	w.output.remove()
+}
+
+// finish writes the remaining data and the footer and closes the output
+func (w *Writer) finish() error {
+	var errs []error
+	if len(w.sorted) > 0 {
+		if err := w.writeSortedRowGroup(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write last sorted row group: %w", err))
 		}
-		_, err = cw.WriteBatch(boolArray, defLevels, repLevels)
+	}
+	if w.rows > 0 {
+		if err := w.WriteBatch(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write last batch data: %w", err))
+		}
+	}
+	if err := w.flushRowGroup(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write last row group: %w", err))
+	}
+	if w.failed != nil {
+		// the footer of an incomplete row group is not written
+		w.output.remove()
+		return errors.Join(errs...)
+	}
+	if w.maxStatisticsSize > 0 {
+		md, err := w.writer.FileMetadata()
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("failed to truncate statistics: %w", err))
+		} else {
+			truncateStatistics(md, w.maxStatisticsSize)
 		}
 	}
-	return nil
+	if err := w.writer.FlushWithFooter(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to flush parquet writer: %w", err))
+	}
+	if err := w.writer.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close parquet writer: %w", err))
+	}
+	if err := w.output.close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
 }
 
-func (w *Writer) writeInt64Column(cw *file.Int64ColumnChunkWriter) error {
-	convert := tfJson.ToInt64
-	field := w.schema.FieldByPath(cw.Descr().ColumnPath())
-	if field.GetType() == NodeTypeByteArray && field.GetExtendedType() == ExtendedTypeRFC3339 {
-		convert = tfJson.ToRFC3339ToTimestampNano
-	}
-	values, defLevels, repLevels, err := writeSingleColumn[int64](w, cw, convert)
-	if err != nil {
-		return err
+// Summary returns the description of the written file, it is available after the writer is closed. The
+// summary of data encrypted with column keys (see Encryption) is not available when it is written to an io.Writer.
+func (w *Writer) Summary() (*Summary, error) {
+	if w.summary == nil {
+		return nil, ErrNoSummary
 	}
-	_, err = cw.WriteBatch(values, defLevels, repLevels)
-	return err
+	return w.summary, nil
 }
 
-func (w *Writer) writeInt64ArrayColumn(cw *file.Int64ColumnChunkWriter) error {
-	convert := tfJson.ToInt64
-	field := w.schema.FieldByPath(cw.Descr().ColumnPath())
-	if field.GetType() == NodeTypeByteArray && field.GetExtendedType() == ExtendedTypeRFC3339 {
-		convert = tfJson.ToRFC3339ToTimestampNano
+// Write converts the values of the row into the column buffers, the buffered rows are written when
+// the batch is full. A row that cannot be converted is not written.
+func (w *Writer) Write(data map[string]interface{}) error {
+	if w.failed != nil {
+		return w.failedError()
 	}
-	for _, row := range w.batch {
-		int64Array, defLevels, repLevels, err := writeArrayColumn[int64](row, cw, convert)
-		if err != nil {
+	if w.sortRows > 0 {
+		w.sorted = append(w.sorted, data)
+		w.sortedBytes += recordSize(data)
+		if len(w.sorted) < w.sortRows && !w.overMemoryLimit() {
+			return nil
+		}
+		return w.writeSortedRowGroup()
+	}
+	return w.writeRow(data)
+}
+
+func (w *Writer) writeRow(data map[string]interface{}) error {
+	if w.rows >= w.batchSize {
+		if err := w.WriteBatch(); err != nil {
 			return err
 		}
-		_, err = cw.WriteBatch(int64Array, defLevels, repLevels)
-		if err != nil {
+	}
+	for i, c := range w.columns {
+		if err := c.append(data); err != nil {
+			for _, prev := range w.columns[:i] {
+				prev.undo()
+			}
 			return err
 		}
 	}
-	return nil
-}
-
-func (w *Writer) writeFloat64Column(cw *file.Float64ColumnChunkWriter) error {
-	values, defLevels, repLevels, err := writeSingleColumn[float64](w, cw, tfJson.ToFloat64)
-	if err != nil {
+	w.rows++
+	if !w.overMemoryLimit() {
+		return nil
+	}
+	log.Logger().Debugf("memory limit of %v bytes reached, writing the row group early", w.memoryLimit)
+	if err := w.WriteBatch(); err != nil {
 		return err
 	}
-	_, err = cw.WriteBatch(values, defLevels, repLevels)
-	return err
+	return w.flushRowGroup()
 }
 
-func (w *Writer) writeFloat64ArrayColumn(cw *file.Float64ColumnChunkWriter) error {
-	for _, row := range w.batch {
-		f64Array, defLevels, repLevels, err := writeArrayColumn[float64](row, cw, tfJson.ToFloat64)
-		if err != nil {
-			return err
+// writeSortedRowGroup sorts the collected rows and writes them as a row group, the row group is split
+// when the memory limit is reached. A row that cannot be written fails the writer, see Close.
+func (w *Writer) writeSortedRowGroup() error {
+	sortRecords(w.sortKeys, w.sorted)
+	rows := w.sorted
+	w.sorted = nil
+	for i, data := range rows {
+		// the memory of the written rows can be reclaimed
+		rows[i] = nil
+		w.sortedBytes -= recordSize(data)
+		if err := w.writeRow(data); err != nil {
+			// the rows were accepted by Write, so the row group without the rest of them is incomplete
+			w.sortedBytes = 0
+			return w.fail(err)
 		}
-		_, err = cw.WriteBatch(f64Array, defLevels, repLevels)
-		if err != nil {
+	}
+	w.sortedBytes = 0
+	if w.rows > 0 {
+		if err := w.WriteBatch(); err != nil {
 			return err
 		}
 	}
-	return nil
+	return w.flushRowGroup()
 }
 
-func toByteArray(v interface{}) (parquet.ByteArray, bool) {
-	s, ok := tfJson.ToString(v)
-	if !ok {
-		return nil, ok
+// bufferedBytes returns the approximate memory size of the buffered data: the rows of the next batch
+// and of the next sorted row group and the row group not yet flushed
+func (w *Writer) bufferedBytes() int64 {
+	size := w.rowGroupSize + w.sortedBytes
+	for _, c := range w.columns {
+		size += c.bufferedBytes()
 	}
-	return parquet.ByteArray(s), true
+	return size
 }
 
-func (w *Writer) writeByteArrayColumn(cw *file.ByteArrayColumnChunkWriter) error {
-	values, defLevels, repLevels, err := writeSingleColumn[parquet.ByteArray](w, cw, toByteArray)
-	if err != nil {
-		return err
+// overMemoryLimit returns true when the buffered data reached the memory limit
+func (w *Writer) overMemoryLimit() bool {
+	if w.memoryLimit <= 0 {
+		return false
 	}
-	_, err = cw.WriteBatch(values, defLevels, repLevels)
-	return err
+	size := w.bufferedBytes()
+	w.peakBytes = max(w.peakBytes, size)
+	return size >= w.memoryLimit
 }
 
-func (w *Writer) writeByteArrayArrayColumn(cw *file.ByteArrayColumnChunkWriter) error {
-	for _, row := range w.batch {
-		baArray, defLevels, repLevels, err := writeArrayColumn[parquet.ByteArray](row, cw, toByteArray)
-		if err != nil {
-			return err
-		}
-		_, err = cw.WriteBatch(baArray, defLevels, repLevels)
-		if err != nil {
-			return err
-		}
+// EstimatedSize returns the size of the data written so far including the row group not yet
+// flushed, the rows buffered for the next batch (or the next sorted row group) are not included
+func (w *Writer) EstimatedSize() int64 {
+	size := w.output.sink.written
+	if w.rowGroup != nil {
+		size += w.estimatedRowGroupSize()
 	}
-	return nil
+	return size
 }
 
-func (w *Writer) writeColumn(cw file.ColumnChunkWriter) error {
-	isArray := cw.Descr().SchemaNode().RepetitionType() == parquet.Repetitions.Repeated
-	if bwr, ok := cw.(*file.BooleanColumnChunkWriter); ok {
-		if isArray {
-			return w.writeBoolArrayColumn(bwr)
-		}
-		return w.writeBoolColumn(bwr)
+// WriteBatch writes the buffered rows to the current row group with a single WriteBatch of each column,
+// the row group is flushed when its estimated size reaches the target size (see WithRowGroupBytes).
+// The rows are converted when they are written, so an error of WriteBatch (e.g. of the output) leaves
+// the row group incomplete and the writer failed, see Close.
+func (w *Writer) WriteBatch() error {
+	if w.failed != nil {
+		return w.failedError()
 	}
-	if int64wr, ok := cw.(*file.Int64ColumnChunkWriter); ok {
-		if isArray {
-			return w.writeInt64ArrayColumn(int64wr)
-		}
-		return w.writeInt64Column(int64wr)
+	if w.rowGroup == nil {
+		w.rowGroup = w.writer.AppendBufferedRowGroup()
 	}
-	if f64wr, ok := cw.(*file.Float64ColumnChunkWriter); ok {
-		if isArray {
-			return w.writeFloat64ArrayColumn(f64wr)
+	// the buffered data grows until the batch is written
+	w.peakBytes = max(w.peakBytes, w.bufferedBytes())
+	log.Logger().Debugf("writing %v rows of json data", w.rows)
+	w.rows = 0
+	if err := w.writeColumns(); err != nil {
+		for _, c := range w.columns {
+			c.reset()
 		}
-		return w.writeFloat64Column(f64wr)
+		// the columns of the row group were written partially, it cannot be flushed
+		w.rowGroup = nil
+		w.rowGroupSize = 0
+		return w.fail(err)
 	}
-	if bawr, ok := cw.(*file.ByteArrayColumnChunkWriter); ok {
-		if isArray {
-			return w.writeByteArrayArrayColumn(bawr)
-		}
-		return w.writeByteArrayColumn(bawr)
+	size := w.estimatedRowGroupSize()
+	w.rowGroupSize = size
+	log.Logger().Debugf("buffered row group size %v bytes", size)
+	if w.rowGroupBytes > 0 && size < w.rowGroupBytes {
+		return nil
 	}
-	return errors.New("invalid write")
+	return w.flushRowGroup()
 }
 
-func (w *Writer) Write(data map[string]interface{}) error {
-	if uint(len(w.batch)) >= w.batchSize {
-		if err := w.WriteBatch(); err != nil {
+// writeColumns passes the column buffers to the column writers of the row group, the columns are
+// encoded and compressed in parallel by at most the number of column workers. The columns of a
+// buffered row group are independent and written to the output in order when the row group is
+// flushed, so the output does not depend on the number of workers.
+func (w *Writer) writeColumns() error {
+	numColumns := w.rowGroup.NumColumns()
+	if w.columnWorkers <= 1 || numColumns <= 1 {
+		for i := range numColumns {
+			if err := w.writeColumn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	errs := make([]error, numColumns)
+	sem := make(chan struct{}, w.columnWorkers)
+	var wg sync.WaitGroup
+	for i := range numColumns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = w.writeColumn(i)
+		}()
+	}
+	wg.Wait()
+	// the error of the first column like in the serial path
+	for _, err := range errs {
+		if err != nil {
 			return err
 		}
 	}
-	w.batch = append(w.batch, data)
 	return nil
 }
 
-func (w *Writer) WriteBatch() error {
-	rgw := w.writer.AppendRowGroup()
-	log.Logger().Debugf("writing %v rows of json data", len(w.batch))
-	for range rgw.NumColumns() {
-		cw, err := rgw.NextColumn()
+func (w *Writer) writeColumn(i int) error {
+	cw, err := w.rowGroup.Column(i)
+	if err != nil {
+		return err
+	}
+	return w.columns[i].write(cw)
+}
+
+// estimatedRowGroupSize returns the encoded size of the current row group, including the pages
+// and dictionaries not yet flushed by the column writers
+func (w *Writer) estimatedRowGroupSize() int64 {
+	size := w.rowGroup.TotalBytesWritten()
+	for i := range w.rowGroup.NumColumns() {
+		cw, err := w.rowGroup.Column(i)
 		if err != nil {
-			rgw.Close()
-			return err
+			continue
 		}
-		err = w.writeColumn(cw)
-		if err != nil {
-			rgw.Close()
-			return err
+		enc := cw.CurrentEncoder()
+		size += enc.EstimatedDataEncodedSize()
+		if dict, ok := enc.(interface{ DictEncodedSize() int }); ok {
+			size += int64(dict.DictEncodedSize())
 		}
 	}
-	log.Logger().Debugf("written row group size %v bytes", rgw.TotalBytesWritten())
-	w.batch = nil
-	return rgw.Close()
+	return size
+}
+
+// flushRowGroup writes the current row group to the output
+func (w *Writer) flushRowGroup() error {
+	if w.rowGroup == nil {
+		return nil
+	}
+	rg := w.rowGroup
+	w.rowGroup = nil
+	w.rowGroupSize = 0
+	if err := rg.Close(); err != nil {
+		return w.fail(err)
+	}
+	log.Logger().Debugf("written row group size %v bytes", rg.TotalBytesWritten())
+	return nil
+}
+
+// fail marks the writer failed by the error, the written data is incomplete and it is discarded
+// when the writer is closed
+func (w *Writer) fail(err error) error {
+	w.failed = err
+	return err
+}
+
+func (w *Writer) failedError() error {
+	return fmt.Errorf("writer failed: %w", w.failed)
 }
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	tfJson "github.com/thermofisher/json2parquet/json"
+	"github.com/thermofisher/json2parquet/parquet"
+)
+
+func runValidate(fs *flag.FlagSet, args []string) int {
+	var verbose bool
+	var schemaFile string
+	var coerce string
+	var maxErrors int
+	var inference inferenceFlags
+	var layoutOpts layoutFlags
+	var encryption encryptionFlags
+	fs.BoolVar(&verbose, "v", false, "Enable verbose mode")
+	fs.StringVar(&schemaFile, "schema", "", "Validate the records by the schema of a parquet file written by json2parquet instead of the inferred schema, e.g. before they are appended to the file")
+	fs.StringVar(&coerce, "coerce", "strict", coerceUsage)
+	fs.IntVar(&maxErrors, "max-errors", 10, "Maximum number of invalid records reported (0 means no limit)")
+	inference.register(fs)
+	layoutOpts.register(fs)
+	encryption.register(fs, false)
+
+	if code, ok := parseArgs(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() < 1 {
+		return usageError(fs, "no input files")
+	}
+	coercion, err := parquet.ParseCoercion(coerce)
+	if err != nil {
+		return usageError(fs, "invalid coercion: %v", err)
+	}
+	layout, err := layoutOpts.layout()
+	if err != nil {
+		return usageError(fs, "%v", err)
+	}
+	kms, err := encryption.kms()
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err = setLogger(verbose); err != nil {
+		return fail("failed create logger: %v", err)
+	}
+	ctx, cancel := signalContext()
+	defer cancel()
+
+	var sc *parquet.Schema
+	if schemaFile != "" {
+		reader, err := parquet.OpenReader(schemaFile, kms)
+		if err != nil {
+			return fail("failed to read schema: %v", err)
+		}
+		sc = reader.Schema()
+		_ = reader.Close()
+	} else {
+		sb := inference.schemaBuilder()
+		if err = inference.infer(ctx, sb, fs.Args()); err != nil {
+			if errors.Is(err, parquet.ErrTypeMismatch) {
+				fmt.Printf("The records do not have a common schema: %v\n", err)
+				return exitInvalid
+			}
+			return fail("failed to infer parquet schema from JSON data: %v", err)
+		}
+		sc = sb.Schema()
+	}
+
+	// the records are converted by the writer of the files, the encoded data is discarded
+	wr, err := parquet.NewWriterTo(io.Discard, 1000, sc, parquet.WithCoercion(coercion),
+		parquet.WithListLayout(layout.Lists), parquet.WithTimestampType(layout.Timestamps))
+	if err != nil {
+		return fail("failed to create parquet writer: %v", err)
+	}
+	var records, invalid int64
+	for _, filename := range fs.Args() {
+		var record int64
+		_, err = readSource(ctx, filename, inference.configureReader, func(data tfJson.NDJsonRecord) {
+			record++
+			records++
+			if errW := wr.Write(data); errW != nil {
+				invalid++
+				if maxErrors <= 0 || invalid <= int64(maxErrors) {
+					fmt.Printf("%v: record %v: %v\n", filename, record, errW)
+				}
+			}
+		})
+		if err != nil {
+			wr.Abort()
+			return fail("failed to read file(%v): %v", filename, err)
+		}
+	}
+	if err = wr.Close(); err != nil {
+		return fail("failed to convert records: %v", err)
+	}
+	fmt.Printf("%v of %v records are valid\n", records-invalid, records)
+	if invalid > 0 {
+		return exitInvalid
+	}
+	return exitOK
+}